@@ -0,0 +1,185 @@
+// Command colibri runs operational tasks against the broker configured by the environment, like the application would.
+//
+// Usage:
+//
+//	colibri dlq list -queue <queue> [-action <action>] [-origin <app>] [-since <duration> | -from <time> -to <time>] [-limit <n>]
+//	colibri dlq replay -queue <queue> [filters] [-id <id>,...] [-topic <topic>] [-rate <per second>] [-dry-run]
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/signal"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/messaging"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/messaging/dlq"
+)
+
+const usage = `usage:
+  colibri dlq list   -queue <queue> [filters] [-limit <n>]
+  colibri dlq replay -queue <queue> [filters] [-limit <n>] [-topic <topic>] [-rate <per second>] [-dry-run]
+
+filters: -action <action> -origin <app> -since <duration> -from <RFC3339> -to <RFC3339> -id <id>,...`
+
+// dlqFlags are the flags shared by the dlq subcommands
+type dlqFlags struct {
+	queue  string
+	action string
+	origin string
+	ids    string
+	since  time.Duration
+	from   string
+	to     string
+	limit  int
+}
+
+func main() {
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt)
+	defer stop()
+
+	if err := run(ctx, os.Args[1:], os.Stdout); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+
+func run(ctx context.Context, args []string, out io.Writer) error {
+	if len(args) < 2 || args[0] != "dlq" {
+		return errors.New(usage)
+	}
+
+	switch args[1] {
+	case "list":
+		return listDeadLetters(ctx, args[2:], out)
+	case "replay":
+		return replayDeadLetters(ctx, args[2:], out)
+	default:
+		return errors.New(usage)
+	}
+}
+
+func listDeadLetters(ctx context.Context, args []string, out io.Writer) error {
+	fs, flags := newDlqFlagSet("list")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	filter, err := flags.filter()
+	if err != nil {
+		return err
+	}
+
+	initialize()
+	deadLetters, err := dlq.List(ctx, flags.queue, filter, flags.limit)
+	if err != nil {
+		return err
+	}
+
+	printDeadLetters(out, deadLetters)
+	return nil
+}
+
+func replayDeadLetters(ctx context.Context, args []string, out io.Writer) error {
+	fs, flags := newDlqFlagSet("replay")
+	topic := fs.String("topic", "", "topic receiving the replayed messages, the queue itself when empty")
+	rate := fs.Float64("rate", dlq.DefaultRatePerSecond, "maximum messages replayed per second")
+	dryRun := fs.Bool("dry-run", false, "list the messages that would be replayed without replaying them")
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	filter, err := flags.filter()
+	if err != nil {
+		return err
+	}
+
+	initialize()
+	result, err := dlq.Replay(ctx, flags.queue, dlq.ReplayOptions{
+		Filter:        filter,
+		Limit:         flags.limit,
+		Topic:         *topic,
+		RatePerSecond: *rate,
+		DryRun:        *dryRun,
+	})
+	if result == nil {
+		return err
+	}
+
+	printDeadLetters(out, result.Replayed)
+	verb := "replayed"
+	if *dryRun {
+		verb = "would be replayed"
+	}
+	fmt.Fprintf(out, "\n%d %s, %d skipped, %d failed\n", len(result.Replayed), verb, result.Skipped, len(result.Failed))
+	for _, failure := range result.Failed {
+		fmt.Fprintf(out, "  %s: %v\n", failure.DeadLetter.Message.ID, failure.Err)
+	}
+
+	return err
+}
+
+func newDlqFlagSet(name string) (*flag.FlagSet, *dlqFlags) {
+	flags := &dlqFlags{}
+	fs := flag.NewFlagSet("dlq "+name, flag.ContinueOnError)
+	fs.StringVar(&flags.queue, "queue", "", "consumer queue whose dead-letter queue is read (required)")
+	fs.StringVar(&flags.action, "action", "", "only messages with this action")
+	fs.StringVar(&flags.origin, "origin", "", "only messages published by this application")
+	fs.StringVar(&flags.ids, "id", "", "only messages with these comma separated ids")
+	fs.DurationVar(&flags.since, "since", 0, "only messages that occurred in this last duration")
+	fs.StringVar(&flags.from, "from", "", "only messages that occurred at or after this RFC3339 time")
+	fs.StringVar(&flags.to, "to", "", "only messages that occurred before this RFC3339 time")
+	fs.IntVar(&flags.limit, "limit", dlq.DefaultLimit, "maximum dead letters read")
+
+	return fs, flags
+}
+
+// filter validates the flags and returns the dead-letter filter they describe
+func (f *dlqFlags) filter() (dlq.Filter, error) {
+	if f.queue == "" {
+		return dlq.Filter{}, fmt.Errorf("-queue is required\n%s", usage)
+	}
+
+	filter := dlq.Filter{Action: f.action, Origin: f.origin}
+	if f.ids != "" {
+		filter.IDs = strings.Split(f.ids, ",")
+	}
+	if f.since > 0 {
+		filter.From = time.Now().Add(-f.since)
+	}
+
+	var err error
+	if f.from != "" {
+		if filter.From, err = time.Parse(time.RFC3339, f.from); err != nil {
+			return dlq.Filter{}, fmt.Errorf("invalid -from: %w", err)
+		}
+	}
+	if f.to != "" {
+		if filter.To, err = time.Parse(time.RFC3339, f.to); err != nil {
+			return dlq.Filter{}, fmt.Errorf("invalid -to: %w", err)
+		}
+	}
+
+	return filter, nil
+}
+
+// initialize loads the configuration and connects to the broker of the environment
+func initialize() {
+	colibri.InitializeApp()
+	messaging.Initialize()
+}
+
+func printDeadLetters(out io.Writer, deadLetters []*messaging.DeadLetter) {
+	w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tACTION\tORIGIN\tOCCURRED AT\tATTEMPTS\tREASON")
+	for _, d := range deadLetters {
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%d\t%s\n", d.Message.ID, d.Message.Action, d.Message.Origin,
+			d.Message.OccurredAt.Format(time.RFC3339), d.Attempts, d.Reason)
+	}
+	_ = w.Flush()
+}
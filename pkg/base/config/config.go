@@ -5,6 +5,7 @@ import (
 	"fmt"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 	"golang.org/x/exp/slices"
@@ -55,6 +56,10 @@ const (
 	CLOUD_NONE                    string = "none"
 	MESSAGING_CLOUD_DEFAULT       string = "CLOUD_DEFAULT"
 	MESSAGING_RABBITMQ            string = "RABBITMQ"
+	MESSAGING_KAFKA               string = "KAFKA"
+	MESSAGING_NATS                string = "NATS"
+	MESSAGING_MEMORY              string = "MEMORY"
+	CACHE_URI_MEMORY              string = "memory://"
 	SQL_DB_CONNECTION_URI_DEFAULT string = "host=%s port=%s user=%s password=%s dbname=%s application_name='%s' sslmode=%s"
 	VERSION                              = "v0.1.9"
 
@@ -151,8 +156,9 @@ func Load() error {
 	}
 
 	if messagingEnv := os.Getenv(ENV_COLIBRI_MESSAGING); messagingEnv != "" {
-		if messagingEnv != MESSAGING_CLOUD_DEFAULT && messagingEnv != MESSAGING_RABBITMQ {
-			return fmt.Errorf("invalid COLIBRI_MESSAGING value: %s. Allowed values: %s, %s", messagingEnv, MESSAGING_CLOUD_DEFAULT, MESSAGING_RABBITMQ)
+		allowedMessaging := []string{MESSAGING_CLOUD_DEFAULT, MESSAGING_RABBITMQ, MESSAGING_KAFKA, MESSAGING_NATS, MESSAGING_MEMORY}
+		if !slices.Contains(allowedMessaging, messagingEnv) {
+			return fmt.Errorf("invalid COLIBRI_MESSAGING value: %s. Allowed values: %s", messagingEnv, strings.Join(allowedMessaging, ", "))
 		}
 		COLIBRI_MESSAGING = messagingEnv
 	}
@@ -7,6 +7,7 @@ import (
 	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
 	colibri_monitoring_base "github.com/colibriproject-dev/colibri-sdk-go/pkg/base/monitoring/colibri-monitoring-base"
 	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/trace"
 )
 
 func TestProductionMonitoring_OT(t *testing.T) {
@@ -32,3 +33,28 @@ func monitoringTest(t *testing.T) {
 		assert.NotNil(t, transaction)
 	})
 }
+
+func TestTraceContextPropagation(t *testing.T) {
+	config.OTEL_EXPORTER_OTLP_ENDPOINT = "http://localhost:4318/v1/traces"
+	config.APP_NAME = "test"
+	Initialize()
+
+	t.Run("Should inject and extract trace context", func(t *testing.T) {
+		txn, ctx := StartTransaction(context.Background(), "txn-producer", colibri_monitoring_base.SpanKindProducer)
+		defer EndTransaction(txn)
+
+		carrier := map[string]string{}
+		InjectTraceContext(ctx, carrier)
+		extracted := ExtractTraceContext(context.Background(), carrier)
+
+		assert.Contains(t, carrier, "traceparent")
+		assert.Equal(t, trace.SpanContextFromContext(ctx).TraceID(), trace.SpanContextFromContext(extracted).TraceID())
+		assert.True(t, trace.SpanContextFromContext(extracted).IsRemote())
+	})
+
+	t.Run("Should return the same context when carrier is empty", func(t *testing.T) {
+		ctx := context.Background()
+
+		assert.Equal(t, ctx, ExtractTraceContext(ctx, nil))
+	})
+}
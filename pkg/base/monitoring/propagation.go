@@ -0,0 +1,22 @@
+package monitoring
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+)
+
+// InjectTraceContext writes the W3C trace context (traceparent, tracestate and baggage) of the context into the carrier
+func InjectTraceContext(ctx context.Context, carrier map[string]string) {
+	otel.GetTextMapPropagator().Inject(ctx, propagation.MapCarrier(carrier))
+}
+
+// ExtractTraceContext returns a copy of the context carrying the W3C trace context read from the carrier
+func ExtractTraceContext(ctx context.Context, carrier map[string]string) context.Context {
+	if len(carrier) == 0 {
+		return ctx
+	}
+
+	return otel.GetTextMapPropagator().Extract(ctx, propagation.MapCarrier(carrier))
+}
@@ -21,6 +21,8 @@ const (
 	localstackID  key = "localstack-id"
 	gcpEmulatorID key = "gcpEmulator-id"
 	rabbitmqID    key = "rabbitmq-id"
+	kafkaID       key = "kafka-id"
+	natsID        key = "nats-id"
 
 	DEVELOPMENT_ENVIRONMENT_PATH  string = "../../../development-environment"
 	DATABASE_ENVIRONMENT_PATH     string = DEVELOPMENT_ENVIRONMENT_PATH + "/database/"
@@ -42,6 +44,11 @@ func InitializeCacheDBTest() {
 	loadConfig()
 }
 
+func InitializeMemoryCacheDBTest() {
+	_ = os.Setenv(config.ENV_CACHE_URI, config.CACHE_URI_MEMORY)
+	loadConfig()
+}
+
 func InitializeSqlDBTest() {
 	UsePostgresContainer(context.Background())
 	loadConfig()
@@ -101,6 +108,41 @@ func getRabbitmqBasePath(path ...string) string {
 	return path[0]
 }
 
+func InitializeKafka() {
+	m.Lock()
+	ctx := context.WithValue(context.Background(), kafkaID, uuid.New().String())
+	_ = UseKafkaContainer(ctx)
+	loadConfig()
+
+	_ = os.Setenv(config.ENV_COLIBRI_MESSAGING, config.MESSAGING_KAFKA)
+	config.COLIBRI_MESSAGING = config.MESSAGING_KAFKA
+	_ = os.Setenv(config.ENV_CLOUD, config.CLOUD_NONE)
+	cloud.Initialize()
+	m.Unlock()
+}
+
+func InitializeNats() {
+	m.Lock()
+	ctx := context.WithValue(context.Background(), natsID, uuid.New().String())
+	_ = UseNatsContainer(ctx)
+	loadConfig()
+
+	_ = os.Setenv(config.ENV_COLIBRI_MESSAGING, config.MESSAGING_NATS)
+	config.COLIBRI_MESSAGING = config.MESSAGING_NATS
+	_ = os.Setenv(config.ENV_CLOUD, config.CLOUD_NONE)
+	cloud.Initialize()
+	m.Unlock()
+}
+
+func InitializeMemoryMessaging() {
+	m.Lock()
+	loadConfig()
+
+	_ = os.Setenv(config.ENV_COLIBRI_MESSAGING, config.MESSAGING_MEMORY)
+	config.COLIBRI_MESSAGING = config.MESSAGING_MEMORY
+	m.Unlock()
+}
+
 func loadConfig() {
 	_ = os.Setenv(config.ENV_ENVIRONMENT, config.ENVIRONMENT_TEST)
 	_ = os.Setenv(config.ENV_APP_NAME, "colibri-project-test")
@@ -0,0 +1,51 @@
+package test
+
+import (
+	"context"
+	"os"
+	"strings"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/testcontainers/testcontainers-go/modules/kafka"
+)
+
+const (
+	kafkaDockerImage = "confluentinc/confluent-local:7.5.0"
+	kafkaClusterID   = "colibri-project-test"
+)
+
+var kafkaContainerInstance *KafkaContainer
+
+type KafkaContainer struct {
+	kafkaContainer *kafka.KafkaContainer
+	brokers        []string
+	ctx            context.Context
+}
+
+func UseKafkaContainer(ctx context.Context) *KafkaContainer {
+	if kafkaContainerInstance == nil {
+		kafkaContainerInstance = &KafkaContainer{ctx: ctx}
+		kafkaContainerInstance.start()
+	}
+	return kafkaContainerInstance
+}
+
+func (c *KafkaContainer) start() {
+	var err error
+	c.kafkaContainer, err = kafka.Run(c.ctx, kafkaDockerImage, kafka.WithClusterID(kafkaClusterID))
+	if err != nil {
+		logging.Fatal(c.ctx).Err(err)
+	}
+
+	c.brokers, err = c.kafkaContainer.Brokers(c.ctx)
+	if err != nil {
+		logging.Fatal(c.ctx).Err(err)
+	}
+
+	c.setKafkaEnv()
+	logging.Info(c.ctx).Msgf("Test kafka started at: %s", strings.Join(c.brokers, ","))
+}
+
+func (c *KafkaContainer) setKafkaEnv() {
+	_ = os.Setenv("KAFKA_BROKERS", strings.Join(c.brokers, ","))
+}
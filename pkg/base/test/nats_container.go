@@ -0,0 +1,73 @@
+package test
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/docker/go-connections/nat"
+	"github.com/google/uuid"
+	"github.com/testcontainers/testcontainers-go"
+	"github.com/testcontainers/testcontainers-go/wait"
+)
+
+const (
+	natsDockerImage = "nats:2-alpine"
+	natsClientPort  = "4222"
+)
+
+var natsContainerInstance *NatsContainer
+
+type NatsContainer struct {
+	natsContainerRequest *testcontainers.ContainerRequest
+	natsContainer        testcontainers.Container
+	ctx                  context.Context
+}
+
+func UseNatsContainer(ctx context.Context) *NatsContainer {
+	if natsContainerInstance == nil {
+		natsContainerInstance = newNatsContainer()
+		natsContainerInstance.ctx = ctx
+		natsContainerInstance.start()
+	}
+	return natsContainerInstance
+}
+
+func newNatsContainer() *NatsContainer {
+	req := &testcontainers.ContainerRequest{
+		Image:        natsDockerImage,
+		ExposedPorts: []string{natsClientPort},
+		Cmd:          []string{"-js"},
+		Name:         fmt.Sprintf("colibri-project-test-nats-%s", uuid.New().String()),
+		WaitingFor: wait.ForAll(
+			wait.ForListeningPort(natsClientPort),
+			wait.ForLog("Server is ready"),
+		),
+	}
+
+	return &NatsContainer{natsContainerRequest: req}
+}
+
+func (c *NatsContainer) start() {
+	var err error
+	c.natsContainer, err = testcontainers.GenericContainer(c.ctx, testcontainers.GenericContainerRequest{
+		ContainerRequest: *c.natsContainerRequest,
+		Started:          true,
+	})
+	if err != nil {
+		logging.Fatal(c.ctx).Err(err)
+	}
+
+	clientPort, err := c.natsContainer.MappedPort(c.ctx, natsClientPort)
+	if err != nil {
+		logging.Fatal(c.ctx).Err(err)
+	}
+
+	c.setNatsEnv(clientPort)
+	logging.Info(c.ctx).Msgf("Test NATS started at port: %s", clientPort)
+}
+
+func (c *NatsContainer) setNatsEnv(port nat.Port) {
+	_ = os.Setenv("NATS_URL", fmt.Sprintf("nats://localhost:%s", port.Port()))
+}
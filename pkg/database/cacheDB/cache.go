@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"slices"
 	"strings"
 	"time"
 
@@ -20,6 +21,7 @@ const (
 type Cache[T any] struct {
 	name string
 	ttl  time.Duration
+	tags []string
 }
 
 // NewCache creates a new pointer to Cache struct.
@@ -29,7 +31,16 @@ type Cache[T any] struct {
 // - ttl: a time.Duration representing the time to live for the cache items.
 // Returns a pointer to Cache[T].
 func NewCache[T any](name string, ttl time.Duration) *Cache[T] {
-	return &Cache[T]{name, ttl}
+	return &Cache[T]{name: name, ttl: ttl}
+}
+
+// WithTags returns a copy of the cache that attaches tags to every item it saves, so they can be removed with InvalidateTags.
+// The receiver is left unchanged, so a shared cache can be tagged per call.
+//
+// tags: the tags to attach, e.g. "tenant:123" or "order:456".
+// Returns a new pointer to Cache[T].
+func (c *Cache[T]) WithTags(tags ...string) *Cache[T] {
+	return &Cache[T]{name: c.name, ttl: c.ttl, tags: slices.Concat(c.tags, tags)}
 }
 
 // Many retrieves multiple items of type T from the cache.
@@ -86,8 +97,9 @@ func (c *Cache[T]) One(ctx context.Context) (*T, error) {
 //
 // ctx: The context for the cache operation.
 // data: The data to be saved in the cache.
+// tags: optional tags attached to the item in addition to the cache tags.
 // Returns an error.
-func (c *Cache[T]) Set(ctx context.Context, data any) error {
+func (c *Cache[T]) Set(ctx context.Context, data any, tags ...string) error {
 	if err := c.validate(); err != nil {
 		return err
 	}
@@ -97,7 +109,35 @@ func (c *Cache[T]) Set(ctx context.Context, data any) error {
 		return err
 	}
 
-	return c.set(ctx, jsonData)
+	if err = c.set(ctx, jsonData); err != nil {
+		return err
+	}
+
+	return c.tag(ctx, slices.Concat(c.tags, tags))
+}
+
+// SetIfAbsent save data in cacheDB only when the key does not exist yet.
+//
+// ctx: The context for the cache operation.
+// data: The data to be saved in the cache.
+// tags: optional tags attached to the item in addition to the cache tags.
+// Returns true when the data was saved and an error.
+func (c *Cache[T]) SetIfAbsent(ctx context.Context, data any, tags ...string) (bool, error) {
+	if err := c.validate(); err != nil {
+		return false, err
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return false, err
+	}
+
+	saved, err := c.setNX(ctx, jsonData)
+	if err != nil || !saved {
+		return saved, err
+	}
+
+	return true, c.tag(ctx, slices.Concat(c.tags, tags))
 }
 
 // Del delete data in cachedDB.
@@ -117,23 +157,41 @@ func (c *Cache[T]) Del(ctx context.Context) error {
 // No parameters.
 // Returns an error.
 func (c *Cache[T]) validate() error {
+	return validateKey(c.name)
+}
+
+// getNamePrefixed returns a string with the prefixed name using the application name and cache name.
+//
+// No parameters.
+// Returns a string.
+func (c *Cache[T]) getNamePrefixed() string {
+	return getKeyPrefixed(c.name)
+}
+
+// validateKey checks if the cache is initialized and the key has a name.
+//
+// Parameter:
+// - name: The name of the key.
+// Returns an error.
+func validateKey(name string) error {
 	if instance == nil {
 		return errors.New("cache not initialized")
 	}
 
-	if c.name == "" {
+	if name == "" {
 		return errors.New("cache without name")
 	}
 
 	return nil
 }
 
-// getNamePrefixed returns a string with the prefixed name using the application name and cache name.
+// getKeyPrefixed returns a string with the prefixed key using the application name and the key name.
 //
-// No parameters.
+// Parameter:
+// - name: The name of the key.
 // Returns a string.
-func (c *Cache[T]) getNamePrefixed() string {
-	return fmt.Sprintf("%s::%s", config.APP_NAME, c.name)
+func getKeyPrefixed(name string) string {
+	return fmt.Sprintf("%s::%s", config.APP_NAME, name)
 }
 
 // isErrRedisMoved checks if the error contains the string "MOVED".
@@ -158,9 +216,18 @@ func (c *Cache[T]) reconnectInstanceAfterError(err error) {
 //
 // ctx: The context for the cache operation.
 // Returns a byte slice and an error.
-func (c *Cache[T]) get(ctx context.Context) ([]byte, error) {
+func (c *Cache[T]) get(ctx context.Context) (result []byte, err error) {
+	observation := startCacheObservation(ctx, c.name, cacheOperationGet)
+	defer func() {
+		outcome := cacheOutcomeHit
+		if result == nil {
+			outcome = cacheOutcomeMiss
+		}
+		observation.end(outcome, err)
+	}()
+
 	for {
-		result, err := instance.Get(ctx, c.getNamePrefixed()).Bytes()
+		result, err = instance.Get(ctx, c.getNamePrefixed()).Bytes()
 		if err != nil {
 			if err.Error() == errRedisNil {
 				return nil, nil
@@ -180,9 +247,12 @@ func (c *Cache[T]) get(ctx context.Context) ([]byte, error) {
 // ctx: The context for the cache operation.
 // data: The data to be saved in the cache.
 // Returns an error.
-func (c *Cache[T]) set(ctx context.Context, data []byte) error {
+func (c *Cache[T]) set(ctx context.Context, data []byte) (err error) {
+	observation := startCacheObservation(ctx, c.name, cacheOperationSet)
+	defer func() { observation.end(cacheOutcomeSet, err) }()
+
 	for {
-		err := instance.Set(ctx, c.getNamePrefixed(), data, c.ttl).Err()
+		err = instance.Set(ctx, c.getNamePrefixed(), data, c.ttl).Err()
 		if err != nil {
 			if c.isErrRedisMoved(err) {
 				c.reconnectInstanceAfterError(err)
@@ -195,13 +265,39 @@ func (c *Cache[T]) set(ctx context.Context, data []byte) error {
 	}
 }
 
+// setNX saves data in the cacheDB when the key does not exist.
+//
+// ctx: The context for the cache operation.
+// data: The data to be saved in the cache.
+// Returns true when the data was saved and an error.
+func (c *Cache[T]) setNX(ctx context.Context, data []byte) (saved bool, err error) {
+	observation := startCacheObservation(ctx, c.name, cacheOperationSet)
+	defer func() { observation.end(cacheOutcomeSet, err) }()
+
+	for {
+		saved, err = instance.SetNX(ctx, c.getNamePrefixed(), data, c.ttl).Result()
+		if err != nil {
+			if c.isErrRedisMoved(err) {
+				c.reconnectInstanceAfterError(err)
+				continue
+			} else {
+				return false, err
+			}
+		}
+		return saved, nil
+	}
+}
+
 // del deletes data in cachedDB.
 //
 // ctx: The context for the cache operation.
 // Returns an error.
-func (c *Cache[T]) del(ctx context.Context) error {
+func (c *Cache[T]) del(ctx context.Context) (err error) {
+	observation := startCacheObservation(ctx, c.name, cacheOperationDel)
+	defer func() { observation.end(cacheOutcomeEvict, err) }()
+
 	for {
-		err := instance.Del(ctx, c.getNamePrefixed()).Err()
+		err = instance.Del(ctx, c.getNamePrefixed()).Err()
 		if err != nil {
 			if c.isErrRedisMoved(err) {
 				c.reconnectInstanceAfterError(err)
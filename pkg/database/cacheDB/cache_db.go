@@ -26,6 +26,15 @@ func Initialize() {
 	}
 
 	opts := &redis.Options{Addr: config.CACHE_URI, Password: config.CACHE_PASSWORD}
+	if isMemoryCacheURI() {
+		backend, err := startMemoryCacheDB()
+		if err != nil {
+			logging.Fatal(context.Background()).Err(err).Msg("An error occurred while trying to start the in-memory cache database")
+		}
+
+		memoryBackend = backend
+		opts.Addr = backend.addr()
+	}
 
 	redisClient := redis.NewClient(opts)
 
@@ -57,6 +66,8 @@ func (o cacheDBObserver) Close() {
 		logging.Warn(context.Background()).Msg("WaitGroup timed out, forcing close the cache connection")
 	}
 
+	releaseHeldLeases()
+
 	logging.Info(context.Background()).Msg("closing cache connection")
 	if err := instance.Close(); err != nil {
 		logging.
@@ -64,4 +75,9 @@ func (o cacheDBObserver) Close() {
 			Err(err).
 			Msg("error when closing cache connection")
 	}
+
+	if memoryBackend != nil {
+		memoryBackend.close()
+		memoryBackend = nil
+	}
 }
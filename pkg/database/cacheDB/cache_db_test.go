@@ -1,8 +1,12 @@
 package cacheDB
 
 import (
+	"context"
+	"os"
 	"testing"
 
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
+
 	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/test"
 	"github.com/stretchr/testify/assert"
 )
@@ -20,3 +24,32 @@ func TestInitialize(t *testing.T) {
 		assert.NotNil(t, instance)
 	})
 }
+
+func TestInitializeInMemory(t *testing.T) {
+	t.Run("Should initialize in-memory backend", func(t *testing.T) {
+		useMemoryCacheDB(t)
+
+		assert.NotNil(t, instance)
+		assert.NotNil(t, memoryBackend)
+		assert.NoError(t, instance.Ping(context.Background()).Err())
+	})
+}
+
+// useMemoryCacheDB initializes the cacheDB with the in-memory backend and restores the previous state on cleanup.
+func useMemoryCacheDB(t *testing.T) {
+	previousURI := os.Getenv(config.ENV_CACHE_URI)
+	previousInstance := instance
+
+	instance = nil
+	test.InitializeMemoryCacheDBTest()
+	Initialize()
+
+	t.Cleanup(func() {
+		_ = instance.Close()
+		memoryBackend.close()
+		memoryBackend = nil
+		instance = previousInstance
+		_ = os.Setenv(config.ENV_CACHE_URI, previousURI)
+		config.CACHE_URI = previousURI
+	})
+}
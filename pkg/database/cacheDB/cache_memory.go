@@ -0,0 +1,88 @@
+package cacheDB
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+)
+
+// memoryClockTick is the interval used to advance the in-memory backend clock, expiring keys whose TTL has elapsed.
+const memoryClockTick time.Duration = 50 * time.Millisecond
+
+var memoryBackend *memoryCacheDB
+
+// memoryCacheDB is an in-process Redis-compatible backend used for local development and unit tests.
+type memoryCacheDB struct {
+	server *miniredis.Miniredis
+	done   chan struct{}
+}
+
+// isMemoryCacheURI checks if the configured cache uri selects the in-memory backend.
+//
+// No parameters.
+// Returns a bool.
+func isMemoryCacheURI() bool {
+	return strings.HasPrefix(config.CACHE_URI, config.CACHE_URI_MEMORY)
+}
+
+// startMemoryCacheDB starts the in-memory backend and its clock.
+//
+// No parameters.
+// Returns a pointer to memoryCacheDB and an error.
+func startMemoryCacheDB() (*memoryCacheDB, error) {
+	server := miniredis.NewMiniRedis()
+	if err := server.Start(); err != nil {
+		return nil, err
+	}
+
+	if config.CACHE_PASSWORD != "" {
+		server.RequireAuth(config.CACHE_PASSWORD)
+	}
+
+	m := &memoryCacheDB{server: server, done: make(chan struct{})}
+	go m.runClock()
+
+	logging.Info(context.Background()).Msgf("In-memory cache database started at %s", server.Addr())
+	return m, nil
+}
+
+// runClock advances the backend clock in real time, so TTLs expire as they would in Redis.
+//
+// No parameters.
+// No return values.
+func (m *memoryCacheDB) runClock() {
+	ticker := time.NewTicker(memoryClockTick)
+	defer ticker.Stop()
+
+	last := time.Now()
+	for {
+		select {
+		case <-m.done:
+			return
+		case now := <-ticker.C:
+			m.server.FastForward(now.Sub(last))
+			last = now
+		}
+	}
+}
+
+// addr returns the address the backend is listening on.
+//
+// No parameters.
+// Returns a string.
+func (m *memoryCacheDB) addr() string {
+	return m.server.Addr()
+}
+
+// close stops the backend clock and server.
+//
+// No parameters.
+// No return values.
+func (m *memoryCacheDB) close() {
+	close(m.done)
+	m.server.Close()
+}
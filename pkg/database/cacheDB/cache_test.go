@@ -121,3 +121,63 @@ func TestCache(t *testing.T) {
 		assert.Nil(t, manyFinalResult)
 	})
 }
+
+func TestCacheInMemory(t *testing.T) {
+	useMemoryCacheDB(t)
+
+	ctx := context.Background()
+	expected := userCached{Id: 1, Name: "User 1"}
+
+	t.Run("Should set and get data in memory cache", func(t *testing.T) {
+		cache := NewCache[userCached]("cache-memory-test", time.Hour)
+
+		setErr := cache.Set(ctx, expected)
+		result, err := cache.One(ctx)
+
+		assert.NoError(t, setErr)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, *result)
+	})
+
+	t.Run("Should expire data in memory cache after ttl", func(t *testing.T) {
+		cache := NewCache[userCached]("cache-memory-ttl-test", 100*time.Millisecond)
+
+		assert.NoError(t, cache.Set(ctx, expected))
+		time.Sleep(300 * time.Millisecond)
+		result, err := cache.One(ctx)
+
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Should set data only when key is absent", func(t *testing.T) {
+		cache := NewCache[userCached]("cache-memory-set-if-absent-test", time.Hour)
+
+		first, firstErr := cache.SetIfAbsent(ctx, expected)
+		second, secondErr := cache.SetIfAbsent(ctx, userCached{Id: 2, Name: "User 2"})
+		result, err := cache.One(ctx)
+
+		assert.NoError(t, firstErr)
+		assert.True(t, first)
+		assert.NoError(t, secondErr)
+		assert.False(t, second)
+		assert.NoError(t, err)
+		assert.Equal(t, expected, *result)
+	})
+
+	t.Run("Should deliver published messages to subscribers", func(t *testing.T) {
+		sub := instance.Subscribe(ctx, "cache-memory-channel")
+		defer sub.Close()
+		_, err := sub.Receive(ctx)
+		assert.NoError(t, err)
+
+		assert.NoError(t, instance.Publish(ctx, "cache-memory-channel", "hello").Err())
+
+		select {
+		case msg := <-sub.Channel():
+			assert.Equal(t, "hello", msg.Payload)
+		case <-time.After(time.Second):
+			t.Fatal("message not received")
+		}
+	})
+}
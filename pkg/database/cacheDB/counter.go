@@ -0,0 +1,75 @@
+package cacheDB
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// incrCounterScript increments the counter and sets its ttl when the counter has no expiration yet.
+var incrCounterScript = redis.NewScript(`
+local value = redis.call("INCRBY", KEYS[1], ARGV[1])
+if tonumber(ARGV[2]) > 0 and redis.call("PTTL", KEYS[1]) == -1 then
+	redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return value`)
+
+// Counter struct
+type Counter struct {
+	name string
+	ttl  time.Duration
+}
+
+// NewCounter creates a new pointer to Counter struct.
+//
+// Parameters:
+// - name: a string representing the name of the counter.
+// - ttl: a time.Duration representing the window of the counter, starting on its first increment. Zero means no expiration.
+// Returns a pointer to Counter.
+func NewCounter(name string, ttl time.Duration) *Counter {
+	return &Counter{name, ttl}
+}
+
+// Incr atomically increments the counter by delta.
+//
+// ctx: The context for the counter operation.
+// delta: The value to add, negative values decrement the counter.
+// Returns the new value of the counter and an error.
+func (c *Counter) Incr(ctx context.Context, delta int64) (int64, error) {
+	if err := validateKey(c.name); err != nil {
+		return 0, err
+	}
+
+	return incrCounterScript.Run(ctx, instance, []string{getKeyPrefixed(c.name)}, delta, c.ttl.Milliseconds()).Int64()
+}
+
+// Get retrieves the current value of the counter.
+//
+// ctx: The context for the counter operation.
+// Returns the value of the counter, zero when it does not exist, and an error.
+func (c *Counter) Get(ctx context.Context) (int64, error) {
+	if err := validateKey(c.name); err != nil {
+		return 0, err
+	}
+
+	value, err := instance.Get(ctx, getKeyPrefixed(c.name)).Int64()
+	if errors.Is(err, redis.Nil) {
+		return 0, nil
+	}
+
+	return value, err
+}
+
+// Reset deletes the counter.
+//
+// ctx: The context for the counter operation.
+// Returns an error.
+func (c *Counter) Reset(ctx context.Context) error {
+	if err := validateKey(c.name); err != nil {
+		return err
+	}
+
+	return instance.Del(ctx, getKeyPrefixed(c.name)).Err()
+}
@@ -0,0 +1,54 @@
+package cacheDB
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCounter(t *testing.T) {
+	useMemoryCacheDB(t)
+
+	ctx := context.Background()
+
+	t.Run("Should increment and decrement counter", func(t *testing.T) {
+		counter := NewCounter("counter-test", time.Hour)
+
+		first, firstErr := counter.Incr(ctx, 5)
+		second, secondErr := counter.Incr(ctx, -2)
+		value, err := counter.Get(ctx)
+
+		assert.NoError(t, firstErr)
+		assert.Equal(t, int64(5), first)
+		assert.NoError(t, secondErr)
+		assert.Equal(t, int64(3), second)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(3), value)
+	})
+
+	t.Run("Should expire counter after ttl window", func(t *testing.T) {
+		counter := NewCounter("counter-ttl-test", 100*time.Millisecond)
+
+		_, err := counter.Incr(ctx, 1)
+		assert.NoError(t, err)
+		time.Sleep(300 * time.Millisecond)
+		value, err := counter.Get(ctx)
+
+		assert.NoError(t, err)
+		assert.Zero(t, value)
+	})
+
+	t.Run("Should reset counter", func(t *testing.T) {
+		counter := NewCounter("counter-reset-test", 0)
+
+		_, err := counter.Incr(ctx, 1)
+		assert.NoError(t, err)
+		assert.NoError(t, counter.Reset(ctx))
+		value, err := counter.Get(ctx)
+
+		assert.NoError(t, err)
+		assert.Zero(t, value)
+	})
+}
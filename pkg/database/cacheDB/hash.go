@@ -0,0 +1,118 @@
+package cacheDB
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// Hash struct
+type Hash[T any] struct {
+	name string
+	ttl  time.Duration
+}
+
+// NewHash creates a new pointer to Hash struct.
+//
+// Parameters:
+// - name: a string representing the name of the hash.
+// - ttl: a time.Duration representing the time to live for the whole hash, refreshed on every write. Zero means no expiration.
+// Returns a pointer to Hash[T].
+func NewHash[T any](name string, ttl time.Duration) *Hash[T] {
+	return &Hash[T]{name, ttl}
+}
+
+// Get retrieves a single field of the hash.
+//
+// ctx: The context for the hash operation.
+// field: The field to retrieve.
+// Returns a pointer to the retrieved item of type T, or nil when the field does not exist, and an error.
+func (h *Hash[T]) Get(ctx context.Context, field string) (*T, error) {
+	if err := validateKey(h.name); err != nil {
+		return nil, err
+	}
+
+	result, err := instance.HGet(ctx, getKeyPrefixed(h.name), field).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	model := new(T)
+	if err = json.Unmarshal(result, model); err != nil {
+		return nil, err
+	}
+
+	return model, nil
+}
+
+// All retrieves every field of the hash.
+//
+// ctx: The context for the hash operation.
+// Returns a map of field to item of type T and an error.
+func (h *Hash[T]) All(ctx context.Context) (map[string]T, error) {
+	if err := validateKey(h.name); err != nil {
+		return nil, err
+	}
+
+	result, err := instance.HGetAll(ctx, getKeyPrefixed(h.name)).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	values := make(map[string]T, len(result))
+	for field, raw := range result {
+		var model T
+		if err = json.Unmarshal([]byte(raw), &model); err != nil {
+			return nil, err
+		}
+		values[field] = model
+	}
+
+	return values, nil
+}
+
+// Set saves a single field of the hash.
+//
+// ctx: The context for the hash operation.
+// field: The field to save.
+// data: The data to be saved in the field.
+// Returns an error.
+func (h *Hash[T]) Set(ctx context.Context, field string, data T) error {
+	if err := validateKey(h.name); err != nil {
+		return err
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	key := getKeyPrefixed(h.name)
+	_, err = instance.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.HSet(ctx, key, field, jsonData)
+		if h.ttl > 0 {
+			pipe.PExpire(ctx, key, h.ttl)
+		}
+		return nil
+	})
+
+	return err
+}
+
+// Del deletes fields of the hash.
+//
+// ctx: The context for the hash operation.
+// fields: The fields to delete.
+// Returns an error.
+func (h *Hash[T]) Del(ctx context.Context, fields ...string) error {
+	if err := validateKey(h.name); err != nil {
+		return err
+	}
+
+	return instance.HDel(ctx, getKeyPrefixed(h.name), fields...).Err()
+}
@@ -0,0 +1,53 @@
+package cacheDB
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHash(t *testing.T) {
+	useMemoryCacheDB(t)
+
+	ctx := context.Background()
+	hash := NewHash[userCached]("hash-test", time.Hour)
+
+	t.Run("Should return error when hash name is empty", func(t *testing.T) {
+		result, err := NewHash[userCached]("", time.Hour).Get(ctx, "1")
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Should set and get hash fields", func(t *testing.T) {
+		assert.NoError(t, hash.Set(ctx, "1", userCached{Id: 1, Name: "User 1"}))
+		assert.NoError(t, hash.Set(ctx, "2", userCached{Id: 2, Name: "User 2"}))
+
+		result, err := hash.Get(ctx, "1")
+		all, allErr := hash.All(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, userCached{Id: 1, Name: "User 1"}, *result)
+		assert.NoError(t, allErr)
+		assert.Len(t, all, 2)
+		assert.Equal(t, userCached{Id: 2, Name: "User 2"}, all["2"])
+	})
+
+	t.Run("Should return nil when field does not exist", func(t *testing.T) {
+		result, err := hash.Get(ctx, "not-found")
+
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Should delete hash fields", func(t *testing.T) {
+		assert.NoError(t, hash.Del(ctx, "1"))
+
+		result, err := hash.Get(ctx, "1")
+
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+	})
+}
@@ -0,0 +1,409 @@
+package cacheDB
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand"
+	"sync"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	lockMinBackoff time.Duration = 10 * time.Millisecond
+	lockMaxBackoff time.Duration = time.Second
+
+	lockExtendError  string = "could not extend lease %s, it will be considered lost"
+	lockReleaseError string = "could not release lease %s"
+)
+
+var (
+	// ErrLockNotAcquired is returned when a lock or semaphore could not be acquired.
+	ErrLockNotAcquired = errors.New("lock not acquired")
+	// ErrLockNotHeld is returned when releasing a lock or semaphore that is not held by the caller.
+	ErrLockNotHeld = errors.New("lock not held")
+	// ErrLockAlreadyHeld is returned when acquiring a lock or semaphore that is already held by the caller.
+	ErrLockAlreadyHeld = errors.New("lock already held")
+)
+
+var (
+	releaseLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0`)
+
+	extendLockScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("PEXPIRE", KEYS[1], ARGV[2])
+end
+return 0`)
+
+	acquireSemaphoreScript = redis.NewScript(`
+redis.call("ZREMRANGEBYSCORE", KEYS[1], "-inf", ARGV[2])
+if redis.call("ZCARD", KEYS[1]) < tonumber(ARGV[4]) then
+	redis.call("ZADD", KEYS[1], ARGV[3], ARGV[1])
+	redis.call("PEXPIRE", KEYS[1], ARGV[5])
+	return 1
+end
+return 0`)
+
+	extendSemaphoreScript = redis.NewScript(`
+if redis.call("ZSCORE", KEYS[1], ARGV[1]) then
+	redis.call("ZADD", KEYS[1], ARGV[2], ARGV[1])
+	redis.call("PEXPIRE", KEYS[1], ARGV[3])
+	return 1
+end
+return 0`)
+)
+
+// heldLeases keeps every lease currently held by this process, so they can be released on shutdown.
+var heldLeases sync.Map
+
+// notHeldLease is the closed channel returned by Lost when the lease is not held.
+var notHeldLease = func() chan struct{} {
+	ch := make(chan struct{})
+	close(ch)
+	return ch
+}()
+
+// Lock is a distributed mutual exclusion lock backed by the cacheDB.
+//
+// The lock is acquired with SET NX PX and a unique token, and is released with a Lua script
+// that only deletes the key when the token still matches. While held, the lease is extended
+// automatically until Release is called.
+type Lock struct {
+	lease *lease
+}
+
+// NewLock creates a new pointer to Lock struct.
+//
+// Parameters:
+// - name: a string representing the name of the lock.
+// - ttl: a time.Duration representing the lease time of the lock.
+// Returns a pointer to Lock.
+func NewLock(name string, ttl time.Duration) *Lock {
+	l := &lease{name: name, ttl: ttl}
+	l.key = fmt.Sprintf("%s::lock::%s", config.APP_NAME, name)
+	l.acquireFn = func(ctx context.Context, token string) (bool, error) {
+		return instance.SetNX(ctx, l.key, token, l.ttl).Result()
+	}
+	l.extendFn = func(ctx context.Context, token string) (bool, error) {
+		return runLeaseScript(ctx, extendLockScript, l.key, token, l.ttl.Milliseconds())
+	}
+	l.releaseFn = func(ctx context.Context, token string) (bool, error) {
+		return runLeaseScript(ctx, releaseLockScript, l.key, token)
+	}
+
+	return &Lock{lease: l}
+}
+
+// TryAcquire tries to acquire the lock once, without waiting.
+//
+// ctx: The context for the lock operation.
+// Returns true if the lock was acquired and an error.
+func (l *Lock) TryAcquire(ctx context.Context) (bool, error) {
+	return l.lease.tryAcquire(ctx)
+}
+
+// Acquire waits until the lock is acquired or the context is done, retrying with exponential backoff.
+//
+// ctx: The context for the lock operation.
+// Returns an error.
+func (l *Lock) Acquire(ctx context.Context) error {
+	return l.lease.acquire(ctx)
+}
+
+// Release releases the lock if it is still held by this instance.
+//
+// ctx: The context for the lock operation.
+// Returns an error.
+func (l *Lock) Release(ctx context.Context) error {
+	return l.lease.release(ctx)
+}
+
+// Lost returns a channel that is closed when a held lock could not be extended.
+// When the lock is not held, the channel is already closed.
+//
+// No parameters.
+// Returns a receive-only channel.
+func (l *Lock) Lost() <-chan struct{} {
+	return l.lease.lostChannel()
+}
+
+// Semaphore is a distributed counting semaphore backed by the cacheDB.
+//
+// Each holder is stored in a sorted set scored by its lease expiration, so holders that
+// crash without releasing are evicted when their lease expires.
+type Semaphore struct {
+	lease *lease
+}
+
+// NewSemaphore creates a new pointer to Semaphore struct.
+//
+// Parameters:
+// - name: a string representing the name of the semaphore.
+// - size: an int representing the maximum number of concurrent holders.
+// - ttl: a time.Duration representing the lease time of each holder.
+// Returns a pointer to Semaphore.
+func NewSemaphore(name string, size int, ttl time.Duration) *Semaphore {
+	l := &lease{name: name, ttl: ttl}
+	l.key = fmt.Sprintf("%s::semaphore::%s", config.APP_NAME, name)
+	l.acquireFn = func(ctx context.Context, token string) (bool, error) {
+		now := time.Now()
+		return runLeaseScript(ctx, acquireSemaphoreScript, l.key,
+			token, now.UnixMilli(), now.Add(l.ttl).UnixMilli(), size, l.ttl.Milliseconds())
+	}
+	l.extendFn = func(ctx context.Context, token string) (bool, error) {
+		return runLeaseScript(ctx, extendSemaphoreScript, l.key,
+			token, time.Now().Add(l.ttl).UnixMilli(), l.ttl.Milliseconds())
+	}
+	l.releaseFn = func(ctx context.Context, token string) (bool, error) {
+		removed, err := instance.ZRem(ctx, l.key, token).Result()
+		return removed > 0, err
+	}
+
+	return &Semaphore{lease: l}
+}
+
+// TryAcquire tries to acquire a semaphore slot once, without waiting.
+//
+// ctx: The context for the semaphore operation.
+// Returns true if a slot was acquired and an error.
+func (s *Semaphore) TryAcquire(ctx context.Context) (bool, error) {
+	return s.lease.tryAcquire(ctx)
+}
+
+// Acquire waits until a semaphore slot is acquired or the context is done, retrying with exponential backoff.
+//
+// ctx: The context for the semaphore operation.
+// Returns an error.
+func (s *Semaphore) Acquire(ctx context.Context) error {
+	return s.lease.acquire(ctx)
+}
+
+// Release releases the semaphore slot held by this instance.
+//
+// ctx: The context for the semaphore operation.
+// Returns an error.
+func (s *Semaphore) Release(ctx context.Context) error {
+	return s.lease.release(ctx)
+}
+
+// Lost returns a channel that is closed when a held slot could not be extended.
+// When no slot is held, the channel is already closed.
+//
+// No parameters.
+// Returns a receive-only channel.
+func (s *Semaphore) Lost() <-chan struct{} {
+	return s.lease.lostChannel()
+}
+
+// lease holds the shared acquire, keep-alive and release logic of Lock and Semaphore.
+type lease struct {
+	mu        sync.Mutex
+	name      string
+	key       string
+	ttl       time.Duration
+	token     string
+	stop      context.CancelFunc
+	stopped   chan struct{}
+	lost      chan struct{}
+	acquireFn func(ctx context.Context, token string) (bool, error)
+	extendFn  func(ctx context.Context, token string) (bool, error)
+	releaseFn func(ctx context.Context, token string) (bool, error)
+}
+
+// validate checks if the cache is initialized and the lease has a name and a positive ttl.
+//
+// No parameters.
+// Returns an error.
+func (l *lease) validate() error {
+	if instance == nil {
+		return errors.New("cache not initialized")
+	}
+
+	if l.name == "" {
+		return errors.New("lock without name")
+	}
+
+	if l.ttl <= 0 {
+		return errors.New("lock without ttl")
+	}
+
+	return nil
+}
+
+// tryAcquire makes a single attempt to acquire the lease and starts the keep-alive on success.
+//
+// ctx: The context for the lease operation.
+// Returns true if the lease was acquired and an error.
+func (l *lease) tryAcquire(ctx context.Context) (bool, error) {
+	if err := l.validate(); err != nil {
+		return false, err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.token != "" {
+		return false, ErrLockAlreadyHeld
+	}
+
+	token := uuid.New().String()
+	ok, err := l.acquireFn(ctx, token)
+	if err != nil || !ok {
+		return false, err
+	}
+
+	l.token = token
+	l.lost = make(chan struct{})
+	l.startKeepAlive(token)
+	heldLeases.Store(l, struct{}{})
+
+	return true, nil
+}
+
+// acquire retries tryAcquire with exponential backoff and jitter until it succeeds or ctx is done.
+//
+// ctx: The context for the lease operation.
+// Returns an error.
+func (l *lease) acquire(ctx context.Context) error {
+	backoff := lockMinBackoff
+	for {
+		ok, err := l.tryAcquire(ctx)
+		if err != nil {
+			return err
+		}
+		if ok {
+			return nil
+		}
+
+		wait := backoff/2 + time.Duration(rand.Int63n(int64(backoff)))
+		select {
+		case <-ctx.Done():
+			return errors.Join(ErrLockNotAcquired, ctx.Err())
+		case <-time.After(wait):
+		}
+
+		backoff = min(backoff*2, lockMaxBackoff)
+	}
+}
+
+// release stops the keep-alive and removes the lease if it is still owned by this instance.
+//
+// ctx: The context for the lease operation.
+// Returns an error.
+func (l *lease) release(ctx context.Context) error {
+	if err := l.validate(); err != nil {
+		return err
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.token == "" {
+		return ErrLockNotHeld
+	}
+
+	l.stop()
+	<-l.stopped
+	heldLeases.Delete(l)
+
+	token := l.token
+	l.token = ""
+
+	released, err := l.releaseFn(ctx, token)
+	if err != nil {
+		return err
+	}
+	if !released {
+		return ErrLockNotHeld
+	}
+
+	return nil
+}
+
+// startKeepAlive extends the lease every third of its ttl until it is released or lost.
+//
+// token: the token that owns the lease.
+func (l *lease) startKeepAlive(token string) {
+	ctx, cancel := context.WithCancel(context.Background())
+	l.stop = cancel
+	stopped := make(chan struct{})
+	l.stopped = stopped
+	lost := l.lost
+
+	go func() {
+		defer close(stopped)
+
+		ticker := time.NewTicker(l.ttl / 3)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+				if ok, err := l.extendFn(ctx, token); ctx.Err() == nil && (err != nil || !ok) {
+					logging.Warn(ctx).Err(err).Msgf(lockExtendError, l.key)
+					close(lost)
+					return
+				}
+			}
+		}
+	}()
+}
+
+// lostChannel returns the channel closed when the current lease is lost, or a closed channel when it is not held.
+//
+// No parameters.
+// Returns a receive-only channel.
+func (l *lease) lostChannel() <-chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.token == "" {
+		return notHeldLease
+	}
+
+	return l.lost
+}
+
+// runLeaseScript runs a lease Lua script and reports whether it returned a positive value.
+//
+// ctx: The context for the script execution.
+// script: the script to run.
+// key: the key of the lease.
+// args: the script arguments.
+// Returns true if the script returned a positive value and an error.
+func runLeaseScript(ctx context.Context, script *redis.Script, key string, args ...any) (bool, error) {
+	result, err := script.Run(ctx, instance, []string{key}, args...).Int64()
+	if err != nil {
+		return false, err
+	}
+
+	return result > 0, nil
+}
+
+// releaseHeldLeases releases every lease still held by this process.
+//
+// No parameters.
+// No return values.
+func releaseHeldLeases() {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	heldLeases.Range(func(key, _ any) bool {
+		l := key.(*lease)
+		if err := l.release(ctx); err != nil && !errors.Is(err, ErrLockNotHeld) {
+			logging.Error(ctx).Err(err).Msgf(lockReleaseError, l.key)
+		}
+		return true
+	})
+}
@@ -0,0 +1,171 @@
+package cacheDB
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// isClosed reports whether the channel is closed without blocking
+func isClosed(ch <-chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
+
+func TestLockNotInitializedValidation(t *testing.T) {
+	instance = nil
+
+	t.Run("Should return error when cacheDB is not initialized", func(t *testing.T) {
+		ok, err := NewLock("lock-test", time.Second).TryAcquire(context.Background())
+
+		assert.Error(t, err)
+		assert.False(t, ok)
+	})
+}
+
+func TestLock(t *testing.T) {
+	useMemoryCacheDB(t)
+
+	ctx := context.Background()
+
+	t.Run("Should return error when lock name is empty", func(t *testing.T) {
+		ok, err := NewLock("", time.Second).TryAcquire(ctx)
+
+		assert.Error(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Should acquire and release lock", func(t *testing.T) {
+		lock := NewLock("lock-acquire-release", time.Second)
+
+		ok, err := lock.TryAcquire(ctx)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		assert.NoError(t, lock.Release(ctx))
+		assert.ErrorIs(t, lock.Release(ctx), ErrLockNotHeld)
+	})
+
+	t.Run("Should return a closed lost channel when the lock is not held", func(t *testing.T) {
+		lock := NewLock("lock-lost-not-held", time.Second)
+		assert.True(t, isClosed(lock.Lost()))
+
+		ok, err := lock.TryAcquire(ctx)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.False(t, isClosed(lock.Lost()))
+
+		assert.NoError(t, lock.Release(ctx))
+		assert.True(t, isClosed(lock.Lost()))
+	})
+
+	t.Run("Should not acquire lock held by another holder", func(t *testing.T) {
+		first := NewLock("lock-concurrent", time.Second)
+		second := NewLock("lock-concurrent", time.Second)
+
+		ok, err := first.TryAcquire(ctx)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = second.TryAcquire(ctx)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		assert.NoError(t, first.Release(ctx))
+
+		ok, err = second.TryAcquire(ctx)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.NoError(t, second.Release(ctx))
+	})
+
+	t.Run("Should keep lock alive while held", func(t *testing.T) {
+		first := NewLock("lock-keep-alive", 300*time.Millisecond)
+		second := NewLock("lock-keep-alive", 300*time.Millisecond)
+
+		assert.NoError(t, first.Acquire(ctx))
+		time.Sleep(time.Second)
+
+		ok, err := second.TryAcquire(ctx)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.NoError(t, first.Release(ctx))
+	})
+
+	t.Run("Should return error when context is done while waiting", func(t *testing.T) {
+		first := NewLock("lock-wait", time.Second)
+		second := NewLock("lock-wait", time.Second)
+		assert.NoError(t, first.Acquire(ctx))
+
+		timeoutCtx, cancel := context.WithTimeout(ctx, 200*time.Millisecond)
+		defer cancel()
+
+		assert.ErrorIs(t, second.Acquire(timeoutCtx), ErrLockNotAcquired)
+		assert.NoError(t, first.Release(ctx))
+	})
+
+	t.Run("Should release held locks on shutdown", func(t *testing.T) {
+		first := NewLock("lock-shutdown", time.Minute)
+		second := NewLock("lock-shutdown", time.Minute)
+		assert.NoError(t, first.Acquire(ctx))
+
+		releaseHeldLeases()
+
+		ok, err := second.TryAcquire(ctx)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.NoError(t, second.Release(ctx))
+	})
+}
+
+func TestSemaphore(t *testing.T) {
+	useMemoryCacheDB(t)
+
+	ctx := context.Background()
+
+	t.Run("Should return a closed lost channel when no slot is held", func(t *testing.T) {
+		semaphore := NewSemaphore("semaphore-lost-not-held", 1, time.Second)
+		assert.True(t, isClosed(semaphore.Lost()))
+
+		ok, err := semaphore.TryAcquire(ctx)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		assert.False(t, isClosed(semaphore.Lost()))
+
+		assert.NoError(t, semaphore.Release(ctx))
+		assert.True(t, isClosed(semaphore.Lost()))
+	})
+
+	t.Run("Should limit concurrent holders", func(t *testing.T) {
+		first := NewSemaphore("semaphore-test", 2, time.Second)
+		second := NewSemaphore("semaphore-test", 2, time.Second)
+		third := NewSemaphore("semaphore-test", 2, time.Second)
+
+		ok, err := first.TryAcquire(ctx)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = second.TryAcquire(ctx)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = third.TryAcquire(ctx)
+		assert.NoError(t, err)
+		assert.False(t, ok)
+
+		assert.NoError(t, first.Release(ctx))
+
+		ok, err = third.TryAcquire(ctx)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+
+		assert.NoError(t, second.Release(ctx))
+		assert.NoError(t, third.Release(ctx))
+	})
+}
@@ -0,0 +1,135 @@
+package cacheDB
+
+import (
+	"context"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/monitoring"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	cacheTransactionMsg string = "Cache"
+
+	cacheOperationGet        string = "get"
+	cacheOperationSet        string = "set"
+	cacheOperationDel        string = "del"
+	cacheOperationInvalidate string = "invalidate"
+
+	cacheOutcomeHit   string = "hit"
+	cacheOutcomeMiss  string = "miss"
+	cacheOutcomeSet   string = "set"
+	cacheOutcomeEvict string = "evict"
+	cacheOutcomeError string = "error"
+
+	// cacheTaggedName is the cache label used by tag invalidations, which are not bound to a single cache.
+	cacheTaggedName string = "tagged"
+)
+
+var (
+	cacheHits = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "colibri",
+		Subsystem: "cache",
+		Name:      "hits_total",
+		Help:      "Number of cache reads that found a value.",
+	}, []string{"cache"})
+
+	cacheMisses = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "colibri",
+		Subsystem: "cache",
+		Name:      "misses_total",
+		Help:      "Number of cache reads that found no value.",
+	}, []string{"cache"})
+
+	cacheErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "colibri",
+		Subsystem: "cache",
+		Name:      "errors_total",
+		Help:      "Number of cache operations that failed.",
+	}, []string{"cache", "operation"})
+
+	cacheSets = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "colibri",
+		Subsystem: "cache",
+		Name:      "sets_total",
+		Help:      "Number of values saved in the cache.",
+	}, []string{"cache"})
+
+	cacheEvictions = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "colibri",
+		Subsystem: "cache",
+		Name:      "evictions_total",
+		Help:      "Number of values explicitly removed from the cache.",
+	}, []string{"cache"})
+
+	cacheOperationDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "colibri",
+		Subsystem: "cache",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of cache operations.",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"cache", "operation"})
+)
+
+// cacheObservation holds the metrics and monitoring segment of a single cache operation.
+type cacheObservation struct {
+	name      string
+	operation string
+	start     time.Time
+	segment   any
+}
+
+// startCacheObservation starts measuring a cache operation, opening a monitoring segment when there is a transaction in context.
+//
+// ctx: The context for the cache operation.
+// name: The name of the cache.
+// operation: The cache operation being measured.
+// Returns a pointer to cacheObservation.
+func startCacheObservation(ctx context.Context, name, operation string) *cacheObservation {
+	o := &cacheObservation{name: name, operation: operation, start: time.Now()}
+
+	if txn := monitoring.GetTransactionInContext(ctx); txn != nil {
+		o.segment = monitoring.StartTransactionSegment(ctx, cacheTransactionMsg, map[string]string{
+			"cache":     name,
+			"operation": operation,
+		})
+	}
+
+	return o
+}
+
+// end records the outcome of the cache operation and closes its monitoring segment.
+//
+// outcome: The outcome of the operation.
+// err: The error of the operation, if any.
+func (o *cacheObservation) end(outcome string, err error) {
+	cacheOperationDuration.WithLabelValues(o.name, o.operation).Observe(time.Since(o.start).Seconds())
+
+	if err != nil {
+		outcome = cacheOutcomeError
+	}
+
+	switch outcome {
+	case cacheOutcomeHit:
+		cacheHits.WithLabelValues(o.name).Inc()
+	case cacheOutcomeMiss:
+		cacheMisses.WithLabelValues(o.name).Inc()
+	case cacheOutcomeSet:
+		cacheSets.WithLabelValues(o.name).Inc()
+	case cacheOutcomeEvict:
+		cacheEvictions.WithLabelValues(o.name).Inc()
+	case cacheOutcomeError:
+		cacheErrors.WithLabelValues(o.name, o.operation).Inc()
+	}
+
+	if o.segment == nil {
+		return
+	}
+
+	monitoring.AddTransactionAttribute(o.segment, "outcome", outcome)
+	if err != nil {
+		monitoring.NoticeError(o.segment, err)
+	}
+	monitoring.EndTransactionSegment(o.segment)
+}
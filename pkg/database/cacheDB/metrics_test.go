@@ -0,0 +1,42 @@
+package cacheDB
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCacheMetrics(t *testing.T) {
+	useMemoryCacheDB(t)
+
+	ctx := context.Background()
+	cache := NewCache[userCached]("cache-metrics-test", time.Hour)
+
+	t.Run("Should count misses, sets, hits and evictions by cache name", func(t *testing.T) {
+		_, missErr := cache.One(ctx)
+		setErr := cache.Set(ctx, userCached{Id: 1, Name: "User 1"})
+		_, hitErr := cache.One(ctx)
+		delErr := cache.Del(ctx)
+
+		assert.NoError(t, missErr)
+		assert.NoError(t, setErr)
+		assert.NoError(t, hitErr)
+		assert.NoError(t, delErr)
+		assert.Equal(t, float64(1), testutil.ToFloat64(cacheMisses.WithLabelValues("cache-metrics-test")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(cacheSets.WithLabelValues("cache-metrics-test")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(cacheHits.WithLabelValues("cache-metrics-test")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(cacheEvictions.WithLabelValues("cache-metrics-test")))
+	})
+
+	t.Run("Should count evictions by tag invalidation", func(t *testing.T) {
+		before := testutil.ToFloat64(cacheEvictions.WithLabelValues(cacheTaggedName))
+		assert.NoError(t, cache.Set(ctx, userCached{Id: 1, Name: "User 1"}, "metrics:1"))
+
+		assert.NoError(t, InvalidateTags(ctx, "metrics:1"))
+
+		assert.Equal(t, before+1, testutil.ToFloat64(cacheEvictions.WithLabelValues(cacheTaggedName)))
+	})
+}
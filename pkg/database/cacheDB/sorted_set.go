@@ -0,0 +1,255 @@
+package cacheDB
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// ScoredMember is a member of a SortedSet with its score
+type ScoredMember[T any] struct {
+	Member T
+	Score  float64
+}
+
+// SortedSet struct
+type SortedSet[T any] struct {
+	name string
+	ttl  time.Duration
+}
+
+// NewSortedSet creates a new pointer to SortedSet struct.
+//
+// Parameters:
+// - name: a string representing the name of the sorted set.
+// - ttl: a time.Duration representing the time to live for the whole set, refreshed on every write. Zero means no expiration.
+// Returns a pointer to SortedSet[T].
+func NewSortedSet[T any](name string, ttl time.Duration) *SortedSet[T] {
+	return &SortedSet[T]{name, ttl}
+}
+
+// Add adds a member with the given score, replacing the score of an existing member.
+//
+// ctx: The context for the sorted set operation.
+// score: The score of the member.
+// member: The member to add.
+// Returns an error.
+func (s *SortedSet[T]) Add(ctx context.Context, score float64, member T) error {
+	if err := validateKey(s.name); err != nil {
+		return err
+	}
+
+	jsonMember, err := json.Marshal(member)
+	if err != nil {
+		return err
+	}
+
+	key := getKeyPrefixed(s.name)
+	_, err = instance.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		pipe.ZAdd(ctx, key, redis.Z{Score: score, Member: string(jsonMember)})
+		s.expire(ctx, pipe, key)
+		return nil
+	})
+
+	return err
+}
+
+// Incr atomically increments the score of a member, adding it when it does not exist.
+//
+// ctx: The context for the sorted set operation.
+// member: The member to increment.
+// delta: The value to add to the score.
+// Returns the new score and an error.
+func (s *SortedSet[T]) Incr(ctx context.Context, member T, delta float64) (float64, error) {
+	if err := validateKey(s.name); err != nil {
+		return 0, err
+	}
+
+	jsonMember, err := json.Marshal(member)
+	if err != nil {
+		return 0, err
+	}
+
+	var score *redis.FloatCmd
+	key := getKeyPrefixed(s.name)
+	if _, err = instance.TxPipelined(ctx, func(pipe redis.Pipeliner) error {
+		score = pipe.ZIncrBy(ctx, key, delta, string(jsonMember))
+		s.expire(ctx, pipe, key)
+		return nil
+	}); err != nil {
+		return 0, err
+	}
+
+	return score.Val(), nil
+}
+
+// Score retrieves the score of a member.
+//
+// ctx: The context for the sorted set operation.
+// member: The member to look up.
+// Returns a pointer to the score, or nil when the member does not exist, and an error.
+func (s *SortedSet[T]) Score(ctx context.Context, member T) (*float64, error) {
+	if err := validateKey(s.name); err != nil {
+		return nil, err
+	}
+
+	jsonMember, err := json.Marshal(member)
+	if err != nil {
+		return nil, err
+	}
+
+	score, err := instance.ZScore(ctx, getKeyPrefixed(s.name), string(jsonMember)).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &score, nil
+}
+
+// Rank retrieves the position of a member ordered by ascending score, or descending when reverse is true.
+//
+// ctx: The context for the sorted set operation.
+// member: The member to look up.
+// reverse: Whether to rank by descending score.
+// Returns a pointer to the zero-based rank, or nil when the member does not exist, and an error.
+func (s *SortedSet[T]) Rank(ctx context.Context, member T, reverse bool) (*int64, error) {
+	if err := validateKey(s.name); err != nil {
+		return nil, err
+	}
+
+	jsonMember, err := json.Marshal(member)
+	if err != nil {
+		return nil, err
+	}
+
+	var cmd *redis.IntCmd
+	if reverse {
+		cmd = instance.ZRevRank(ctx, getKeyPrefixed(s.name), string(jsonMember))
+	} else {
+		cmd = instance.ZRank(ctx, getKeyPrefixed(s.name), string(jsonMember))
+	}
+
+	rank, err := cmd.Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	return &rank, nil
+}
+
+// Range retrieves the members between the start and stop positions, ordered by ascending score or descending when reverse is true.
+//
+// ctx: The context for the sorted set operation.
+// start: The zero-based start position, negative values count from the end.
+// stop: The zero-based inclusive stop position, negative values count from the end.
+// reverse: Whether to order by descending score.
+// Returns a slice of ScoredMember[T] and an error.
+func (s *SortedSet[T]) Range(ctx context.Context, start, stop int64, reverse bool) ([]ScoredMember[T], error) {
+	if err := validateKey(s.name); err != nil {
+		return nil, err
+	}
+
+	result, err := instance.ZRangeArgsWithScores(ctx, redis.ZRangeArgs{
+		Key:   getKeyPrefixed(s.name),
+		Start: start,
+		Stop:  stop,
+		Rev:   reverse,
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeScoredMembers[T](result)
+}
+
+// RangeByScore retrieves the members with score between min and max, inclusive, ordered by ascending score.
+//
+// ctx: The context for the sorted set operation.
+// min: The minimum score.
+// max: The maximum score.
+// Returns a slice of ScoredMember[T] and an error.
+func (s *SortedSet[T]) RangeByScore(ctx context.Context, min, max float64) ([]ScoredMember[T], error) {
+	if err := validateKey(s.name); err != nil {
+		return nil, err
+	}
+
+	result, err := instance.ZRangeByScoreWithScores(ctx, getKeyPrefixed(s.name), &redis.ZRangeBy{
+		Min: strconv.FormatFloat(min, 'f', -1, 64),
+		Max: strconv.FormatFloat(max, 'f', -1, 64),
+	}).Result()
+	if err != nil {
+		return nil, err
+	}
+
+	return decodeScoredMembers[T](result)
+}
+
+// Remove removes members from the sorted set.
+//
+// ctx: The context for the sorted set operation.
+// members: The members to remove.
+// Returns an error.
+func (s *SortedSet[T]) Remove(ctx context.Context, members ...T) error {
+	if err := validateKey(s.name); err != nil {
+		return err
+	}
+
+	jsonMembers := make([]any, 0, len(members))
+	for _, member := range members {
+		jsonMember, err := json.Marshal(member)
+		if err != nil {
+			return err
+		}
+		jsonMembers = append(jsonMembers, string(jsonMember))
+	}
+
+	return instance.ZRem(ctx, getKeyPrefixed(s.name), jsonMembers...).Err()
+}
+
+// Count returns the number of members in the sorted set.
+//
+// ctx: The context for the sorted set operation.
+// Returns the number of members and an error.
+func (s *SortedSet[T]) Count(ctx context.Context) (int64, error) {
+	if err := validateKey(s.name); err != nil {
+		return 0, err
+	}
+
+	return instance.ZCard(ctx, getKeyPrefixed(s.name)).Result()
+}
+
+// expire refreshes the ttl of the sorted set inside a pipeline when the set has a ttl.
+//
+// ctx: The context for the sorted set operation.
+// pipe: The pipeline to add the command to.
+// key: The prefixed key of the sorted set.
+func (s *SortedSet[T]) expire(ctx context.Context, pipe redis.Pipeliner, key string) {
+	if s.ttl > 0 {
+		pipe.PExpire(ctx, key, s.ttl)
+	}
+}
+
+// decodeScoredMembers transforms redis scored members into ScoredMember[T].
+//
+// result: The redis scored members.
+// Returns a slice of ScoredMember[T] and an error.
+func decodeScoredMembers[T any](result []redis.Z) ([]ScoredMember[T], error) {
+	members := make([]ScoredMember[T], 0, len(result))
+	for _, z := range result {
+		var member T
+		if err := json.Unmarshal([]byte(z.Member.(string)), &member); err != nil {
+			return nil, err
+		}
+		members = append(members, ScoredMember[T]{Member: member, Score: z.Score})
+	}
+
+	return members, nil
+}
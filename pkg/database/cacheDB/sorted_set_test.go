@@ -0,0 +1,62 @@
+package cacheDB
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSortedSet(t *testing.T) {
+	useMemoryCacheDB(t)
+
+	ctx := context.Background()
+	leaderboard := NewSortedSet[string]("sorted-set-test", time.Hour)
+
+	assert.NoError(t, leaderboard.Add(ctx, 10, "alice"))
+	assert.NoError(t, leaderboard.Add(ctx, 30, "bob"))
+	assert.NoError(t, leaderboard.Add(ctx, 20, "carol"))
+
+	t.Run("Should range members by position", func(t *testing.T) {
+		result, err := leaderboard.Range(ctx, 0, 1, true)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []ScoredMember[string]{{"bob", 30}, {"carol", 20}}, result)
+	})
+
+	t.Run("Should range members by score", func(t *testing.T) {
+		result, err := leaderboard.RangeByScore(ctx, 15, 30)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []ScoredMember[string]{{"carol", 20}, {"bob", 30}}, result)
+	})
+
+	t.Run("Should increment score and rank member", func(t *testing.T) {
+		score, err := leaderboard.Incr(ctx, "alice", 25)
+		rank, rankErr := leaderboard.Rank(ctx, "alice", true)
+
+		assert.NoError(t, err)
+		assert.Equal(t, float64(35), score)
+		assert.NoError(t, rankErr)
+		assert.Equal(t, int64(0), *rank)
+	})
+
+	t.Run("Should return nil score and rank when member does not exist", func(t *testing.T) {
+		score, err := leaderboard.Score(ctx, "dave")
+		rank, rankErr := leaderboard.Rank(ctx, "dave", false)
+
+		assert.NoError(t, err)
+		assert.Nil(t, score)
+		assert.NoError(t, rankErr)
+		assert.Nil(t, rank)
+	})
+
+	t.Run("Should remove members", func(t *testing.T) {
+		assert.NoError(t, leaderboard.Remove(ctx, "alice", "bob"))
+		count, err := leaderboard.Count(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), count)
+	})
+}
@@ -0,0 +1,152 @@
+package cacheDB
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	streamPayloadField string = "payload"
+	errStreamBusyGroup string = "BUSYGROUP"
+)
+
+// StreamMessage is a message read from a Stream.
+// Err is set when the payload could not be decoded; the message stays pending until it is acknowledged.
+type StreamMessage[T any] struct {
+	ID      string
+	Payload T
+	Err     error
+}
+
+// Stream struct
+type Stream[T any] struct {
+	name   string
+	maxLen int64
+}
+
+// NewStream creates a new pointer to Stream struct.
+//
+// Parameters:
+// - name: a string representing the name of the stream.
+// - maxLen: an int64 representing the approximate maximum length of the stream. Zero means unbounded.
+// Returns a pointer to Stream[T].
+func NewStream[T any](name string, maxLen int64) *Stream[T] {
+	return &Stream[T]{name, maxLen}
+}
+
+// Add appends a message to the stream.
+//
+// ctx: The context for the stream operation.
+// data: The data to be appended.
+// Returns the id of the new message and an error.
+func (s *Stream[T]) Add(ctx context.Context, data T) (string, error) {
+	if err := validateKey(s.name); err != nil {
+		return "", err
+	}
+
+	jsonData, err := json.Marshal(data)
+	if err != nil {
+		return "", err
+	}
+
+	return instance.XAdd(ctx, &redis.XAddArgs{
+		Stream: getKeyPrefixed(s.name),
+		MaxLen: s.maxLen,
+		Approx: s.maxLen > 0,
+		Values: map[string]any{streamPayloadField: jsonData},
+	}).Result()
+}
+
+// CreateGroup creates a consumer group reading new messages, creating the stream when it does not exist.
+// Creating a group that already exists is not an error.
+//
+// ctx: The context for the stream operation.
+// group: The name of the consumer group.
+// Returns an error.
+func (s *Stream[T]) CreateGroup(ctx context.Context, group string) error {
+	if err := validateKey(s.name); err != nil {
+		return err
+	}
+
+	err := instance.XGroupCreateMkStream(ctx, getKeyPrefixed(s.name), group, "$").Err()
+	if err != nil && strings.HasPrefix(err.Error(), errStreamBusyGroup) {
+		return nil
+	}
+
+	return err
+}
+
+// Read reads new messages for a consumer of a group, waiting up to block for messages to arrive.
+//
+// ctx: The context for the stream operation.
+// group: The name of the consumer group.
+// consumer: The name of the consumer inside the group.
+// count: The maximum number of messages to read.
+// block: The maximum time to wait for messages, zero returns immediately.
+// Returns a slice of StreamMessage[T], including the messages whose payload could not be decoded, and an error.
+func (s *Stream[T]) Read(ctx context.Context, group, consumer string, count int64, block time.Duration) ([]StreamMessage[T], error) {
+	if err := validateKey(s.name); err != nil {
+		return nil, err
+	}
+
+	if block == 0 {
+		block = -1
+	}
+
+	result, err := instance.XReadGroup(ctx, &redis.XReadGroupArgs{
+		Group:    group,
+		Consumer: consumer,
+		Streams:  []string{getKeyPrefixed(s.name), ">"},
+		Count:    count,
+		Block:    block,
+	}).Result()
+	if errors.Is(err, redis.Nil) {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+
+	messages := make([]StreamMessage[T], 0)
+	for _, stream := range result {
+		for _, msg := range stream.Messages {
+			message := StreamMessage[T]{ID: msg.ID}
+			if raw, ok := msg.Values[streamPayloadField].(string); ok {
+				message.Err = json.Unmarshal([]byte(raw), &message.Payload)
+			}
+			messages = append(messages, message)
+		}
+	}
+
+	return messages, nil
+}
+
+// Ack acknowledges messages processed by a consumer group.
+//
+// ctx: The context for the stream operation.
+// group: The name of the consumer group.
+// ids: The ids of the processed messages.
+// Returns an error.
+func (s *Stream[T]) Ack(ctx context.Context, group string, ids ...string) error {
+	if err := validateKey(s.name); err != nil {
+		return err
+	}
+
+	return instance.XAck(ctx, getKeyPrefixed(s.name), group, ids...).Err()
+}
+
+// Len returns the number of messages in the stream.
+//
+// ctx: The context for the stream operation.
+// Returns the number of messages and an error.
+func (s *Stream[T]) Len(ctx context.Context) (int64, error) {
+	if err := validateKey(s.name); err != nil {
+		return 0, err
+	}
+
+	return instance.XLen(ctx, getKeyPrefixed(s.name)).Result()
+}
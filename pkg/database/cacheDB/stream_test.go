@@ -0,0 +1,67 @@
+package cacheDB
+
+import (
+	"context"
+	"testing"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStream(t *testing.T) {
+	useMemoryCacheDB(t)
+
+	ctx := context.Background()
+	stream := NewStream[userCached]("stream-test", 100)
+
+	t.Run("Should create consumer group twice without error", func(t *testing.T) {
+		assert.NoError(t, stream.CreateGroup(ctx, "group-test"))
+		assert.NoError(t, stream.CreateGroup(ctx, "group-test"))
+	})
+
+	t.Run("Should add, read and ack messages", func(t *testing.T) {
+		id, err := stream.Add(ctx, userCached{Id: 1, Name: "User 1"})
+		assert.NoError(t, err)
+		assert.NotEmpty(t, id)
+
+		messages, err := stream.Read(ctx, "group-test", "consumer-1", 10, 0)
+		assert.NoError(t, err)
+		assert.Len(t, messages, 1)
+		assert.Equal(t, id, messages[0].ID)
+		assert.Equal(t, userCached{Id: 1, Name: "User 1"}, messages[0].Payload)
+
+		assert.NoError(t, stream.Ack(ctx, "group-test", id))
+
+		messages, err = stream.Read(ctx, "group-test", "consumer-1", 10, 0)
+		assert.NoError(t, err)
+		assert.Empty(t, messages)
+	})
+
+	t.Run("Should return stream length", func(t *testing.T) {
+		length, err := stream.Len(ctx)
+
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), length)
+	})
+
+	t.Run("Should return the decoded messages and the decode error of each invalid message", func(t *testing.T) {
+		invalidID, err := instance.XAdd(ctx, &redis.XAddArgs{
+			Stream: getKeyPrefixed("stream-test"),
+			Values: map[string]any{streamPayloadField: "invalid"},
+		}).Result()
+		assert.NoError(t, err)
+		validID, err := stream.Add(ctx, userCached{Id: 2, Name: "User 2"})
+		assert.NoError(t, err)
+
+		messages, err := stream.Read(ctx, "group-test", "consumer-1", 10, 0)
+
+		assert.NoError(t, err)
+		assert.Len(t, messages, 2)
+		assert.Equal(t, invalidID, messages[0].ID)
+		assert.Error(t, messages[0].Err)
+		assert.Equal(t, validID, messages[1].ID)
+		assert.NoError(t, messages[1].Err)
+		assert.Equal(t, userCached{Id: 2, Name: "User 2"}, messages[1].Payload)
+		assert.NoError(t, stream.Ack(ctx, "group-test", invalidID, validID))
+	})
+}
@@ -0,0 +1,121 @@
+package cacheDB
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
+	"github.com/redis/go-redis/v9"
+)
+
+// invalidateTagsBatchSize is the number of tagged keys deleted by each pipeline when invalidating a tag
+const invalidateTagsBatchSize = 100
+
+// tagKeyScript adds the cache key to a tag set, keeping the tag set alive at least as long as the key.
+// It touches only the tag set, so it runs on any Redis Cluster node owning the tag.
+var tagKeyScript = redis.NewScript(`
+local existed = redis.call("EXISTS", KEYS[1])
+redis.call("SADD", KEYS[1], ARGV[1])
+if ARGV[2] == "0" then
+	redis.call("PERSIST", KEYS[1])
+else
+	local ttl = redis.call("PTTL", KEYS[1])
+	if existed == 0 or (ttl >= 0 and ttl < tonumber(ARGV[2])) then
+		redis.call("PEXPIRE", KEYS[1], ARGV[2])
+	end
+end
+return 1`)
+
+// InvalidateTags deletes every cached item carrying at least one of the given tags.
+//
+// ctx: The context for the cache operation.
+// tags: the tags to invalidate.
+// Returns an error.
+func InvalidateTags(ctx context.Context, tags ...string) error {
+	if instance == nil {
+		return errors.New("cache not initialized")
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+
+	observation := startCacheObservation(ctx, cacheTaggedName, cacheOperationInvalidate)
+	deleted, err := invalidateTagKeys(ctx, getTagKeys(tags))
+	if err == nil {
+		cacheEvictions.WithLabelValues(cacheTaggedName).Add(float64(deleted))
+	}
+	observation.end("", err)
+
+	return err
+}
+
+// invalidateTagKeys deletes every key referenced by the tag sets with single-key commands, so the keys may live on
+// any Redis Cluster slot. Keys are removed from the tag set before being deleted, so keys tagged meanwhile stay tracked.
+//
+// ctx: The context for the cache operation.
+// tagKeys: the prefixed keys of the tag sets.
+// Returns the number of deleted keys and an error.
+func invalidateTagKeys(ctx context.Context, tagKeys []string) (int64, error) {
+	var deleted int64
+	for _, tagKey := range tagKeys {
+		keys, err := instance.SMembers(ctx, tagKey).Result()
+		if err != nil {
+			return deleted, err
+		}
+
+		for batch := range slices.Chunk(keys, invalidateTagsBatchSize) {
+			members := make([]any, 0, len(batch))
+			for _, key := range batch {
+				members = append(members, key)
+			}
+
+			cmds, err := instance.Pipelined(ctx, func(pipe redis.Pipeliner) error {
+				pipe.SRem(ctx, tagKey, members...)
+				for _, key := range batch {
+					pipe.Del(ctx, key)
+				}
+				return nil
+			})
+			if err != nil {
+				return deleted, err
+			}
+
+			for _, cmd := range cmds[1:] {
+				deleted += cmd.(*redis.IntCmd).Val()
+			}
+		}
+	}
+
+	return deleted, nil
+}
+
+// tag attaches the cache key to the given tags.
+//
+// ctx: The context for the cache operation.
+// tags: the tags to attach.
+// Returns an error.
+func (c *Cache[T]) tag(ctx context.Context, tags []string) error {
+	for _, tagKey := range getTagKeys(tags) {
+		if err := tagKeyScript.Run(ctx, instance, []string{tagKey}, c.getNamePrefixed(), c.ttl.Milliseconds()).Err(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// getTagKeys returns the prefixed keys of the sets tracking each tag.
+//
+// tags: the tags to prefix.
+// Returns a slice of string.
+func getTagKeys(tags []string) []string {
+	keys := make([]string, 0, len(tags))
+	for _, tag := range tags {
+		keys = append(keys, fmt.Sprintf("%s::tag::%s", config.APP_NAME, tag))
+	}
+
+	return keys
+}
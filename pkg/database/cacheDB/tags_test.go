@@ -0,0 +1,91 @@
+package cacheDB
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInvalidateTags(t *testing.T) {
+	useMemoryCacheDB(t)
+
+	ctx := context.Background()
+	expected := userCached{Id: 1, Name: "User 1"}
+
+	t.Run("Should delete every item carrying the tag", func(t *testing.T) {
+		first := NewCache[userCached]("cache-tag-first", time.Hour).WithTags("tenant:1")
+		second := NewCache[userCached]("cache-tag-second", time.Hour)
+		other := NewCache[userCached]("cache-tag-other", time.Hour).WithTags("tenant:2")
+
+		assert.NoError(t, first.Set(ctx, expected))
+		assert.NoError(t, second.Set(ctx, expected, "tenant:1", "order:1"))
+		assert.NoError(t, other.Set(ctx, expected))
+
+		assert.NoError(t, InvalidateTags(ctx, "tenant:1"))
+
+		firstResult, firstErr := first.One(ctx)
+		secondResult, secondErr := second.One(ctx)
+		otherResult, otherErr := other.One(ctx)
+
+		assert.NoError(t, firstErr)
+		assert.Nil(t, firstResult)
+		assert.NoError(t, secondErr)
+		assert.Nil(t, secondResult)
+		assert.NoError(t, otherErr)
+		assert.Equal(t, expected, *otherResult)
+	})
+
+	t.Run("Should keep tag set alive while tagged items live", func(t *testing.T) {
+		short := NewCache[userCached]("cache-tag-short", 100*time.Millisecond).WithTags("tenant:3")
+		long := NewCache[userCached]("cache-tag-long", time.Hour).WithTags("tenant:3")
+
+		assert.NoError(t, long.Set(ctx, expected))
+		assert.NoError(t, short.Set(ctx, expected))
+		time.Sleep(300 * time.Millisecond)
+
+		assert.NoError(t, InvalidateTags(ctx, "tenant:3"))
+		result, err := long.One(ctx)
+
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+	})
+
+	t.Run("Should not change the cache it was tagged from", func(t *testing.T) {
+		shared := NewCache[userCached]("cache-tag-shared", time.Hour)
+		tenant := shared.WithTags("tenant:4")
+		order := tenant.WithTags("order:4")
+
+		assert.NoError(t, shared.Set(ctx, expected))
+		assert.NoError(t, InvalidateTags(ctx, "tenant:4"))
+		sharedResult, sharedErr := shared.One(ctx)
+
+		assert.NoError(t, sharedErr)
+		assert.Equal(t, expected, *sharedResult)
+		assert.Empty(t, shared.tags)
+		assert.Equal(t, []string{"tenant:4"}, tenant.tags)
+		assert.Equal(t, []string{"tenant:4", "order:4"}, order.tags)
+	})
+
+	t.Run("Should delete every tagged item in batches and remove the tag set", func(t *testing.T) {
+		for i := range invalidateTagsBatchSize + 1 {
+			cache := NewCache[userCached](fmt.Sprintf("cache-tag-batch-%d", i), time.Hour).WithTags("tenant:5")
+			assert.NoError(t, cache.Set(ctx, expected))
+		}
+
+		assert.NoError(t, InvalidateTags(ctx, "tenant:5"))
+		result, err := NewCache[userCached]("cache-tag-batch-0", time.Hour).One(ctx)
+		exists, existsErr := instance.Exists(ctx, getTagKeys([]string{"tenant:5"})...).Result()
+
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+		assert.NoError(t, existsErr)
+		assert.Zero(t, exists)
+	})
+
+	t.Run("Should do nothing without tags", func(t *testing.T) {
+		assert.NoError(t, InvalidateTags(ctx))
+	})
+}
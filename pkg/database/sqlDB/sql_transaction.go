@@ -4,6 +4,7 @@ import (
 	"context"
 	"database/sql"
 	"fmt"
+	"sync"
 
 	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
 	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/transaction"
@@ -15,6 +16,8 @@ type SqlTxContextKey string
 const (
 	SqlTxContext SqlTxContextKey = "SqlTxContext"
 
+	sqlTxAfterCommitContext SqlTxContextKey = "SqlTxAfterCommitContext"
+
 	transactionIsolationWarnMsg string = "transaction isolation just use first parameter, others will be ignored"
 	transactionRollbackErrorMsg string = "error when executing transaction rollback: %v: %w"
 	transactionCommitErrorMsg   string = "could not commit transaction: %w"
@@ -63,7 +66,9 @@ func (t *sqlTransaction) ExecuteInInstance(ctx context.Context, instance *sql.DB
 	}
 	defer close(transactionChannel)
 
+	hooks := &afterCommitHooks{}
 	ctx = context.WithValue(ctx, SqlTxContext, tx)
+	ctx = context.WithValue(ctx, sqlTxAfterCommitContext, hooks)
 
 	if err = fn(ctx); err != nil {
 		if rbErr := tx.Rollback(); rbErr != nil {
@@ -85,9 +90,39 @@ func (t *sqlTransaction) ExecuteInInstance(ctx context.Context, instance *sql.DB
 		return fErr
 	}
 
+	hooks.run()
 	return nil
 }
 
+// afterCommitHooks holds the functions executed after a transaction is committed.
+type afterCommitHooks struct {
+	mu  sync.Mutex
+	fns []func()
+}
+
+// add registers a function to be executed after the commit.
+//
+// fn: the function to be executed.
+func (h *afterCommitHooks) add(fn func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.fns = append(h.fns, fn)
+}
+
+// run executes the registered functions in registration order.
+//
+// No parameters.
+// No return values.
+func (h *afterCommitHooks) run() {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for _, fn := range h.fns {
+		fn()
+	}
+}
+
 // beginTransaction starts a new database transaction.
 //
 // ctx: The context for the transaction.
@@ -4,13 +4,19 @@ import (
 	"context"
 	"database/sql"
 	"errors"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/database/cacheDB"
 )
 
+const invalidateCacheTagsError string = "could not invalidate cache tags %v"
+
 // Statement is a struct for sql statement
 type Statement struct {
 	ctx   context.Context
 	query string
 	args  []any
+	tags  []string
 }
 
 // NewStatement creates a new pointer to Statement struct.
@@ -20,7 +26,17 @@ type Statement struct {
 // params: variadic any for additional parameters
 // Returns a pointer to Statement struct
 func NewStatement(ctx context.Context, query string, params ...any) *Statement {
-	return &Statement{ctx, query, params}
+	return &Statement{ctx: ctx, query: query, args: params}
+}
+
+// InvalidateTags declares the cache tags invalidated by this statement.
+//
+// The tags are invalidated after the statement is executed or, inside a transaction, after it is committed.
+// tags: the cacheDB tags to invalidate.
+// Returns the same pointer to Statement struct.
+func (s *Statement) InvalidateTags(tags ...string) *Statement {
+	s.tags = append(s.tags, tags...)
+	return s
 }
 
 // Execute applies the statement in the database.
@@ -50,9 +66,33 @@ func (s *Statement) ExecuteInInstance(instance *sql.DB) error {
 		return err
 	}
 
+	s.invalidateCacheTags()
 	return nil
 }
 
+// invalidateCacheTags invalidates the declared cache tags now or, inside a transaction, after the commit.
+//
+// No parameters.
+// No return values.
+func (s *Statement) invalidateCacheTags() {
+	if len(s.tags) == 0 {
+		return
+	}
+
+	invalidate := func() {
+		if err := cacheDB.InvalidateTags(s.ctx, s.tags...); err != nil {
+			logging.Error(s.ctx).Err(err).Msgf(invalidateCacheTagsError, s.tags)
+		}
+	}
+
+	if hooks, ok := s.ctx.Value(sqlTxAfterCommitContext).(*afterCommitHooks); ok {
+		hooks.add(invalidate)
+		return
+	}
+
+	invalidate()
+}
+
 // validate checks if the Statement instance is initialized and if the query is empty.
 //
 // No parameters.
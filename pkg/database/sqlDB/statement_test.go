@@ -5,6 +5,8 @@ import (
 	"testing"
 	"time"
 
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/test"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/database/cacheDB"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -47,4 +49,22 @@ func TestStatement(t *testing.T) {
 		assert.Equal(t, user.Birthday.Local(), result.Birthday.Local())
 		assert.Equal(t, user.Profile, result.Profile)
 	})
+	t.Run("Should invalidate cache tags after execute statement", func(t *testing.T) {
+		test.InitializeMemoryCacheDBTest()
+		cacheDB.Initialize()
+		cache := cacheDB.NewCache[User]("statement-user-124", time.Hour).WithTags("user:124")
+		birth, _ := time.Parse("2006-01-02", "2021-11-22")
+		user := User{124, "Usuário teste stmt tags", birth, Profile{100, "ADMIN"}}
+
+		setErr := cache.Set(ctx, user)
+		statementErr := NewStatement(ctx, "INSERT INTO users VALUES ($1, $2, $3, $4)", user.Id, user.Name, user.Birthday, user.Profile.Id).
+			InvalidateTags("user:124").
+			Execute()
+		result, err := cache.One(ctx)
+
+		assert.NoError(t, setErr)
+		assert.NoError(t, statementErr)
+		assert.NoError(t, err)
+		assert.Nil(t, result)
+	})
 }
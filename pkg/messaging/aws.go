@@ -3,9 +3,18 @@ package messaging
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"maps"
+	"math"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
 	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/aws/arn"
+	"github.com/aws/aws-sdk-go/aws/awserr"
 	"github.com/aws/aws-sdk-go/service/sns"
 	"github.com/aws/aws-sdk-go/service/sqs"
 	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/cloud"
@@ -22,21 +31,178 @@ type sqsNotification struct {
 	Signature        string `json:"Signature"`
 	SigningCertURL   string `json:"SigningCertURL"`
 	UnsubscribeURL   string `json:"UnsubscribeURL"`
+	// MessageAttributes holds the SNS message attributes when raw message delivery is disabled
+	MessageAttributes map[string]sqsNotificationAttribute `json:"MessageAttributes"`
 }
 
+type sqsNotificationAttribute struct {
+	Type  string `json:"Type"`
+	Value string `json:"Value"`
+}
+
+const (
+	sqsDLQSuffix                = "_DLQ"
+	sqsMaxBatchSize             = 10
+	snsMaxBatchSize             = 10
+	sqsLongPollingSeconds       = 20
+	sqsMaxVisibilityTimeout     = 12 * time.Hour
+	sqsDefaultVisibilityTimeout = 30 * time.Second
+	sqsPollInitialBackoff       = time.Second
+	sqsPollMaxBackoff           = 30 * time.Second
+	sqsDeadLetterHold           = 5 * time.Minute
+	sqsDeadLetterWaitSeconds    = 1
+	sqsApproximateReceiveCount  = "ApproximateReceiveCount"
+	sqsStringAttributeDataType  = "String"
+	sqsNumberAttributeDataType  = "Number"
+	sqsMaxDelay                 = 15 * time.Minute
+	sqsMaxMessageAttributes     = 10
+	sqsFifoSuffix               = ".fifo"
+	snsSqsProtocol              = "sqs"
+	snsFilterPolicyAttribute    = "FilterPolicy"
+	snsSubscriptionsTTL         = time.Minute
+	couldNotChangeMsgVisibility = "could not change visibility of message %s from queue %s"
+)
+
+// errTooManyMessageAttributes is returned when a message needs more attributes than SNS and SQS accept
+var errTooManyMessageAttributes = fmt.Errorf("message needs more than %d attributes", sqsMaxMessageAttributes)
+
 type awsMessaging struct {
 	snsService *sns.SNS
 	sqsService *sqs.SQS
+	dlqUrls    sync.Map
+	// topicQueues caches the queues subscribed to each topic for snsSubscriptionsTTL
+	topicQueues sync.Map
+}
+
+// snsTopicQueues are the queues subscribed to a topic, or nil when the topic can not be delayed natively
+type snsTopicQueues struct {
+	urls    []*string
+	expires time.Time
+}
+
+type awsOriginalMessage struct {
+	m         *awsMessaging
+	queue     string
+	queueUrl  *sqs.GetQueueUrlOutput
+	msg       *sqs.Message
+	heartbeat *sqsHeartbeat
+}
+
+// sqsHeartbeat extends the visibility timeout of a message while it is processed
+type sqsHeartbeat struct {
+	mu      sync.Mutex
+	stopped bool
+	cancel  func()
+}
+
+// extend runs the visibility extension unless the heartbeat was stopped
+func (h *sqsHeartbeat) extend(fn func()) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.stopped {
+		fn()
+	}
 }
 
-type awsOriginalMessage struct{}
+// stop ends the heartbeat, waiting for an extension in progress so it does not override the visibility set afterward
+func (h *sqsHeartbeat) stop() {
+	if h == nil {
+		return
+	}
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !h.stopped {
+		h.stopped = true
+		h.cancel()
+	}
+}
 
+// Ack deletes the message from the queue.
 func (a awsOriginalMessage) Ack() error {
-	return nil
+	a.heartbeat.stop()
+	_, err := a.m.sqsService.DeleteMessage(&sqs.DeleteMessageInput{
+		QueueUrl:      a.queueUrl.QueueUrl,
+		ReceiptHandle: a.msg.ReceiptHandle,
+	})
+	if err != nil {
+		logging.Error(context.Background()).Err(err).Msgf(couldNotDeleteMsg, *a.msg.MessageId, *a.queueUrl.QueueUrl)
+	}
+
+	return err
 }
 
-func (a awsOriginalMessage) Nack(_ bool, _ error) error {
-	return nil
+// Nack makes the message visible again when requeue is true. Otherwise, it sends the message to the <queue>_DLQ queue
+// with the failure reason and attempt count as message attributes. When that queue does not exist, the message is made
+// visible again so the queue redrive policy moves it to its dead-letter queue.
+func (a awsOriginalMessage) Nack(requeue bool, err error) error {
+	a.heartbeat.stop()
+	if requeue {
+		return a.NackWithDelay(0, err)
+	}
+
+	dlqUrl, dlqErr := a.m.getDLQUrl(a.queue)
+	if dlqErr != nil {
+		logging.Error(context.Background()).Err(dlqErr).Msgf(couldNotSendToDLQ, *a.msg.MessageId)
+	}
+	if dlqUrl == nil {
+		return a.NackWithDelay(0, err)
+	}
+
+	if _, sendErr := a.m.sqsService.SendMessage(&sqs.SendMessageInput{
+		QueueUrl:          dlqUrl,
+		MessageBody:       a.msg.Body,
+		MessageAttributes: getSqsDeadLetterAttributes(a.msg, err),
+	}); sendErr != nil {
+		logging.Error(context.Background()).Err(sendErr).Msgf(couldNotSendToDLQ, *a.msg.MessageId)
+		return a.NackWithDelay(0, err)
+	}
+
+	logging.Debug(context.Background()).Msgf(messageSentToDLQ, *a.msg.MessageId, *dlqUrl, errorReason(err))
+	return a.Ack()
+}
+
+// getSqsDeadLetterAttributes returns the attributes of the message with the failure reason and attempt count on top.
+// The failure attributes are left out when the message already has as many attributes as SQS accepts.
+func getSqsDeadLetterAttributes(msg *sqs.Message, err error) map[string]*sqs.MessageAttributeValue {
+	attributes := maps.Clone(msg.MessageAttributes)
+	if attributes == nil {
+		attributes = make(map[string]*sqs.MessageAttributeValue, 2)
+	}
+
+	failure := []struct {
+		key       string
+		attribute *sqs.MessageAttributeValue
+	}{
+		{headerFailureReason, &sqs.MessageAttributeValue{DataType: aws.String(sqsStringAttributeDataType), StringValue: aws.String(errorReason(err))}},
+		{headerAttempts, &sqs.MessageAttributeValue{DataType: aws.String(sqsNumberAttributeDataType), StringValue: aws.String(strconv.Itoa(getSqsAttempt(msg)))}},
+	}
+	for _, f := range failure {
+		_, exists := attributes[f.key]
+		if aws.StringValue(f.attribute.StringValue) != "" && (exists || len(attributes) < sqsMaxMessageAttributes) {
+			attributes[f.key] = f.attribute
+		}
+	}
+
+	return attributes
+}
+
+// NackWithDelay changes the message visibility timeout so SQS delivers it again after the delay.
+func (a awsOriginalMessage) NackWithDelay(delay time.Duration, _ error) error {
+	a.heartbeat.stop()
+	delay = min(max(delay, 0), sqsMaxVisibilityTimeout)
+	_, err := a.m.sqsService.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+		QueueUrl:          a.queueUrl.QueueUrl,
+		ReceiptHandle:     a.msg.ReceiptHandle,
+		VisibilityTimeout: aws.Int64(int64(math.Ceil(delay.Seconds()))),
+	})
+	if err != nil {
+		logging.Error(context.Background()).Err(err).Msgf(couldNotChangeMsgVisibility, *a.msg.MessageId, *a.queueUrl.QueueUrl)
+	}
+
+	return err
 }
 
 func newAwsMessaging() *awsMessaging {
@@ -52,52 +218,181 @@ func newAwsMessaging() *awsMessaging {
 }
 
 func (m *awsMessaging) producer(ctx context.Context, p *Producer, msg *ProviderMessage) error {
-	_, err := m.snsService.PublishWithContext(ctx, &sns.PublishInput{
-		Message: aws.String(msg.String()),
-		TopicArn: aws.String(fmt.Sprintf("arn:%s:sns:%s:%s:%s",
-			cloud.GetAwsARN().Partition,
-			*cloud.GetAwsSession().Config.Region,
-			cloud.GetAwsARN().AccountID,
-			p.topic,
-		)),
-	})
+	body, attributes, err := encodeAwsMessage(msg)
+	if err != nil {
+		return err
+	}
 
+	input := &sns.PublishInput{
+		Message:           aws.String(string(body)),
+		MessageAttributes: getSnsAttributes(attributes),
+		TopicArn:          aws.String(getSnsTopicArn(p.topic)),
+	}
+	if strings.HasSuffix(p.topic, sqsFifoSuffix) {
+		input.MessageGroupId = aws.String(getSnsMessageGroupId(msg))
+		input.MessageDeduplicationId = aws.String(msg.ID.String())
+	}
+
+	_, err = m.snsService.PublishWithContext(ctx, input)
 	return err
 }
 
+// producerBatch publishes the messages with SNS PublishBatch, up to 10 messages per request
+func (m *awsMessaging) producerBatch(ctx context.Context, p *Producer, msgs []*ProviderMessage) []error {
+	errs := make([]error, len(msgs))
+	for start := 0; start < len(msgs); start += snsMaxBatchSize {
+		end := min(start+snsMaxBatchSize, len(msgs))
+		m.publishBatch(ctx, p.topic, msgs[start:end], errs[start:end])
+	}
+
+	return errs
+}
+
+// publishBatch publishes up to 10 messages in one request, setting the error of each message that failed
+func (m *awsMessaging) publishBatch(ctx context.Context, topic string, msgs []*ProviderMessage, errs []error) {
+	entries := make([]*sns.PublishBatchRequestEntry, 0, len(msgs))
+	positions := make(map[string]int, len(msgs))
+	for i, msg := range msgs {
+		body, attributes, err := encodeAwsMessage(msg)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		entry := &sns.PublishBatchRequestEntry{
+			Id:                aws.String(strconv.Itoa(i)),
+			Message:           aws.String(string(body)),
+			MessageAttributes: getSnsAttributes(attributes),
+		}
+		if strings.HasSuffix(topic, sqsFifoSuffix) {
+			entry.MessageGroupId = aws.String(getSnsMessageGroupId(msg))
+			entry.MessageDeduplicationId = aws.String(msg.ID.String())
+		}
+		entries = append(entries, entry)
+		positions[*entry.Id] = i
+	}
+
+	if len(entries) == 0 {
+		return
+	}
+
+	output, err := m.snsService.PublishBatchWithContext(ctx, &sns.PublishBatchInput{
+		PublishBatchRequestEntries: entries,
+		TopicArn:                   aws.String(getSnsTopicArn(topic)),
+	})
+	if err != nil {
+		for _, i := range positions {
+			errs[i] = err
+		}
+		return
+	}
+
+	for _, failed := range output.Failed {
+		errs[positions[aws.StringValue(failed.Id)]] = fmt.Errorf("%s: %s", aws.StringValue(failed.Code), aws.StringValue(failed.Message))
+	}
+}
+
+// producerWithDelay sends the message with DelaySeconds straight to the standard SQS queues subscribed to the topic,
+// since SNS does not delay messages. Delays above 15 minutes and topics with other subscriptions, filter policies
+// or FIFO queues are not supported natively.
+func (m *awsMessaging) producerWithDelay(ctx context.Context, p *Producer, msg *ProviderMessage, delay time.Duration) error {
+	if delay > sqsMaxDelay {
+		return errDelayNotSupported
+	}
+
+	queueUrls, err := m.getTopicQueueUrls(ctx, p.topic)
+	if err != nil {
+		return err
+	}
+	if queueUrls == nil {
+		return errDelayNotSupported
+	}
+
+	body, attributes, err := encodeAwsMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	sqsAttributes := make(map[string]*sqs.MessageAttributeValue, len(attributes))
+	for key, value := range attributes {
+		sqsAttributes[key] = &sqs.MessageAttributeValue{DataType: aws.String(sqsStringAttributeDataType), StringValue: aws.String(value)}
+	}
+
+	for _, queueUrl := range queueUrls {
+		if _, err = m.sqsService.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+			QueueUrl:          queueUrl,
+			MessageBody:       aws.String(string(body)),
+			MessageAttributes: sqsAttributes,
+			DelaySeconds:      aws.Int64(int64(math.Ceil(delay.Seconds()))),
+		}); err != nil {
+			// the subscriptions may have changed, so they are listed again on the next message
+			m.topicQueues.Delete(p.topic)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// consumer long polls the queue and hands the messages to the workers. The visibility timeout of each message is
+// extended while it is processed, and it is deleted only when the workers acknowledge it.
 func (m *awsMessaging) consumer(ctx context.Context, c *consumer) (chan *ProviderMessage, error) {
-	ch := make(chan *ProviderMessage, 1)
+	ch := make(chan *ProviderMessage, c.options.Prefetch)
 	queueUrl := m.getQueueUrl(ctx, c.queue)
+	visibility := m.getVisibilityTimeout(ctx, queueUrl)
 
+	readCtx, cancel := context.WithCancel(ctx)
 	go func() {
+		<-c.done
+		cancel()
+	}()
+
+	c.Add(1)
+	go func() {
+		defer c.Done()
+
+		backoff := sqsPollInitialBackoff
 		for {
 			if c.isCanceled() {
-				c.Done()
 				return
 			}
 
-			msgs, err := m.readMessages(ctx, queueUrl)
+			msgs, err := m.readMessages(readCtx, queueUrl, c.options.Prefetch)
 			if err != nil {
+				if readCtx.Err() != nil {
+					return
+				}
+
 				logging.Error(ctx).Err(err).Msgf(couldNotReceiveMsg, c.queue)
+				select {
+				case <-time.After(backoff):
+				case <-readCtx.Done():
+					return
+				}
+				backoff = min(backoff*2, sqsPollMaxBackoff)
+				continue
 			}
+			backoff = sqsPollInitialBackoff
 
-			if len(msgs.Messages) > 0 {
-				msg := msgs.Messages[0]
-
-				var n sqsNotification
-				if err = json.Unmarshal([]byte(*msg.Body), &n); err != nil {
-					logging.Error(ctx).Err(err).Msgf(couldNotReadMsgBody, *msg.MessageId, c.queue)
+			for i, msg := range msgs.Messages {
+				original := awsOriginalMessage{m: m, queue: c.queue, queueUrl: queueUrl, msg: msg}
+				pm, err := decodeSqsMessage(msg)
+				if err != nil {
+					logging.Error(ctx).Err(err).Msgf(couldNotReadMsgBody, aws.StringValue(msg.MessageId), c.queue)
+					_ = original.Nack(false, err)
+					continue
 				}
 
-				var pm ProviderMessage
-				if err = json.Unmarshal([]byte(n.Message), &pm); err != nil {
-					logging.Error(ctx).Err(err).Msgf(couldNotReadMsgBody, *msg.MessageId, c.queue)
+				original.heartbeat = m.startHeartbeat(queueUrl, msg, visibility)
+				pm.addOriginBrokerNotification(original)
+				if !c.deliver(ch, pm) {
+					// the consumer is closing, so the messages not delivered are made visible again right away
+					original.heartbeat.stop()
+					for _, rest := range msgs.Messages[i:] {
+						_ = awsOriginalMessage{m: m, queue: c.queue, queueUrl: queueUrl, msg: rest}.NackWithDelay(0, nil)
+					}
 					return
 				}
-
-				pm.addOriginBrokerNotification(awsOriginalMessage{})
-				ch <- &pm
-				m.removeMessageFromQueue(ctx, queueUrl, msg)
 			}
 		}
 	}()
@@ -105,26 +400,54 @@ func (m *awsMessaging) consumer(ctx context.Context, c *consumer) (chan *Provide
 	return ch, nil
 }
 
-func (m *awsMessaging) readMessages(ctx context.Context, queueResult *sqs.GetQueueUrlOutput) (*sqs.ReceiveMessageOutput, error) {
+// startHeartbeat extends the visibility timeout of the message every half of the timeout, until the message is
+// acknowledged or rejected, or the SQS limit of 12 hours is reached
+func (m *awsMessaging) startHeartbeat(queueUrl *sqs.GetQueueUrlOutput, msg *sqs.Message, visibility time.Duration) *sqsHeartbeat {
+	h := &sqsHeartbeat{}
+	stop := make(chan struct{})
+	h.cancel = func() { close(stop) }
+
+	go func() {
+		ticker := time.NewTicker(max(visibility/2, time.Second))
+		defer ticker.Stop()
+		deadline := time.After(sqsMaxVisibilityTimeout - visibility)
+
+		for {
+			select {
+			case <-stop:
+				return
+			case <-deadline:
+				return
+			case <-ticker.C:
+				h.extend(func() {
+					if _, err := m.sqsService.ChangeMessageVisibility(&sqs.ChangeMessageVisibilityInput{
+						QueueUrl:          queueUrl.QueueUrl,
+						ReceiptHandle:     msg.ReceiptHandle,
+						VisibilityTimeout: aws.Int64(int64(visibility.Seconds())),
+					}); err != nil {
+						logging.Warn(context.Background()).Err(err).Msgf(couldNotChangeMsgVisibility, aws.StringValue(msg.MessageId), aws.StringValue(queueUrl.QueueUrl))
+					}
+				})
+			}
+		}
+	}()
+
+	return h
+}
+
+// readMessages long polls the queue for up to batchSize messages, limited to the SQS maximum of 10
+func (m *awsMessaging) readMessages(ctx context.Context, queueResult *sqs.GetQueueUrlOutput, batchSize int) (*sqs.ReceiveMessageOutput, error) {
 	var msgs, err = m.sqsService.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
 		QueueUrl:              queueResult.QueueUrl,
-		MaxNumberOfMessages:   aws.Int64(1),
-		WaitTimeSeconds:       aws.Int64(1),
+		MaxNumberOfMessages:   aws.Int64(int64(min(batchSize, sqsMaxBatchSize))),
+		WaitTimeSeconds:       aws.Int64(sqsLongPollingSeconds),
 		MessageAttributeNames: aws.StringSlice([]string{"All"}),
+		AttributeNames:        aws.StringSlice([]string{sqsApproximateReceiveCount}),
 	})
 
 	return msgs, err
 }
 
-func (m *awsMessaging) removeMessageFromQueue(ctx context.Context, queueResult *sqs.GetQueueUrlOutput, msg *sqs.Message) {
-	if _, err := m.sqsService.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
-		QueueUrl:      queueResult.QueueUrl,
-		ReceiptHandle: msg.ReceiptHandle,
-	}); err != nil {
-		logging.Error(ctx).Err(err).Msgf(couldNotDeleteMsg, *msg.MessageId, *queueResult.QueueUrl)
-	}
-}
-
 func (m *awsMessaging) getQueueUrl(ctx context.Context, queue string) *sqs.GetQueueUrlOutput {
 	queueResult, err := m.sqsService.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queue)})
 	if err != nil {
@@ -137,3 +460,335 @@ func (m *awsMessaging) getQueueUrl(ctx context.Context, queue string) *sqs.GetQu
 
 	return queueResult
 }
+
+// queueDepth returns the approximate number of messages available in the queue
+func (m *awsMessaging) queueDepth(ctx context.Context, queue string) (int64, error) {
+	queueUrl, err := m.sqsService.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queue)})
+	if err != nil {
+		return 0, err
+	}
+
+	attributes, err := m.sqsService.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       queueUrl.QueueUrl,
+		AttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameApproximateNumberOfMessages}),
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(aws.StringValue(attributes.Attributes[sqs.QueueAttributeNameApproximateNumberOfMessages]), 10, 64)
+}
+
+// readDeadLetters receives up to limit messages from the <queue>_DLQ queue, keeping them invisible to other
+// readers for sqsDeadLetterHold, renewed before each replay so a slow replay does not outlast it.
+// Undecodable messages stay invisible until the hold expires.
+func (m *awsMessaging) readDeadLetters(ctx context.Context, queue string, limit int) ([]*DeadLetter, error) {
+	dlqUrl, err := m.getDLQUrl(queue)
+	if err != nil {
+		return nil, err
+	}
+	if dlqUrl == nil {
+		return nil, fmt.Errorf(queueNotFound, queue+sqsDLQSuffix)
+	}
+	queueUrl, err := m.sqsService.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{QueueName: aws.String(queue)})
+	if err != nil {
+		return nil, err
+	}
+
+	deadLetters := make([]*DeadLetter, 0, limit)
+	for len(deadLetters) < limit {
+		msgs, err := m.sqsService.ReceiveMessageWithContext(ctx, &sqs.ReceiveMessageInput{
+			QueueUrl:              dlqUrl,
+			MaxNumberOfMessages:   aws.Int64(int64(min(limit-len(deadLetters), sqsMaxBatchSize))),
+			WaitTimeSeconds:       aws.Int64(sqsDeadLetterWaitSeconds),
+			VisibilityTimeout:     aws.Int64(int64(sqsDeadLetterHold.Seconds())),
+			MessageAttributeNames: aws.StringSlice([]string{"All"}),
+		})
+		if err != nil {
+			releaseDeadLetters(ctx, deadLetters)
+			return nil, err
+		}
+		if len(msgs.Messages) == 0 {
+			break
+		}
+
+		for _, msg := range msgs.Messages {
+			pm, err := decodeSqsMessage(msg)
+			if err != nil {
+				logging.Error(ctx).Err(err).Msgf(couldNotReadMsgBody, aws.StringValue(msg.MessageId), aws.StringValue(dlqUrl))
+				continue
+			}
+			deadLetters = append(deadLetters, m.newDeadLetter(queue, queueUrl.QueueUrl, dlqUrl, pm, msg))
+		}
+	}
+
+	return deadLetters, nil
+}
+
+// newDeadLetter returns the dead letter of a message received from the DLQ of the queue
+func (m *awsMessaging) newDeadLetter(queue string, queueUrl, dlqUrl *string, pm *ProviderMessage, msg *sqs.Message) *DeadLetter {
+	var reason, attempts string
+	if attribute, ok := msg.MessageAttributes[headerFailureReason]; ok {
+		reason = aws.StringValue(attribute.StringValue)
+	}
+	if attribute, ok := msg.MessageAttributes[headerAttempts]; ok {
+		attempts = aws.StringValue(attribute.StringValue)
+	}
+
+	return &DeadLetter{
+		Message:  pm,
+		Queue:    queue,
+		Reason:   reason,
+		Attempts: getDeadLetterAttempts(attempts),
+		remove: func(ctx context.Context) error {
+			_, err := m.sqsService.DeleteMessageWithContext(ctx, &sqs.DeleteMessageInput{
+				QueueUrl:      dlqUrl,
+				ReceiptHandle: msg.ReceiptHandle,
+			})
+			return err
+		},
+		release: func(ctx context.Context) error {
+			_, err := m.sqsService.ChangeMessageVisibilityWithContext(ctx, &sqs.ChangeMessageVisibilityInput{
+				QueueUrl:          dlqUrl,
+				ReceiptHandle:     msg.ReceiptHandle,
+				VisibilityTimeout: aws.Int64(0),
+			})
+			return err
+		},
+		hold: func(ctx context.Context) error {
+			_, err := m.sqsService.ChangeMessageVisibilityWithContext(ctx, &sqs.ChangeMessageVisibilityInput{
+				QueueUrl:          dlqUrl,
+				ReceiptHandle:     msg.ReceiptHandle,
+				VisibilityTimeout: aws.Int64(int64(sqsDeadLetterHold.Seconds())),
+			})
+			return err
+		},
+		requeue: func(ctx context.Context) error {
+			attributes := make(map[string]*sqs.MessageAttributeValue, len(msg.MessageAttributes))
+			for key, attribute := range msg.MessageAttributes {
+				attributes[key] = attribute
+			}
+			delete(attributes, headerFailureReason)
+			delete(attributes, headerAttempts)
+
+			_, err := m.sqsService.SendMessageWithContext(ctx, &sqs.SendMessageInput{
+				QueueUrl:          queueUrl,
+				MessageBody:       msg.Body,
+				MessageAttributes: attributes,
+			})
+			return err
+		},
+	}
+}
+
+// getVisibilityTimeout returns the visibility timeout of the queue, or the SQS default when it can not be read
+func (m *awsMessaging) getVisibilityTimeout(ctx context.Context, queueUrl *sqs.GetQueueUrlOutput) time.Duration {
+	attributes, err := m.sqsService.GetQueueAttributesWithContext(ctx, &sqs.GetQueueAttributesInput{
+		QueueUrl:       queueUrl.QueueUrl,
+		AttributeNames: aws.StringSlice([]string{sqs.QueueAttributeNameVisibilityTimeout}),
+	})
+	if err != nil {
+		return sqsDefaultVisibilityTimeout
+	}
+
+	seconds, err := strconv.Atoi(aws.StringValue(attributes.Attributes[sqs.QueueAttributeNameVisibilityTimeout]))
+	if err != nil || seconds <= 0 {
+		return sqsDefaultVisibilityTimeout
+	}
+
+	return time.Duration(seconds) * time.Second
+}
+
+// getDLQUrl returns the url of the <queue>_DLQ queue, or nil when it does not exist, resolving it once per process.
+// Other errors are not cached, so the url is resolved again on the next call.
+func (m *awsMessaging) getDLQUrl(queue string) (*string, error) {
+	if url, ok := m.dlqUrls.Load(queue); ok {
+		return url.(*string), nil
+	}
+
+	result, err := m.sqsService.GetQueueUrl(&sqs.GetQueueUrlInput{QueueName: aws.String(queue + sqsDLQSuffix)})
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) && awsErr.Code() == sqs.ErrCodeQueueDoesNotExist {
+		m.dlqUrls.Store(queue, (*string)(nil))
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	m.dlqUrls.Store(queue, result.QueueUrl)
+
+	return result.QueueUrl, nil
+}
+
+// getTopicQueueUrls returns the urls of the queues subscribed to the topic, listing them again once they are older
+// than snsSubscriptionsTTL. It returns nil when a subscription is not a standard SQS queue without a filter policy.
+func (m *awsMessaging) getTopicQueueUrls(ctx context.Context, topic string) ([]*string, error) {
+	if queues, ok := m.topicQueues.Load(topic); ok && time.Now().Before(queues.(snsTopicQueues).expires) {
+		return queues.(snsTopicQueues).urls, nil
+	}
+
+	var subscriptions []*sns.Subscription
+	if err := m.snsService.ListSubscriptionsByTopicPagesWithContext(ctx, &sns.ListSubscriptionsByTopicInput{
+		TopicArn: aws.String(getSnsTopicArn(topic)),
+	}, func(page *sns.ListSubscriptionsByTopicOutput, _ bool) bool {
+		subscriptions = append(subscriptions, page.Subscriptions...)
+		return true
+	}); err != nil {
+		return nil, err
+	}
+
+	urls, err := m.getSubscriptionQueueUrls(ctx, subscriptions)
+	if err != nil {
+		return nil, err
+	}
+	m.topicQueues.Store(topic, snsTopicQueues{urls: urls, expires: time.Now().Add(snsSubscriptionsTTL)})
+
+	return urls, nil
+}
+
+func (m *awsMessaging) getSubscriptionQueueUrls(ctx context.Context, subscriptions []*sns.Subscription) ([]*string, error) {
+	urls := make([]*string, 0, len(subscriptions))
+	for _, subscription := range subscriptions {
+		queueArn, err := arn.Parse(aws.StringValue(subscription.Endpoint))
+		if aws.StringValue(subscription.Protocol) != snsSqsProtocol || err != nil || strings.HasSuffix(queueArn.Resource, sqsFifoSuffix) {
+			return nil, nil
+		}
+
+		attributes, err := m.snsService.GetSubscriptionAttributesWithContext(ctx, &sns.GetSubscriptionAttributesInput{
+			SubscriptionArn: subscription.SubscriptionArn,
+		})
+		if err != nil {
+			return nil, err
+		}
+		if aws.StringValue(attributes.Attributes[snsFilterPolicyAttribute]) != "" {
+			return nil, nil
+		}
+
+		queueUrl, err := m.sqsService.GetQueueUrlWithContext(ctx, &sqs.GetQueueUrlInput{
+			QueueName:              aws.String(queueArn.Resource),
+			QueueOwnerAWSAccountId: aws.String(queueArn.AccountID),
+		})
+		if err != nil {
+			return nil, err
+		}
+		urls = append(urls, queueUrl.QueueUrl)
+	}
+
+	return urls, nil
+}
+
+// encodeAwsMessage encodes the message within the limits of SNS and SQS message attributes, which are at most 10
+// and can not be empty. Binary CloudEvents that do not fit are published as structured CloudEvents instead,
+// carrying the CloudEvents attributes in the body. Empty attributes of other encodings are left out,
+// since the body carries the headers too.
+func encodeAwsMessage(msg *ProviderMessage) ([]byte, map[string]string, error) {
+	body, attributes, err := encodeMessage(msg, cloudEventsAttributePrefix)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if msg.encoding == EncodingCloudEventsBinary && !fitsAwsAttributes(attributes) {
+		structured := *msg
+		structured.encoding = EncodingCloudEventsStructured
+		if body, attributes, err = encodeMessage(&structured, cloudEventsAttributePrefix); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	maps.DeleteFunc(attributes, func(_ string, value string) bool { return value == "" })
+	if len(attributes) > sqsMaxMessageAttributes {
+		return nil, nil, fmt.Errorf("%w: %d attributes of message %s", errTooManyMessageAttributes, len(attributes), msg.ID)
+	}
+
+	return body, attributes, nil
+}
+
+// fitsAwsAttributes reports whether the attributes are accepted as they are by SNS and SQS
+func fitsAwsAttributes(attributes map[string]string) bool {
+	for _, value := range attributes {
+		if value == "" {
+			return false
+		}
+	}
+
+	return len(attributes) <= sqsMaxMessageAttributes
+}
+
+// getSnsAttributes returns the message attributes as SNS string attributes
+func getSnsAttributes(attributes map[string]string) map[string]*sns.MessageAttributeValue {
+	snsAttributes := make(map[string]*sns.MessageAttributeValue, len(attributes))
+	for key, value := range attributes {
+		snsAttributes[key] = &sns.MessageAttributeValue{DataType: aws.String(sqsStringAttributeDataType), StringValue: aws.String(value)}
+	}
+
+	return snsAttributes
+}
+
+// getSnsMessageGroupId returns the FIFO message group of the message. Messages without a key use their own id
+// as the group, so they are not ordered with each other.
+func getSnsMessageGroupId(msg *ProviderMessage) string {
+	if msg.key != "" {
+		return msg.key
+	}
+
+	return msg.ID.String()
+}
+
+// getSnsTopicArn returns the ARN of the topic in the account and region of the AWS session
+func getSnsTopicArn(topic string) string {
+	return fmt.Sprintf("arn:%s:sns:%s:%s:%s",
+		cloud.GetAwsARN().Partition,
+		*cloud.GetAwsSession().Config.Region,
+		cloud.GetAwsARN().AccountID,
+		topic,
+	)
+}
+
+// decodeSqsMessage decodes the message from the SNS notification, or from the SQS message body itself when raw
+// message delivery is enabled
+func decodeSqsMessage(msg *sqs.Message) (*ProviderMessage, error) {
+	var n sqsNotification
+	body := aws.StringValue(msg.Body)
+	if err := json.Unmarshal([]byte(body), &n); err == nil && n.Type != "" {
+		body = n.Message
+	}
+
+	attributes := getSqsAttributes(msg, n)
+	pm, err := decodeMessage([]byte(body), attributes, cloudEventsAttributePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	pm.attempt = getSqsAttempt(msg)
+	pm.traceContext = attributes
+
+	return pm, nil
+}
+
+// getSqsAttempt returns how many times SQS delivered the message, starting at 1
+func getSqsAttempt(msg *sqs.Message) int {
+	count, err := strconv.Atoi(aws.StringValue(msg.Attributes[sqsApproximateReceiveCount]))
+	if err != nil {
+		return 1
+	}
+
+	return max(count, 1)
+}
+
+// getSqsAttributes returns the string attributes carried by the SNS notification or by the SQS message itself
+// when raw message delivery is enabled
+func getSqsAttributes(msg *sqs.Message, n sqsNotification) map[string]string {
+	carrier := make(map[string]string, len(n.MessageAttributes)+len(msg.MessageAttributes))
+	for key, attribute := range n.MessageAttributes {
+		if attribute.Type == sqsStringAttributeDataType {
+			carrier[key] = attribute.Value
+		}
+	}
+	for key, attribute := range msg.MessageAttributes {
+		if aws.StringValue(attribute.DataType) == sqsStringAttributeDataType {
+			carrier[key] = aws.StringValue(attribute.StringValue)
+		}
+	}
+
+	return carrier
+}
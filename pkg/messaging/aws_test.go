@@ -0,0 +1,144 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/sqs"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAwsEncoding(t *testing.T) {
+	newMessage := func(encoding Encoding, headers map[string]string) *ProviderMessage {
+		msg := NewProviderMessage(context.Background(), "user_created", map[string]any{"name": "User"})
+		msg.Origin = "colibri-test"
+		msg.CorrelationID = "correlation"
+		msg.Headers = headers
+		msg.OccurredAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		msg.encoding = encoding
+		msg.key = "order-1"
+		msg.traceContext = map[string]string{
+			"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01",
+			"tracestate":  "congo=t61rcWkgMzE",
+		}
+		return msg
+	}
+	headers := map[string]string{
+		"tenant":     "tenant",
+		"requestid":  "request",
+		"replyto":    "reply-queue",
+		"encryption": encryptionAES256GCM,
+		"signature":  "c2lnbmF0dXJl",
+	}
+
+	t.Run("Should publish binary CloudEvents as structured when the attributes do not fit", func(t *testing.T) {
+		msg := newMessage(EncodingCloudEventsBinary, headers)
+
+		body, attributes, err := encodeAwsMessage(msg)
+		var event map[string]any
+		unmarshalErr := json.Unmarshal(body, &event)
+		decoded, decodeErr := decodeMessage(body, attributes, cloudEventsAttributePrefix)
+
+		assert.NoError(t, err)
+		assert.NoError(t, unmarshalErr)
+		assert.NoError(t, decodeErr)
+		assert.LessOrEqual(t, len(attributes), sqsMaxMessageAttributes)
+		assert.Equal(t, "1.0", event["specversion"])
+		assert.Equal(t, msg.ID, decoded.ID)
+		assert.Equal(t, headers, decoded.Headers)
+		assert.Equal(t, "order-1", decoded.key)
+	})
+
+	t.Run("Should publish binary CloudEvents as structured when an attribute is empty", func(t *testing.T) {
+		msg := newMessage(EncodingCloudEventsBinary, map[string]string{"tenant": ""})
+
+		body, attributes, err := encodeAwsMessage(msg)
+		decoded, decodeErr := decodeMessage(body, attributes, cloudEventsAttributePrefix)
+
+		assert.NoError(t, err)
+		assert.NoError(t, decodeErr)
+		assert.NotContains(t, attributes, cloudEventsAttributePrefix+cloudEventsSpecVersionAttr)
+		assert.Equal(t, map[string]string{"tenant": ""}, decoded.Headers)
+	})
+
+	t.Run("Should keep binary CloudEvents that fit", func(t *testing.T) {
+		msg := newMessage(EncodingCloudEventsBinary, nil)
+		msg.traceContext = nil
+
+		_, attributes, err := encodeAwsMessage(msg)
+
+		assert.NoError(t, err)
+		assert.Equal(t, cloudEventsSpecVersion, attributes[cloudEventsAttributePrefix+cloudEventsSpecVersionAttr])
+	})
+
+	t.Run("Should leave out empty attributes", func(t *testing.T) {
+		msg := newMessage(EncodingColibri, map[string]string{"tenant": "tenant", "requestid": ""})
+
+		_, attributes, err := encodeAwsMessage(msg)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "tenant", attributes["tenant"])
+		assert.NotContains(t, attributes, "requestid")
+	})
+
+	t.Run("Should return error when the message needs more attributes than allowed", func(t *testing.T) {
+		many := make(map[string]string)
+		for _, name := range strings.Split("a b c d e f g h i", " ") {
+			many[name] = name
+		}
+		msg := newMessage(EncodingColibri, many)
+
+		_, _, err := encodeAwsMessage(msg)
+
+		assert.ErrorIs(t, err, errTooManyMessageAttributes)
+	})
+}
+
+func TestSqsDeadLetterAttributes(t *testing.T) {
+	stringAttribute := func(value string) *sqs.MessageAttributeValue {
+		return &sqs.MessageAttributeValue{DataType: aws.String(sqsStringAttributeDataType), StringValue: aws.String(value)}
+	}
+	newMessage := func(attributes int) *sqs.Message {
+		msg := &sqs.Message{
+			Attributes:        map[string]*string{sqsApproximateReceiveCount: aws.String("3")},
+			MessageAttributes: map[string]*sqs.MessageAttributeValue{},
+		}
+		for i := range attributes {
+			msg.MessageAttributes[fmt.Sprintf("attribute-%d", i)] = stringAttribute("value")
+		}
+		return msg
+	}
+
+	t.Run("Should copy the message attributes and add the failure attributes", func(t *testing.T) {
+		msg := newMessage(2)
+		msg.MessageAttributes[cloudEventsAttributePrefix+cloudEventsSpecVersionAttr] = stringAttribute(cloudEventsSpecVersion)
+
+		attributes := getSqsDeadLetterAttributes(msg, errors.New("failure"))
+
+		assert.Len(t, attributes, 5)
+		assert.Equal(t, cloudEventsSpecVersion, aws.StringValue(attributes[cloudEventsAttributePrefix+cloudEventsSpecVersionAttr].StringValue))
+		assert.Equal(t, "failure", aws.StringValue(attributes[headerFailureReason].StringValue))
+		assert.Equal(t, "3", aws.StringValue(attributes[headerAttempts].StringValue))
+		assert.Len(t, msg.MessageAttributes, 3)
+	})
+
+	t.Run("Should add only the failure attributes that fit", func(t *testing.T) {
+		attributes := getSqsDeadLetterAttributes(newMessage(sqsMaxMessageAttributes-1), errors.New("failure"))
+
+		assert.Len(t, attributes, sqsMaxMessageAttributes)
+		assert.Contains(t, attributes, headerFailureReason)
+	})
+
+	t.Run("Should leave out an empty failure reason", func(t *testing.T) {
+		attributes := getSqsDeadLetterAttributes(newMessage(0), nil)
+
+		assert.NotContains(t, attributes, headerFailureReason)
+		assert.Contains(t, attributes, headerAttempts)
+	})
+}
@@ -0,0 +1,227 @@
+package messaging
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"os"
+
+	gcpstorage "cloud.google.com/go/storage"
+	"github.com/aws/aws-sdk-go/aws/awserr"
+	"github.com/aws/aws-sdk-go/service/s3"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/storage"
+)
+
+const (
+	// ClaimCheckDefaultThreshold is the payload size above which messages are offloaded, below the SNS and SQS limit of 256 KB
+	ClaimCheckDefaultThreshold = 200 * 1024
+
+	// headerClaimCheck marks a message whose payload was offloaded and holds its retention policy.
+	// It only has lower-case letters, so it is kept as is by every encoding.
+	headerClaimCheck = "claimcheck"
+
+	couldNotDeleteClaimCheck = "could not delete offloaded payload %s of message %s"
+)
+
+// ErrClaimCheckNotFound is returned by claim-check stores when the offloaded payload does not exist.
+// Messages whose payload is gone can never be processed, so they go straight to the dead-letter queue.
+var ErrClaimCheckNotFound = errors.New("offloaded payload not found")
+
+// ClaimCheckRetention is what happens to an offloaded payload once the message is processed
+type ClaimCheckRetention string
+
+const (
+	// ClaimCheckDeleteOnAck deletes the payload when the message is acknowledged.
+	// Use it only when the topic has a single consumer queue, since the first queue to process the message deletes it.
+	ClaimCheckDeleteOnAck ClaimCheckRetention = "delete"
+	// ClaimCheckRetain keeps the payload, leaving its expiration to the bucket lifecycle rules. It is the default.
+	ClaimCheckRetain ClaimCheckRetention = "retain"
+)
+
+// ClaimCheckStore stores the payloads offloaded by producers with WithClaimCheck.
+// Download returns ErrClaimCheckNotFound when the payload does not exist.
+type ClaimCheckStore interface {
+	Upload(ctx context.Context, bucket, key string, payload []byte) error
+	Download(ctx context.Context, bucket, key string) ([]byte, error)
+	Delete(ctx context.Context, bucket, key string) error
+}
+
+// ClaimCheckOption configures optional behaviour of the claim-check of a producer
+type ClaimCheckOption func(c *claimCheck)
+
+// claimCheck is the offload configuration of a producer
+type claimCheck struct {
+	bucket    string
+	threshold int
+	retention ClaimCheckRetention
+}
+
+// claimCheckReference is published in place of an offloaded payload
+type claimCheckReference struct {
+	Bucket string `json:"bucket"`
+	Key    string `json:"key"`
+	Size   int    `json:"size"`
+}
+
+var claimCheckStore ClaimCheckStore = NewStorageClaimCheckStore()
+
+// WithClaimCheck offloads the payloads larger than the threshold to the bucket, publishing only a reference to them.
+// Consumers download the payload before processing the message.
+func WithClaimCheck(bucket string, opts ...ClaimCheckOption) ProducerOption {
+	c := &claimCheck{bucket: bucket, threshold: ClaimCheckDefaultThreshold, retention: ClaimCheckRetain}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return func(p *Producer) {
+		p.claimCheck = c
+	}
+}
+
+// WithClaimCheckThreshold sets the payload size in bytes above which the payload is offloaded
+func WithClaimCheckThreshold(threshold int) ClaimCheckOption {
+	return func(c *claimCheck) {
+		c.threshold = threshold
+	}
+}
+
+// WithClaimCheckRetention sets what happens to the offloaded payload once the message is processed.
+// The default is ClaimCheckRetain, since other queues of the topic may still need the payload.
+func WithClaimCheckRetention(retention ClaimCheckRetention) ClaimCheckOption {
+	return func(c *claimCheck) {
+		c.retention = retention
+	}
+}
+
+// SetClaimCheckStore replaces the store of offloaded payloads, which is the storage package by default.
+// Producers and consumers of the same topic must use the same store.
+func SetClaimCheckStore(store ClaimCheckStore) {
+	claimCheckStore = store
+}
+
+// offload uploads the message payload when it is larger than the threshold and replaces it with a reference
+func (c *claimCheck) offload(ctx context.Context, topic string, msg *ProviderMessage) error {
+	payload, err := json.Marshal(msg.Message)
+	if err != nil {
+		return err
+	}
+	if len(payload) <= c.threshold {
+		return nil
+	}
+
+	ref := claimCheckReference{Bucket: c.bucket, Key: fmt.Sprintf("%s/%s.json", topic, msg.ID), Size: len(payload)}
+	if err = claimCheckStore.Upload(ctx, ref.Bucket, ref.Key, payload); err != nil {
+		return err
+	}
+
+	msg.Message = ref
+	WithHeader(headerClaimCheck, string(c.retention))(msg)
+	return nil
+}
+
+// resolveClaimCheck replaces the reference of an offloaded payload with the payload itself
+func resolveClaimCheck(ctx context.Context, msg *ProviderMessage) error {
+	retention, ok := msg.Headers[headerClaimCheck]
+	if !ok {
+		return nil
+	}
+
+	var ref claimCheckReference
+	if err := msg.DecodeMessage(&ref); err != nil {
+		return err
+	}
+
+	payload, err := claimCheckStore.Download(ctx, ref.Bucket, ref.Key)
+	if errors.Is(err, ErrClaimCheckNotFound) {
+		return NewPermanentError(err)
+	}
+	if err != nil {
+		return err
+	}
+
+	var message any
+	if err = json.Unmarshal(payload, &message); err != nil {
+		return err
+	}
+
+	msg.Message = message
+	delete(msg.Headers, headerClaimCheck)
+	if ClaimCheckRetention(retention) == ClaimCheckDeleteOnAck {
+		msg.claimCheck = &ref
+	}
+
+	return nil
+}
+
+// releaseClaimCheck deletes the offloaded payload of an acknowledged message when its retention policy asks so
+func releaseClaimCheck(ctx context.Context, msg *ProviderMessage) {
+	if msg.claimCheck == nil {
+		return
+	}
+
+	if err := claimCheckStore.Delete(ctx, msg.claimCheck.Bucket, msg.claimCheck.Key); err != nil {
+		logging.Error(ctx).Err(err).Msgf(couldNotDeleteClaimCheck, msg.claimCheck.Key, msg.ID)
+	}
+}
+
+// StorageClaimCheckStore stores the offloaded payloads with the storage package, which must be initialized
+type StorageClaimCheckStore struct{}
+
+// NewStorageClaimCheckStore returns a claim-check store backed by the storage package
+func NewStorageClaimCheckStore() *StorageClaimCheckStore {
+	return &StorageClaimCheckStore{}
+}
+
+func (s *StorageClaimCheckStore) Upload(ctx context.Context, bucket, key string, payload []byte) error {
+	var file multipart.File = claimCheckPayload{bytes.NewReader(payload)}
+	_, err := storage.UploadFile(ctx, bucket, key, &file)
+	return err
+}
+
+func (s *StorageClaimCheckStore) Download(ctx context.Context, bucket, key string) ([]byte, error) {
+	file, err := storage.DownloadFile(ctx, bucket, key)
+	if isStorageNotFound(err) {
+		return nil, fmt.Errorf("%w: %s/%s: %w", ErrClaimCheckNotFound, bucket, key, err)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = file.Close()
+		_ = os.Remove(file.Name())
+	}()
+
+	if _, err = file.Seek(0, io.SeekStart); err != nil {
+		return nil, err
+	}
+
+	return io.ReadAll(file)
+}
+
+func (s *StorageClaimCheckStore) Delete(ctx context.Context, bucket, key string) error {
+	return storage.DeleteFile(ctx, bucket, key)
+}
+
+// isStorageNotFound reports whether the error of the storage package means the object does not exist
+func isStorageNotFound(err error) bool {
+	var awsErr awserr.Error
+	if errors.As(err, &awsErr) {
+		return awsErr.Code() == s3.ErrCodeNoSuchKey
+	}
+
+	return errors.Is(err, gcpstorage.ErrObjectNotExist)
+}
+
+// claimCheckPayload is an in-memory payload uploaded as a multipart file
+type claimCheckPayload struct {
+	*bytes.Reader
+}
+
+func (claimCheckPayload) Close() error {
+	return nil
+}
@@ -0,0 +1,154 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// claimCheckStoreTest keeps the offloaded payloads in memory
+type claimCheckStoreTest struct {
+	mu          sync.Mutex
+	objects     map[string][]byte
+	downloadErr error
+}
+
+func (s *claimCheckStoreTest) Upload(_ context.Context, bucket, key string, payload []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.objects[bucket+"/"+key] = payload
+	return nil
+}
+
+func (s *claimCheckStoreTest) Download(_ context.Context, bucket, key string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.downloadErr != nil {
+		return nil, s.downloadErr
+	}
+	payload, ok := s.objects[bucket+"/"+key]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s/%s", ErrClaimCheckNotFound, bucket, key)
+	}
+	return payload, nil
+}
+
+func (s *claimCheckStoreTest) Delete(_ context.Context, bucket, key string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	delete(s.objects, bucket+"/"+key)
+	return nil
+}
+
+func TestClaimCheck(t *testing.T) {
+	useMemoryMessaging(t)
+	store := &claimCheckStoreTest{objects: make(map[string][]byte)}
+	previousStore := claimCheckStore
+	SetClaimCheckStore(store)
+	t.Cleanup(func() { SetClaimCheckStore(previousStore) })
+
+	largeUser := userMessageTest{Name: strings.Repeat("a", 200)}
+	consume := func(queue string) *[]userMessageTest {
+		received := make([]userMessageTest, 0)
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				var user userMessageTest
+				if err := message.DecodeMessage(&user); err != nil {
+					return err
+				}
+				received = append(received, user)
+				return nil
+			},
+			qName: queue,
+		})
+		return &received
+	}
+
+	t.Run("Should offload large payloads and delete them after the ack", func(t *testing.T) {
+		Memory().Bind("CLAIM_CHECK_TOPIC", "CLAIM_CHECK_QUEUE")
+		received := consume("CLAIM_CHECK_QUEUE")
+
+		err := NewProducer("CLAIM_CHECK_TOPIC", WithClaimCheck("bucket", WithClaimCheckThreshold(100), WithClaimCheckRetention(ClaimCheckDeleteOnAck))).
+			Publish(context.Background(), "create", largeUser)
+
+		published := Memory().Published("CLAIM_CHECK_TOPIC")
+		assert.NoError(t, err)
+		assert.Equal(t, []userMessageTest{largeUser}, *received)
+		assert.IsType(t, claimCheckReference{}, published[0].Message)
+		assert.Equal(t, "CLAIM_CHECK_TOPIC/"+published[0].ID.String()+".json", published[0].Message.(claimCheckReference).Key)
+		assert.Empty(t, store.objects)
+	})
+
+	t.Run("Should keep the payload with the retain policy", func(t *testing.T) {
+		Memory().Bind("CLAIM_CHECK_RETAIN_TOPIC", "CLAIM_CHECK_RETAIN_QUEUE")
+		received := consume("CLAIM_CHECK_RETAIN_QUEUE")
+		producer := NewProducer("CLAIM_CHECK_RETAIN_TOPIC",
+			WithClaimCheck("bucket", WithClaimCheckThreshold(100), WithClaimCheckRetention(ClaimCheckRetain)))
+
+		results, err := producer.PublishBatch(context.Background(), "create", []any{largeUser, userMessageTest{Name: "User"}})
+
+		published := Memory().Published("CLAIM_CHECK_RETAIN_TOPIC")
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, []userMessageTest{largeUser, {Name: "User"}}, *received)
+		assert.Contains(t, store.objects, "bucket/CLAIM_CHECK_RETAIN_TOPIC/"+results[0].ID.String()+".json")
+		assert.Equal(t, userMessageTest{Name: "User"}, published[1].Message)
+	})
+
+	t.Run("Should reject the message when the payload can not be downloaded", func(t *testing.T) {
+		Memory().Bind("CLAIM_CHECK_FAIL_TOPIC", "CLAIM_CHECK_FAIL_QUEUE")
+		received := consume("CLAIM_CHECK_FAIL_QUEUE")
+		store.downloadErr = errors.New("storage unavailable")
+		defer func() { store.downloadErr = nil }()
+
+		err := NewProducer("CLAIM_CHECK_FAIL_TOPIC", WithClaimCheck("bucket", WithClaimCheckThreshold(100))).
+			Publish(context.Background(), "create", largeUser)
+
+		deadLetters := Memory().DeadLetters("CLAIM_CHECK_FAIL_QUEUE")
+		assert.NoError(t, err)
+		assert.Empty(t, *received)
+		assert.Len(t, deadLetters, 1)
+		assert.Equal(t, "storage unavailable", deadLetters[0].Reason)
+	})
+
+	t.Run("Should keep the payload by default", func(t *testing.T) {
+		received := consume("CLAIM_CHECK_DEFAULT_QUEUE")
+
+		results, err := NewProducer("CLAIM_CHECK_DEFAULT_QUEUE", WithClaimCheck("bucket", WithClaimCheckThreshold(100))).
+			PublishBatch(context.Background(), "create", []any{largeUser})
+
+		assert.NoError(t, err)
+		assert.Equal(t, []userMessageTest{largeUser}, *received)
+		assert.Contains(t, store.objects, "bucket/CLAIM_CHECK_DEFAULT_QUEUE/"+results[0].ID.String()+".json")
+	})
+
+	t.Run("Should send the message to the dead-letter queue without retrying when the payload does not exist", func(t *testing.T) {
+		attempts := 0
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				attempts++
+				return nil
+			},
+			qName: "CLAIM_CHECK_MISSING_QUEUE",
+		}, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+		msg := NewProviderMessage(context.Background(), "create", claimCheckReference{Bucket: "bucket", Key: "missing.json"})
+		WithHeader(headerClaimCheck, string(ClaimCheckRetain))(msg)
+
+		err := instance.producer(context.Background(), NewProducer("CLAIM_CHECK_MISSING_QUEUE"), msg)
+
+		deadLetters := Memory().DeadLetters("CLAIM_CHECK_MISSING_QUEUE")
+		assert.NoError(t, err)
+		assert.Zero(t, attempts)
+		assert.Len(t, deadLetters, 1)
+		assert.Equal(t, 1, deadLetters[0].Attempts)
+		assert.Contains(t, deadLetters[0].Reason, ErrClaimCheckNotFound.Error())
+	})
+}
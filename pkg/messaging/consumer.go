@@ -2,8 +2,12 @@ package messaging
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"hash/fnv"
 	"sync"
+	"sync/atomic"
+	"time"
 
 	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/monitoring"
 	colibrimonitoringbase "github.com/colibriproject-dev/colibri-sdk-go/pkg/base/monitoring/colibri-monitoring-base"
@@ -14,9 +18,46 @@ import (
 
 type consumer struct {
 	sync.WaitGroup
-	queue string
-	fn    func(ctx context.Context, message *ProviderMessage) error
-	done  chan any
+	mu      sync.Mutex
+	queue   string
+	topic   string
+	fn      func(ctx context.Context, message *ProviderMessage) error
+	done    chan any
+	options ConsumerOptions
+	// messages are the messages received from the provider and not handed to the workers yet
+	messages chan *ProviderMessage
+	// releases free the provider resources once the consumer stopped and handed back its unprocessed messages
+	releases []func()
+	// paused is closed while the consumer is paused and resumed while it is running
+	paused    chan struct{}
+	resumed   chan struct{}
+	inFlight  atomic.Int64
+	processed atomic.Uint64
+	failed    atomic.Uint64
+}
+
+// ConsumerHandle controls a consumer started by NewConsumer
+type ConsumerHandle struct {
+	c *consumer
+}
+
+// ConsumerStats is a snapshot of the state and counters of a consumer
+type ConsumerStats struct {
+	Queue   string
+	Paused  bool
+	Stopped bool
+	// InFlight is the number of messages being processed
+	InFlight int
+	// Processed is the number of messages processed and acknowledged
+	Processed uint64
+	// Failed is the number of messages whose processing failed, including the ones retried
+	Failed uint64
+}
+
+// resumeMessaging is implemented by brokers that deliver messages outside the consumer workers,
+// so they deliver the messages held while the consumer was paused
+type resumeMessaging interface {
+	resume(c *consumer)
 }
 
 type consumerObserver struct {
@@ -27,7 +68,9 @@ func (o consumerObserver) Close() {
 	o.c.close()
 }
 
-func NewConsumer(qc QueueConsumer) {
+// NewConsumer starts consuming the queue of qc and returns the handle controlling the consumer.
+// The consumer is also stopped when the application shuts down.
+func NewConsumer(qc QueueConsumer, opts ...ConsumerOption) *ConsumerHandle {
 	if instance == nil {
 		logging.Fatal(context.Background()).Msg(messagingNotInitialized)
 	}
@@ -35,27 +78,182 @@ func NewConsumer(qc QueueConsumer) {
 	c := &consumer{
 		WaitGroup: sync.WaitGroup{},
 		queue:     qc.QueueName(),
+		topic:     qc.QueueName(),
 		fn:        qc.Consume,
 		done:      make(chan any),
+		options:   newConsumerOptions(opts...),
+		paused:    make(chan struct{}),
+		resumed:   make(chan struct{}),
+	}
+	close(c.resumed)
+
+	if tc, ok := qc.(TopicConsumer); ok {
+		c.topic = tc.TopicName()
 	}
 
 	observer.Attach(consumerObserver{c: c})
 	startListener(c)
+	if broker, ok := instance.(depthMessaging); ok {
+		go watchQueueDepth(broker, c)
+	}
+
+	return &ConsumerHandle{c: c}
+}
+
+// Pause stops handing messages to the workers. Messages being processed finish, and prefetched messages wait until Resume.
+func (h *ConsumerHandle) Pause() {
+	h.c.mu.Lock()
+	defer h.c.mu.Unlock()
+
+	if isClosed(h.c.paused) {
+		return
+	}
+	close(h.c.paused)
+	h.c.resumed = make(chan struct{})
+}
+
+// Resume hands messages to the workers again after Pause
+func (h *ConsumerHandle) Resume() {
+	h.c.mu.Lock()
+	if isClosed(h.c.resumed) {
+		h.c.mu.Unlock()
+		return
+	}
+	close(h.c.resumed)
+	h.c.paused = make(chan struct{})
+	h.c.mu.Unlock()
+
+	if broker, ok := instance.(resumeMessaging); ok {
+		broker.resume(h.c)
+	}
+}
+
+// Stop stops receiving messages and waits until the in-flight messages are processed,
+// or returns the context error when ctx is done first. Stopped consumers can not be started again.
+func (h *ConsumerHandle) Stop(ctx context.Context) error {
+	drained := make(chan struct{})
+	go func() {
+		h.c.close()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// Stats returns the state and counters of the consumer
+func (h *ConsumerHandle) Stats() ConsumerStats {
+	return ConsumerStats{
+		Queue:     h.c.queue,
+		Paused:    h.c.isPaused(),
+		Stopped:   h.c.isCanceled(),
+		InFlight:  int(h.c.inFlight.Load()),
+		Processed: h.c.processed.Load(),
+		Failed:    h.c.failed.Load(),
+	}
 }
 
+// startListener starts the consumer workers. Each message taken from the provider is tracked in the consumer
+// wait group until it is processed, so closing the consumer drains the in-flight messages.
+// Messages with an ordering key are always processed by the same worker, so messages sharing a key are processed in order.
 func startListener(c *consumer) {
 	ch := createConsumer(c)
+	c.messages = ch
+	work := make(chan *ProviderMessage, c.options.MaxInFlight-c.options.Concurrency)
+	keyedWork := make([]chan *ProviderMessage, c.options.Concurrency)
+	inFlight := make(chan struct{}, c.options.MaxInFlight)
+
+	for i := range c.options.Concurrency {
+		keyedWork[i] = make(chan *ProviderMessage, c.options.MaxInFlight)
+		go func() {
+			work, keyed := work, keyedWork[i]
+			for work != nil || keyed != nil {
+				var msg *ProviderMessage
+				var ok bool
+				select {
+				case msg, ok = <-keyed:
+					if !ok {
+						keyed = nil
+						continue
+					}
+				case msg, ok = <-work:
+					if !ok {
+						work = nil
+						continue
+					}
+				}
+
+				processMessage(c, msg)
+				<-inFlight
+				c.Done()
+			}
+		}()
+	}
 
 	go func() {
+		// closing the work channels stops the workers once they have processed the messages handed to them
+		defer func() {
+			close(work)
+			for _, keyed := range keyedWork {
+				close(keyed)
+			}
+		}()
+
 		for {
-			msg := <-ch
-			processMessage(c, msg)
+			c.mu.Lock()
+			paused, resumed := c.paused, c.resumed
+			c.mu.Unlock()
+
+			select {
+			case <-c.done:
+				return
+			case <-resumed:
+			}
+
+			select {
+			case <-c.done:
+				return
+			case inFlight <- struct{}{}:
+			}
+
+			select {
+			case <-c.done:
+				<-inFlight
+				return
+			case <-paused:
+				<-inFlight
+			case msg := <-ch:
+				if !c.track() {
+					<-inFlight
+					requeueMessage(msg)
+					return
+				}
+
+				if msg.key != "" {
+					keyedWork[getKeyWorker(msg.key, len(keyedWork))] <- msg
+				} else {
+					work <- msg
+				}
+			}
 		}
 	}()
 }
 
+// getKeyWorker returns the index of the worker that processes the messages with the given key
+func getKeyWorker(key string, workers int) int {
+	h := fnv.New32a()
+	_, _ = h.Write([]byte(key))
+
+	return int(h.Sum32() % uint32(workers))
+}
+
 func processMessage(c *consumer, msg *ProviderMessage) {
 	ctxRoot := context.WithValue(context.Background(), logging.CorrelationIDParam, msg.CorrelationID)
+	ctxRoot = monitoring.ExtractTraceContext(ctxRoot, msg.traceContext)
 
 	txn, ctx := monitoring.StartTransaction(ctxRoot, fmt.Sprintf(messagingConsumerTransaction, c.queue), colibrimonitoringbase.SpanKindConsumer)
 	monitoring.AddTransactionAttribute(txn, logging.CorrelationIDParam, msg.CorrelationID)
@@ -64,11 +262,32 @@ func processMessage(c *consumer, msg *ProviderMessage) {
 	monitoring.AddTransactionAttribute(txn, "span.kind", "CONSUMER")
 	defer monitoring.EndTransactionSegment(txn)
 
-	msg.AuthContext.SetInContext(ctx)
+	err := verifySignature(ctx, c.options.RequireSignature, msg)
+	if err == nil {
+		msg.AuthContext.SetInContext(ctx)
+	}
+	ctx = withRequest(ctx, msg, c.options.Reply)
+
+	c.inFlight.Add(1)
+	defer c.inFlight.Add(-1)
+	messagesConsumed.WithLabelValues(c.queue, msg.Action).Inc()
+	defer func(start time.Time) {
+		messageProcessingDuration.WithLabelValues(c.queue, msg.Action).Observe(time.Since(start).Seconds())
+	}(time.Now())
 
-	if err := c.fn(ctx, msg); err != nil {
+	if err == nil {
+		err = resolveClaimCheck(ctx, msg)
+	}
+	if err == nil {
+		err = decryptMessage(ctx, msg)
+	}
+	if err == nil {
+		err = c.fn(ctx, msg)
+	}
+	if err != nil {
+		c.failed.Add(1)
 		logging.Error(ctx).Err(err).Msgf(couldNotProcessMsg, msg.ID)
-		if err := msg.Nack(false, err); err != nil {
+		if err := rejectMessage(c, msg, err); err != nil {
 			logging.Error(ctx).Err(err).Msgf("error sending nack for message %s", msg.ID)
 		}
 		monitoring.NoticeError(txn, err)
@@ -77,11 +296,35 @@ func processMessage(c *consumer, msg *ProviderMessage) {
 
 	if err := msg.Ack(); err != nil {
 		logging.Error(ctx).Err(err).Msgf("error sending ack for message %s", msg.ID)
+	} else {
+		c.processed.Add(1)
+		messagesAcked.WithLabelValues(c.queue, msg.Action).Inc()
+		releaseClaimCheck(ctx, msg)
 	}
 
 	logging.Debug(ctx).Msgf("message %s processed", msg.ID)
 }
 
+// rejectMessage redelivers the message after the retry policy backoff while it has attempts left,
+// or sends it to the DLQ otherwise or when the error is permanent.
+// Messages in progress in another worker are redelivered after the in-progress lease, whatever the retry policy.
+func rejectMessage(c *consumer, msg *ProviderMessage, err error) error {
+	var inProgress inProgressError
+	if errors.As(err, &inProgress) {
+		messagesNacked.WithLabelValues(c.queue, msg.Action).Inc()
+		return msg.deferDelivery(inProgress.lease, err)
+	}
+
+	policy := c.options.Retry
+	if !IsPermanentError(err) && policy.ShouldRetry(msg.Attempt()) {
+		messagesNacked.WithLabelValues(c.queue, msg.Action).Inc()
+		return msg.NackWithDelay(policy.Backoff(msg.Attempt()), err)
+	}
+
+	messagesDeadLettered.WithLabelValues(c.queue, msg.Action).Inc()
+	return msg.Nack(false, err)
+}
+
 func createConsumer(c *consumer) chan *ProviderMessage {
 	ctx := context.Background()
 	ch, err := instance.consumer(ctx, c)
@@ -93,10 +336,83 @@ func createConsumer(c *consumer) chan *ProviderMessage {
 	return ch
 }
 
+// close stops the consumer and waits for the in-flight messages. It is called by Stop and on shutdown, so it may run twice.
+// The messages the provider buffered for the workers are requeued once the provider has stopped receiving.
 func (c *consumer) close() {
-	logging.Info(context.Background()).Msgf(closingQueueConsumer, c.queue)
-	close(c.done)
+	c.mu.Lock()
+	if !c.isCanceled() {
+		logging.Info(context.Background()).Msgf(closingQueueConsumer, c.queue)
+		close(c.done)
+	}
+	c.mu.Unlock()
 	c.Wait()
+
+	for {
+		select {
+		case msg := <-c.messages:
+			requeueMessage(msg)
+		default:
+			c.release()
+			return
+		}
+	}
+}
+
+// onStopped registers a function run once the consumer stopped and handed back its unprocessed messages
+func (c *consumer) onStopped(fn func()) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.releases = append(c.releases, fn)
+}
+
+// release runs the functions registered with onStopped, once
+func (c *consumer) release() {
+	c.mu.Lock()
+	releases := c.releases
+	c.releases = nil
+	c.mu.Unlock()
+
+	for _, fn := range releases {
+		fn()
+	}
+}
+
+// requeueMessage hands a message that was received but not processed back to the broker for redelivery,
+// keeping its attempt count where the broker allows it, since it was never processed
+func requeueMessage(msg *ProviderMessage) {
+	if err := msg.deferDelivery(0, nil); err != nil {
+		logging.Error(context.Background()).Err(err).Msgf("error sending nack for message %s", msg.ID)
+	}
+}
+
+// deliver sends the message to the workers, returning false when the consumer is closing
+func (c *consumer) deliver(ch chan<- *ProviderMessage, msg *ProviderMessage) bool {
+	select {
+	case ch <- msg:
+		return true
+	case <-c.done:
+		return false
+	}
+}
+
+// track adds an in-flight message to the consumer wait group, returning false when the consumer is closing
+func (c *consumer) track() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.isCanceled() {
+		return false
+	}
+	c.Add(1)
+	return true
+}
+
+func (c *consumer) isPaused() bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return isClosed(c.paused)
 }
 
 func (c *consumer) isCanceled() bool {
@@ -107,3 +423,13 @@ func (c *consumer) isCanceled() bool {
 		return false
 	}
 }
+
+// isClosed reports whether the channel is closed, without blocking
+func isClosed(ch chan struct{}) bool {
+	select {
+	case <-ch:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,88 @@
+package messaging
+
+// ConsumerOptions holds the optional settings of a consumer
+type ConsumerOptions struct {
+	// Retry is the policy applied when the consumer fails to process a message.
+	Retry RetryPolicy
+	// Concurrency is the number of workers processing messages in parallel. Values lower than 1 mean 1.
+	Concurrency int
+	// Prefetch is how many messages the provider fetches ahead of processing: the RabbitMQ QoS, the SQS batch size
+	// (up to 10), the NATS pull batch and the size of the buffer between the provider and the workers.
+	// Values lower than 1 mean 1.
+	Prefetch int
+	// MaxInFlight caps the messages handed to the workers and not yet acknowledged, including the ones being processed.
+	// Values lower than Concurrency mean Concurrency.
+	MaxInFlight int
+	// RequireSignature rejects unsigned messages. Signed messages are verified even when it is false.
+	RequireSignature bool
+	// Reply holds the producer options ReplyTo seals the replies with, such as WithEncryption, WithSigning and
+	// WithClaimCheck.
+	Reply []ProducerOption
+}
+
+// ConsumerOption configures optional behaviour of a consumer
+type ConsumerOption func(o *ConsumerOptions)
+
+// WithRetryPolicy sets the retry policy used when the consumer fails to process a message
+func WithRetryPolicy(policy RetryPolicy) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.Retry = policy
+	}
+}
+
+// WithConcurrency sets the number of workers processing messages in parallel
+func WithConcurrency(concurrency int) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.Concurrency = concurrency
+	}
+}
+
+// WithPrefetch sets how many messages the provider fetches ahead of processing
+func WithPrefetch(prefetch int) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.Prefetch = prefetch
+	}
+}
+
+// WithMaxInFlight sets the maximum number of messages handed to the workers and not yet acknowledged
+func WithMaxInFlight(maxInFlight int) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.MaxInFlight = maxInFlight
+	}
+}
+
+// WithSignatureVerification rejects the messages not signed by their origin before they are consumed,
+// sending them to the dead-letter queue
+func WithSignatureVerification() ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.RequireSignature = true
+	}
+}
+
+// WithReplyOptions sets how ReplyTo seals the replies sent by the consumer
+func WithReplyOptions(opts ...ProducerOption) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		o.Reply = opts
+	}
+}
+
+// WithConsumerOptions replaces every consumer option with the given ones
+func WithConsumerOptions(options ConsumerOptions) ConsumerOption {
+	return func(o *ConsumerOptions) {
+		*o = options
+	}
+}
+
+// newConsumerOptions returns the consumer options with defaults applied
+func newConsumerOptions(opts ...ConsumerOption) ConsumerOptions {
+	options := ConsumerOptions{Retry: DefaultRetryPolicy}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	options.Concurrency = max(options.Concurrency, 1)
+	options.Prefetch = max(options.Prefetch, 1)
+	options.MaxInFlight = max(options.MaxInFlight, options.Concurrency)
+
+	return options
+}
@@ -0,0 +1,34 @@
+package messaging
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConsumerOptions(t *testing.T) {
+	t.Run("Should apply defaults", func(t *testing.T) {
+		options := newConsumerOptions()
+
+		assert.Equal(t, DefaultRetryPolicy, options.Retry)
+		assert.Equal(t, 1, options.Concurrency)
+		assert.Equal(t, 1, options.Prefetch)
+		assert.Equal(t, 1, options.MaxInFlight)
+	})
+
+	t.Run("Should keep max in flight at least equal to concurrency", func(t *testing.T) {
+		options := newConsumerOptions(WithConcurrency(5), WithMaxInFlight(2), WithPrefetch(10))
+
+		assert.Equal(t, 5, options.Concurrency)
+		assert.Equal(t, 10, options.Prefetch)
+		assert.Equal(t, 5, options.MaxInFlight)
+	})
+
+	t.Run("Should replace every option with consumer options", func(t *testing.T) {
+		options := newConsumerOptions(WithConcurrency(5), WithConsumerOptions(ConsumerOptions{Prefetch: 3, MaxInFlight: 8}))
+
+		assert.Equal(t, 1, options.Concurrency)
+		assert.Equal(t, 3, options.Prefetch)
+		assert.Equal(t, 8, options.MaxInFlight)
+	})
+}
@@ -0,0 +1,216 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newConsumerTest starts a consumer that is stopped when the test finishes, so its workers do not outlive the test
+func newConsumerTest(t *testing.T, qc QueueConsumer, opts ...ConsumerOption) *ConsumerHandle {
+	consumer := NewConsumer(qc, opts...)
+	t.Cleanup(func() { _ = consumer.Stop(context.Background()) })
+	return consumer
+}
+
+// originalMessageTest records the acks and nacks of a message
+type originalMessageTest struct {
+	nacks *[]bool
+}
+
+func (m originalMessageTest) Ack() error {
+	return nil
+}
+
+func (m originalMessageTest) Nack(requeue bool, _ error) error {
+	*m.nacks = append(*m.nacks, requeue)
+	return nil
+}
+
+func TestConsumerHandle(t *testing.T) {
+	useMemoryMessaging(t)
+	publish := func(topic string, name string) {
+		assert.NoError(t, NewProducer(topic).Publish(context.Background(), "create", userMessageTest{Name: name}))
+	}
+
+	t.Run("Should hold messages while paused and process them on resume", func(t *testing.T) {
+		received := make([]string, 0)
+		consumer := newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				var user userMessageTest
+				_ = message.DecodeMessage(&user)
+				received = append(received, user.Name)
+				return nil
+			},
+			qName: "CONSUMER_PAUSE_QUEUE",
+		})
+
+		consumer.Pause()
+		publish("CONSUMER_PAUSE_QUEUE", "User 1")
+		publish("CONSUMER_PAUSE_QUEUE", "User 2")
+		paused := consumer.Stats()
+		consumer.Resume()
+
+		assert.True(t, paused.Paused)
+		assert.Equal(t, []string{"User 1", "User 2"}, received)
+		assert.False(t, consumer.Stats().Paused)
+	})
+
+	t.Run("Should count processed and failed messages", func(t *testing.T) {
+		consumer := newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				var user userMessageTest
+				_ = message.DecodeMessage(&user)
+				if user.Name == "Invalid" {
+					return NewPermanentError(errors.New("invalid user"))
+				}
+				return nil
+			},
+			qName: "CONSUMER_STATS_QUEUE",
+		})
+
+		publish("CONSUMER_STATS_QUEUE", "User")
+		publish("CONSUMER_STATS_QUEUE", "Invalid")
+
+		assert.Equal(t, ConsumerStats{Queue: "CONSUMER_STATS_QUEUE", Processed: 1, Failed: 1}, consumer.Stats())
+	})
+
+	t.Run("Should stop receiving messages after stop", func(t *testing.T) {
+		var processed atomic.Int32
+		consumer := newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				processed.Add(1)
+				return nil
+			},
+			qName: "CONSUMER_STOP_QUEUE",
+		})
+		publish("CONSUMER_STOP_QUEUE", "User 1")
+
+		err := consumer.Stop(context.Background())
+		publish("CONSUMER_STOP_QUEUE", "User 2")
+
+		assert.NoError(t, err)
+		assert.True(t, consumer.Stats().Stopped)
+		assert.Equal(t, int32(1), processed.Load())
+		assert.NoError(t, consumer.Stop(context.Background()))
+	})
+
+	t.Run("Should drain the in-flight messages of an asynchronous consumer on stop", func(t *testing.T) {
+		Memory().SetSynchronous(false)
+		defer Memory().SetSynchronous(true)
+		started := make(chan struct{})
+		var processed atomic.Int32
+		consumer := newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				close(started)
+				time.Sleep(50 * time.Millisecond)
+				processed.Add(1)
+				return nil
+			},
+			qName: "CONSUMER_DRAIN_QUEUE",
+		})
+		publish("CONSUMER_DRAIN_QUEUE", "User")
+		<-started
+
+		inFlight := consumer.Stats().InFlight
+		err := consumer.Stop(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, 1, inFlight)
+		assert.Equal(t, int32(1), processed.Load())
+		assert.Len(t, Memory().Acked("CONSUMER_DRAIN_QUEUE"), 1)
+	})
+
+	t.Run("Should return the context error when the in-flight messages are not drained in time", func(t *testing.T) {
+		Memory().SetSynchronous(false)
+		defer Memory().SetSynchronous(true)
+		started := make(chan struct{})
+		release := make(chan struct{})
+		consumer := newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				close(started)
+				<-release
+				return nil
+			},
+			qName: "CONSUMER_STOP_TIMEOUT_QUEUE",
+		})
+		publish("CONSUMER_STOP_TIMEOUT_QUEUE", "User")
+		<-started
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		err := consumer.Stop(ctx)
+		close(release)
+
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.NoError(t, consumer.Stop(context.Background()))
+	})
+
+	t.Run("Should keep the messages not processed by a stopped consumer for the next one without counting an attempt", func(t *testing.T) {
+		Memory().SetSynchronous(false).SetMaxAttempts(1)
+		defer func() { Memory().SetSynchronous(true).SetMaxAttempts(memoryDefaultMaxAttempts) }()
+		started := make(chan struct{})
+		release := make(chan struct{})
+		first := NewConsumer(&queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				close(started)
+				<-release
+				return nil
+			},
+			qName: "CONSUMER_STOP_REQUEUE_QUEUE",
+		}, WithConcurrency(1))
+		for _, name := range []string{"User 1", "User 2", "User 3"} {
+			publish("CONSUMER_STOP_REQUEUE_QUEUE", name)
+		}
+		<-started
+
+		stopped := make(chan error)
+		go func() { stopped <- first.Stop(context.Background()) }()
+		assert.Eventually(t, func() bool { return first.Stats().Stopped }, time.Second, time.Millisecond)
+		close(release)
+		assert.NoError(t, <-stopped)
+
+		var mu sync.Mutex
+		attempts := make([]int, 0)
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				mu.Lock()
+				defer mu.Unlock()
+				attempts = append(attempts, message.Attempt())
+				return nil
+			},
+			qName: "CONSUMER_STOP_REQUEUE_QUEUE",
+		})
+
+		assert.Eventually(t, func() bool { return len(Memory().Acked("CONSUMER_STOP_REQUEUE_QUEUE")) == 3 }, time.Second, time.Millisecond)
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, []int{1, 1}, attempts)
+		assert.Empty(t, Memory().DeadLetters("CONSUMER_STOP_REQUEUE_QUEUE"))
+	})
+
+	t.Run("Should requeue the messages received from the provider and not processed on stop", func(t *testing.T) {
+		nacks := make([]bool, 0)
+		c := &consumer{done: make(chan any), messages: make(chan *ProviderMessage, 2)}
+		for range 2 {
+			msg := &ProviderMessage{}
+			msg.addOriginBrokerNotification(originalMessageTest{nacks: &nacks})
+			c.messages <- msg
+		}
+
+		releasedAfter := -1
+		c.onStopped(func() { releasedAfter = len(nacks) })
+
+		err := (&ConsumerHandle{c: c}).Stop(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, []bool{true, true}, nacks)
+		assert.Empty(t, c.messages)
+		assert.Equal(t, 2, releasedAfter)
+	})
+}
@@ -0,0 +1,141 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"strconv"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+)
+
+const deadLetterReleased = "could not return message %s to the dead-letter queue of %s"
+
+var (
+	// ErrDeadLettersNotSupported is returned when the broker can not read its dead-letter queues
+	ErrDeadLettersNotSupported = errors.New("dead-letter queues not supported by the broker")
+	// ErrReplayToQueueNotSupported is returned when the broker can only replay messages to a topic
+	ErrReplayToQueueNotSupported = errors.New("replay to queue not supported by the broker, replay to a topic instead")
+)
+
+// DeadLetter is a message read from the dead-letter queue of a consumer queue.
+// It is held by the reader until it is removed, released or replayed.
+type DeadLetter struct {
+	// Message is the dead-lettered message
+	Message *ProviderMessage
+	// Queue is the consumer queue that dead-lettered the message
+	Queue string
+	// Reason is the failure reason of the last attempt, when the broker kept it
+	Reason string
+	// Attempts is the number of times the message was delivered before it was dead-lettered
+	Attempts int
+
+	remove  func(ctx context.Context) error
+	release func(ctx context.Context) error
+	requeue func(ctx context.Context) error
+	// hold renews the hold of brokers that only hold messages for a while, so it does not expire before the replay
+	hold     func(ctx context.Context) error
+	resolved bool
+}
+
+// deadLetterMessaging is implemented by brokers that read the dead-letter queue of a consumer queue.
+// The messages read are held, invisible to other readers, until they are removed or released.
+type deadLetterMessaging interface {
+	readDeadLetters(ctx context.Context, queue string, limit int) ([]*DeadLetter, error)
+}
+
+// ReadDeadLetters reads up to limit messages from the dead-letter queue of the queue without removing them.
+// Every message returned must be removed, released or replayed.
+func ReadDeadLetters(ctx context.Context, queue string, limit int) ([]*DeadLetter, error) {
+	if instance == nil {
+		logging.Fatal(context.Background()).Msg(messagingNotInitialized)
+	}
+	broker, ok := instance.(deadLetterMessaging)
+	if !ok {
+		return nil, ErrDeadLettersNotSupported
+	}
+
+	return broker.readDeadLetters(ctx, queue, limit)
+}
+
+// Remove deletes the message from the dead-letter queue
+func (d *DeadLetter) Remove(ctx context.Context) error {
+	if d.resolved {
+		return nil
+	}
+	d.resolved = true
+	return d.remove(ctx)
+}
+
+// Release returns the message to the dead-letter queue, so it can be read again
+func (d *DeadLetter) Release(ctx context.Context) error {
+	if d.resolved {
+		return nil
+	}
+	d.resolved = true
+	return d.release(ctx)
+}
+
+// ReplayToQueue sends the message back to the consumer queue and removes it from the dead-letter queue
+func (d *DeadLetter) ReplayToQueue(ctx context.Context) error {
+	if d.requeue == nil {
+		return ErrReplayToQueueNotSupported
+	}
+
+	if err := d.renewHold(ctx); err != nil {
+		return err
+	}
+	if err := d.requeue(ctx); err != nil {
+		return err
+	}
+
+	return d.Remove(ctx)
+}
+
+// ReplayToTopic publishes the message to the topic and removes it from the dead-letter queue.
+// The message is republished as read, with its id, encoding, headers and payload, so it stays encrypted, offloaded
+// and signed as the original producer sealed it.
+func (d *DeadLetter) ReplayToTopic(ctx context.Context, topic string) error {
+	msg := *d.Message
+	msg.Headers = maps.Clone(d.Message.Headers)
+	delete(msg.Headers, headerFailureReason)
+	delete(msg.Headers, headerAttempts)
+	msg.traceContext = make(map[string]string)
+
+	if err := d.renewHold(ctx); err != nil {
+		return err
+	}
+	if err := instance.producer(ctx, NewProducer(topic), &msg); err != nil {
+		return err
+	}
+
+	return d.Remove(ctx)
+}
+
+// renewHold renews the hold of the message before it is replayed, so no other reader receives it meanwhile
+func (d *DeadLetter) renewHold(ctx context.Context) error {
+	if d.hold == nil {
+		return nil
+	}
+
+	return d.hold(ctx)
+}
+
+// releaseDeadLetters releases the dead letters read before a reading error
+func releaseDeadLetters(ctx context.Context, deadLetters []*DeadLetter) {
+	for _, d := range deadLetters {
+		if err := d.Release(ctx); err != nil {
+			logging.Error(ctx).Err(err).Msgf(deadLetterReleased, d.Message.ID, d.Queue)
+		}
+	}
+}
+
+// getDeadLetterAttempts parses the attempts attribute kept with a dead-lettered message
+func getDeadLetterAttempts(value string) int {
+	attempts, err := strconv.Atoi(value)
+	if err != nil {
+		return 0
+	}
+
+	return attempts
+}
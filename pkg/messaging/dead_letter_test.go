@@ -0,0 +1,46 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDeadLetterHold(t *testing.T) {
+	newDeadLetter := func(calls *[]string, holdErr error) *DeadLetter {
+		record := func(call string, err error) func(ctx context.Context) error {
+			return func(ctx context.Context) error {
+				*calls = append(*calls, call)
+				return err
+			}
+		}
+
+		return &DeadLetter{
+			Message: NewProviderMessage(context.Background(), "DEAD_LETTER_HOLD", nil),
+			remove:  record("remove", nil),
+			release: record("release", nil),
+			requeue: record("requeue", nil),
+			hold:    record("hold", holdErr),
+		}
+	}
+
+	t.Run("Should renew the hold before replaying the message", func(t *testing.T) {
+		calls := make([]string, 0)
+
+		err := newDeadLetter(&calls, nil).ReplayToQueue(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"hold", "requeue", "remove"}, calls)
+	})
+
+	t.Run("Should not replay the message when the hold can not be renewed", func(t *testing.T) {
+		calls := make([]string, 0)
+
+		err := newDeadLetter(&calls, errors.New("receipt handle expired")).ReplayToQueue(context.Background())
+
+		assert.EqualError(t, err, "receipt handle expired")
+		assert.Equal(t, []string{"hold"}, calls)
+	})
+}
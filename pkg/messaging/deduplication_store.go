@@ -0,0 +1,153 @@
+package messaging
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/database/cacheDB"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/database/sqlDB"
+)
+
+const (
+	deduplicationInProgress    = "in-progress"
+	deduplicationProcessed     = "processed"
+	deduplicationCachePrefix   = "idempotency"
+	deduplicationDefaultTable  = "colibri_processed_messages"
+	deduplicationCreateTable   = "CREATE TABLE IF NOT EXISTS %s (message_key TEXT PRIMARY KEY, status TEXT NOT NULL, expires_at TIMESTAMPTZ NOT NULL)"
+	deduplicationBeginQuery    = "INSERT INTO %[1]s (message_key, status, expires_at) VALUES ($1, $2, now() + $3 * interval '1 millisecond') ON CONFLICT (message_key) DO UPDATE SET status = EXCLUDED.status, expires_at = EXCLUDED.expires_at WHERE %[1]s.expires_at < now() RETURNING status"
+	deduplicationStatusQuery   = "SELECT status FROM %s WHERE message_key = $1"
+	deduplicationCompleteQuery = "INSERT INTO %s (message_key, status, expires_at) VALUES ($1, $2, now() + $3 * interval '1 millisecond') ON CONFLICT (message_key) DO UPDATE SET status = EXCLUDED.status, expires_at = EXCLUDED.expires_at"
+	deduplicationReleaseQuery  = "DELETE FROM %s WHERE message_key = $1 AND status = $2"
+	deduplicationPurgeQuery    = "DELETE FROM %s WHERE expires_at < now()"
+)
+
+// CacheDeduplicationStore records processed messages in Redis with cacheDB
+type CacheDeduplicationStore struct{}
+
+// NewCacheDeduplicationStore returns a DeduplicationStore backed by cacheDB
+func NewCacheDeduplicationStore() *CacheDeduplicationStore {
+	return &CacheDeduplicationStore{}
+}
+
+// Begin marks the message as in progress when its key does not exist
+func (s *CacheDeduplicationStore) Begin(ctx context.Context, key string, lease time.Duration) (DeduplicationState, error) {
+	saved, err := s.cache(key, lease).SetIfAbsent(ctx, deduplicationInProgress)
+	if err != nil || saved {
+		return DeduplicationNew, err
+	}
+
+	status, err := s.cache(key, lease).One(ctx)
+	if err != nil {
+		return DeduplicationNew, err
+	}
+
+	return getDeduplicationState(status), nil
+}
+
+// Complete marks the message as processed for the retention duration
+func (s *CacheDeduplicationStore) Complete(ctx context.Context, key string, retention time.Duration) error {
+	return s.cache(key, retention).Set(ctx, deduplicationProcessed)
+}
+
+// Release removes the in-progress marker of the message
+func (s *CacheDeduplicationStore) Release(ctx context.Context, key string) error {
+	return s.cache(key, 0).Del(ctx)
+}
+
+func (s *CacheDeduplicationStore) cache(key string, ttl time.Duration) *cacheDB.Cache[string] {
+	return cacheDB.NewCache[string](fmt.Sprintf("%s::%s", deduplicationCachePrefix, key), ttl)
+}
+
+// SQLDeduplicationStore records processed messages in a Postgres table with sqlDB.
+// The table is created on first use. Inside a sqlDB transaction, Complete is part of that transaction.
+type SQLDeduplicationStore struct {
+	table       string
+	createTable sqlTableCreator
+}
+
+// NewSQLDeduplicationStore returns a DeduplicationStore backed by the given table, or colibri_processed_messages when empty
+func NewSQLDeduplicationStore(table string) *SQLDeduplicationStore {
+	if table == "" {
+		table = deduplicationDefaultTable
+	}
+
+	return &SQLDeduplicationStore{table: table}
+}
+
+// Begin inserts the in-progress marker, replacing expired records of the same message
+func (s *SQLDeduplicationStore) Begin(ctx context.Context, key string, lease time.Duration) (DeduplicationState, error) {
+	if err := s.ensureTable(ctx); err != nil {
+		return DeduplicationNew, err
+	}
+
+	inserted, err := sqlDB.NewQuery[string](ctx, fmt.Sprintf(deduplicationBeginQuery, s.table), key, deduplicationInProgress, lease.Milliseconds()).One()
+	if err != nil || inserted != nil {
+		return DeduplicationNew, err
+	}
+
+	status, err := sqlDB.NewQuery[string](ctx, fmt.Sprintf(deduplicationStatusQuery, s.table), key).One()
+	if err != nil {
+		return DeduplicationNew, err
+	}
+
+	return getDeduplicationState(status), nil
+}
+
+// Complete marks the message as processed for the retention duration
+func (s *SQLDeduplicationStore) Complete(ctx context.Context, key string, retention time.Duration) error {
+	return sqlDB.NewStatement(ctx, fmt.Sprintf(deduplicationCompleteQuery, s.table), key, deduplicationProcessed, retention.Milliseconds()).Execute()
+}
+
+// Release removes the in-progress marker of the message
+func (s *SQLDeduplicationStore) Release(ctx context.Context, key string) error {
+	return sqlDB.NewStatement(ctx, fmt.Sprintf(deduplicationReleaseQuery, s.table), key, deduplicationInProgress).Execute()
+}
+
+// Purge deletes the expired records
+func (s *SQLDeduplicationStore) Purge(ctx context.Context) error {
+	return sqlDB.NewStatement(ctx, fmt.Sprintf(deduplicationPurgeQuery, s.table)).Execute()
+}
+
+func (s *SQLDeduplicationStore) ensureTable(ctx context.Context) error {
+	return s.createTable.ensure(ctx, fmt.Sprintf(deduplicationCreateTable, s.table))
+}
+
+// sqlTableCreator runs the statements creating a table until they succeed once.
+// A failure is not kept, so the next use tries again.
+type sqlTableCreator struct {
+	mu      sync.Mutex
+	created bool
+}
+
+// ensure runs the statements when the table was not created yet.
+// They run outside the caller's sqlDB transaction and cancellation, so a rolled back transaction does not drop the table.
+func (t *sqlTableCreator) ensure(ctx context.Context, statements ...string) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if t.created {
+		return nil
+	}
+
+	ctx = context.WithValue(context.WithoutCancel(ctx), sqlDB.SqlTxContext, nil)
+	for _, statement := range statements {
+		if err := sqlDB.NewStatement(ctx, statement).Execute(); err != nil {
+			return err
+		}
+	}
+
+	t.created = true
+	return nil
+}
+
+// getDeduplicationState maps a stored status to a DeduplicationState.
+// A missing status means the marker expired meanwhile, so the message is reported as in progress and retried.
+func getDeduplicationState(status *string) DeduplicationState {
+	if status != nil && *status == deduplicationProcessed {
+		return DeduplicationProcessed
+	}
+
+	return DeduplicationInProgress
+}
@@ -0,0 +1,179 @@
+// Package dlq lists the messages of the dead-letter queues of consumer queues and replays them.
+package dlq
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/messaging"
+)
+
+const (
+	// DefaultLimit is the number of dead letters read when no limit is given
+	DefaultLimit = 100
+	// DefaultRatePerSecond is the number of messages replayed per second when no rate is given
+	DefaultRatePerSecond = 10
+
+	couldNotReleaseDeadLetter = "could not release dead letter %s of queue %s"
+	couldNotReplayDeadLetter  = "could not replay dead letter %s of queue %s"
+)
+
+// Filter selects dead letters by the fields of their message. Empty fields match every message.
+type Filter struct {
+	// Action matches the message action
+	Action string
+	// Origin matches the application that published the message
+	Origin string
+	// From matches messages that occurred at or after it
+	From time.Time
+	// To matches messages that occurred before it
+	To time.Time
+	// IDs matches the message ids
+	IDs []string
+}
+
+// ReplayOptions configures Replay
+type ReplayOptions struct {
+	// Filter selects the dead letters replayed. The others are returned to the dead-letter queue.
+	Filter Filter
+	// Limit is the number of dead letters read, DefaultLimit when zero
+	Limit int
+	// Topic receives the replayed messages. When empty, they are sent back to the consumer queue.
+	Topic string
+	// RatePerSecond limits the messages replayed per second, DefaultRatePerSecond when zero
+	RatePerSecond float64
+	// DryRun returns the dead letters that would be replayed, leaving them in the dead-letter queue
+	DryRun bool
+}
+
+// ReplayResult is the outcome of Replay
+type ReplayResult struct {
+	// Replayed holds the dead letters replayed, or that would be replayed on a dry run
+	Replayed []*messaging.DeadLetter
+	// Skipped is the number of dead letters read that did not match the filter
+	Skipped int
+	// Failed holds the dead letters that could not be replayed, which are returned to the dead-letter queue
+	Failed []ReplayFailure
+}
+
+// ReplayFailure is a dead letter that could not be replayed
+type ReplayFailure struct {
+	DeadLetter *messaging.DeadLetter
+	Err        error
+}
+
+// Match reports whether the message matches the filter
+func (f Filter) Match(msg *messaging.ProviderMessage) bool {
+	switch {
+	case f.Action != "" && msg.Action != f.Action:
+		return false
+	case f.Origin != "" && msg.Origin != f.Origin:
+		return false
+	case !f.From.IsZero() && msg.OccurredAt.Before(f.From):
+		return false
+	case !f.To.IsZero() && !msg.OccurredAt.Before(f.To):
+		return false
+	case len(f.IDs) > 0 && !slices.Contains(f.IDs, msg.ID.String()):
+		return false
+	default:
+		return true
+	}
+}
+
+// List reads up to limit dead letters of the queue and returns the ones matching the filter.
+// Every dead letter read is returned to the dead-letter queue.
+func List(ctx context.Context, queue string, filter Filter, limit int) ([]*messaging.DeadLetter, error) {
+	deadLetters, err := messaging.ReadDeadLetters(ctx, queue, getLimit(limit))
+	if err != nil {
+		return nil, err
+	}
+
+	matched := make([]*messaging.DeadLetter, 0, len(deadLetters))
+	for _, d := range deadLetters {
+		if filter.Match(d.Message) {
+			matched = append(matched, d)
+		}
+		release(ctx, d)
+	}
+
+	return matched, nil
+}
+
+// Replay reads up to opts.Limit dead letters of the queue and replays the ones matching the filter to the topic,
+// or to the queue itself, at no more than opts.RatePerSecond messages per second.
+// Replayed messages are removed from the dead-letter queue and keep their id and correlation id.
+func Replay(ctx context.Context, queue string, opts ReplayOptions) (*ReplayResult, error) {
+	deadLetters, err := messaging.ReadDeadLetters(ctx, queue, getLimit(opts.Limit))
+	if err != nil {
+		return nil, err
+	}
+
+	rate := opts.RatePerSecond
+	if rate <= 0 {
+		rate = DefaultRatePerSecond
+	}
+	ticker := time.NewTicker(time.Duration(float64(time.Second) / rate))
+	defer ticker.Stop()
+
+	result := &ReplayResult{}
+	for i, d := range deadLetters {
+		if !opts.Filter.Match(d.Message) {
+			result.Skipped++
+			release(ctx, d)
+			continue
+		}
+
+		if opts.DryRun {
+			result.Replayed = append(result.Replayed, d)
+			release(ctx, d)
+			continue
+		}
+
+		if len(result.Replayed)+len(result.Failed) > 0 {
+			select {
+			case <-ticker.C:
+			case <-ctx.Done():
+				for _, rest := range deadLetters[i:] {
+					release(context.WithoutCancel(ctx), rest)
+				}
+				return result, ctx.Err()
+			}
+		}
+
+		if err := replay(ctx, d, opts.Topic); err != nil {
+			logging.Error(ctx).Err(err).Msgf(couldNotReplayDeadLetter, d.Message.ID, queue)
+			result.Failed = append(result.Failed, ReplayFailure{DeadLetter: d, Err: err})
+			release(ctx, d)
+			continue
+		}
+		result.Replayed = append(result.Replayed, d)
+	}
+
+	return result, nil
+}
+
+// replay sends the dead letter to the topic, or back to its queue when the topic is empty
+func replay(ctx context.Context, d *messaging.DeadLetter, topic string) error {
+	if topic == "" {
+		return d.ReplayToQueue(ctx)
+	}
+
+	return d.ReplayToTopic(ctx, topic)
+}
+
+// release returns the dead letter to the dead-letter queue, logging failures
+func release(ctx context.Context, d *messaging.DeadLetter) {
+	if err := d.Release(ctx); err != nil {
+		logging.Error(ctx).Err(err).Msgf(couldNotReleaseDeadLetter, d.Message.ID, d.Queue)
+	}
+}
+
+func getLimit(limit int) int {
+	if limit <= 0 {
+		return DefaultLimit
+	}
+
+	return limit
+}
@@ -0,0 +1,167 @@
+package dlq
+
+import (
+	"context"
+	"errors"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/test"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/messaging"
+	"github.com/stretchr/testify/assert"
+)
+
+type orderMessageTest struct {
+	Number int `json:"number"`
+}
+
+type queueConsumerTest struct {
+	failing atomic.Bool
+	qName   string
+}
+
+func (q *queueConsumerTest) Consume(_ context.Context, _ *messaging.ProviderMessage) error {
+	if q.failing.Load() {
+		return errors.New("order service unavailable")
+	}
+	return nil
+}
+
+func (q *queueConsumerTest) QueueName() string {
+	return q.qName
+}
+
+// deadLetterOrders consumes the queue with a failing consumer, so every order published ends in its dead-letter list
+func deadLetterOrders(t *testing.T, queue string, actions ...string) *queueConsumerTest {
+	consumer := &queueConsumerTest{qName: queue}
+	consumer.failing.Store(true)
+	handle := messaging.NewConsumer(consumer)
+	t.Cleanup(func() { _ = handle.Stop(context.Background()) })
+
+	for i, action := range actions {
+		assert.NoError(t, messaging.NewProducer(queue).Publish(context.Background(), action, orderMessageTest{Number: i}))
+	}
+	assert.Len(t, messaging.Memory().DeadLetters(queue), len(actions))
+
+	return consumer
+}
+
+func TestDeadLetters(t *testing.T) {
+	test.InitializeMemoryMessaging()
+	messaging.Initialize()
+
+	t.Run("Should list the dead letters matching the filter with their failure reason, keeping them in the queue", func(t *testing.T) {
+		deadLetterOrders(t, "DLQ_LIST_QUEUE", "create", "cancel", "create")
+
+		deadLetters, err := List(context.Background(), "DLQ_LIST_QUEUE", Filter{Action: "create"}, 0)
+
+		assert.NoError(t, err)
+		assert.Len(t, deadLetters, 2)
+		for _, d := range deadLetters {
+			assert.Equal(t, "create", d.Message.Action)
+			assert.Equal(t, "order service unavailable", d.Reason)
+			assert.Equal(t, "DLQ_LIST_QUEUE", d.Queue)
+		}
+		assert.Len(t, messaging.Memory().DeadLetters("DLQ_LIST_QUEUE"), 3)
+	})
+
+	t.Run("Should match messages by origin, time window and id", func(t *testing.T) {
+		occurredAt := time.Date(2026, 1, 10, 12, 0, 0, 0, time.UTC)
+		msg := &messaging.ProviderMessage{Origin: "orders", Action: "create", OccurredAt: occurredAt}
+		msg.ID[0] = 1
+
+		assert.True(t, Filter{Origin: "orders", From: occurredAt, To: occurredAt.Add(time.Hour)}.Match(msg))
+		assert.True(t, Filter{IDs: []string{msg.ID.String()}}.Match(msg))
+		assert.False(t, Filter{Origin: "payments"}.Match(msg))
+		assert.False(t, Filter{From: occurredAt.Add(time.Second)}.Match(msg))
+		assert.False(t, Filter{To: occurredAt}.Match(msg))
+		assert.False(t, Filter{IDs: []string{"other"}}.Match(msg))
+	})
+
+	t.Run("Should leave the dead letters in the queue on a dry run", func(t *testing.T) {
+		deadLetterOrders(t, "DLQ_DRY_RUN_QUEUE", "create", "create")
+
+		result, err := Replay(context.Background(), "DLQ_DRY_RUN_QUEUE", ReplayOptions{DryRun: true})
+
+		assert.NoError(t, err)
+		assert.Len(t, result.Replayed, 2)
+		assert.Len(t, messaging.Memory().DeadLetters("DLQ_DRY_RUN_QUEUE"), 2)
+		assert.Empty(t, messaging.Memory().Acked("DLQ_DRY_RUN_QUEUE"))
+	})
+
+	t.Run("Should replay the matching dead letters to their queue and remove them", func(t *testing.T) {
+		consumer := deadLetterOrders(t, "DLQ_REPLAY_QUEUE", "create", "cancel")
+		consumer.failing.Store(false)
+
+		result, err := Replay(context.Background(), "DLQ_REPLAY_QUEUE", ReplayOptions{Filter: Filter{Action: "create"}})
+
+		assert.NoError(t, err)
+		assert.Len(t, result.Replayed, 1)
+		assert.Equal(t, 1, result.Skipped)
+		assert.Empty(t, result.Failed)
+		remaining := messaging.Memory().DeadLetters("DLQ_REPLAY_QUEUE")
+		assert.Len(t, remaining, 1)
+		assert.Equal(t, "cancel", remaining[0].Message.Action)
+		acked := messaging.Memory().Acked("DLQ_REPLAY_QUEUE")
+		assert.Len(t, acked, 1)
+		assert.Equal(t, result.Replayed[0].Message.ID, acked[0].ID)
+	})
+
+	t.Run("Should replay the dead letters to another topic keeping their id", func(t *testing.T) {
+		deadLetterOrders(t, "DLQ_TOPIC_QUEUE", "create")
+
+		result, err := Replay(context.Background(), "DLQ_TOPIC_QUEUE", ReplayOptions{Topic: "DLQ_REPLAY_TOPIC"})
+
+		assert.NoError(t, err)
+		published := messaging.Memory().Published("DLQ_REPLAY_TOPIC")
+		assert.Len(t, published, 1)
+		assert.Equal(t, result.Replayed[0].Message.ID, published[0].ID)
+		assert.Empty(t, messaging.Memory().DeadLetters("DLQ_TOPIC_QUEUE"))
+	})
+
+	t.Run("Should replay signed dead letters to another topic keeping their encoding and signature", func(t *testing.T) {
+		messaging.SetSigningKeyProvider(messaging.SigningKeys{config.APP_NAME: []byte("secret of the app")})
+		t.Cleanup(func() { messaging.SetSigningKeyProvider(nil) })
+		consumer := &queueConsumerTest{qName: "DLQ_SIGNED_QUEUE"}
+		consumer.failing.Store(true)
+		handle := messaging.NewConsumer(consumer, messaging.WithSignatureVerification())
+		t.Cleanup(func() { _ = handle.Stop(context.Background()) })
+		target := messaging.NewConsumer(&queueConsumerTest{qName: "DLQ_SIGNED_TOPIC"}, messaging.WithSignatureVerification())
+		t.Cleanup(func() { _ = target.Stop(context.Background()) })
+		producer := messaging.NewProducer("DLQ_SIGNED_QUEUE", messaging.WithSigning(), messaging.WithEncoding(messaging.EncodingCloudEventsStructured))
+		assert.NoError(t, producer.Publish(context.Background(), "create", orderMessageTest{Number: 1}))
+
+		result, err := Replay(context.Background(), "DLQ_SIGNED_QUEUE", ReplayOptions{Topic: "DLQ_SIGNED_TOPIC"})
+
+		assert.NoError(t, err)
+		assert.Len(t, result.Replayed, 1)
+		assert.Len(t, messaging.Memory().Acked("DLQ_SIGNED_TOPIC"), 1)
+		assert.Empty(t, messaging.Memory().DeadLetters("DLQ_SIGNED_TOPIC"))
+	})
+
+	t.Run("Should limit the replay rate", func(t *testing.T) {
+		consumer := deadLetterOrders(t, "DLQ_RATE_QUEUE", "create", "create", "create")
+		consumer.failing.Store(false)
+
+		start := time.Now()
+		result, err := Replay(context.Background(), "DLQ_RATE_QUEUE", ReplayOptions{RatePerSecond: 20})
+
+		assert.NoError(t, err)
+		assert.Len(t, result.Replayed, 3)
+		assert.GreaterOrEqual(t, time.Since(start), 100*time.Millisecond)
+	})
+
+	t.Run("Should dead-letter again the replayed messages that still fail", func(t *testing.T) {
+		deadLetterOrders(t, "DLQ_FAILED_QUEUE", "create")
+
+		result, err := Replay(context.Background(), "DLQ_FAILED_QUEUE", ReplayOptions{})
+
+		assert.NoError(t, err)
+		assert.Len(t, result.Replayed, 1)
+		deadLetters := messaging.Memory().DeadLetters("DLQ_FAILED_QUEUE")
+		assert.Len(t, deadLetters, 1)
+		assert.Equal(t, result.Replayed[0].Message.ID, deadLetters[0].Message.ID)
+	})
+}
@@ -0,0 +1,265 @@
+package messaging
+
+import (
+	"encoding/json"
+	"maps"
+	"slices"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/google/uuid"
+)
+
+// Encoding defines how a ProviderMessage is written to the broker
+type Encoding string
+
+const (
+	// EncodingColibri writes the ProviderMessage as JSON, with the headers also mapped to native broker attributes
+	EncodingColibri Encoding = "colibri"
+	// EncodingCloudEventsStructured writes a CloudEvents 1.0 JSON event, with the headers as extension attributes
+	EncodingCloudEventsStructured Encoding = "cloudevents-structured"
+	// EncodingCloudEventsBinary writes the payload as the body and the CloudEvents 1.0 attributes as native broker attributes
+	EncodingCloudEventsBinary Encoding = "cloudevents-binary"
+)
+
+const (
+	cloudEventsSpecVersion           = "1.0"
+	cloudEventsJSONContentType       = "application/json"
+	cloudEventsStructuredContentType = "application/cloudevents+json"
+	cloudEventsAttributePrefix       = "ce-"
+	cloudEventsSpecVersionAttr       = "specversion"
+	cloudEventsIDAttr                = "id"
+	cloudEventsSourceAttr            = "source"
+	cloudEventsTypeAttr              = "type"
+	cloudEventsTimeAttr              = "time"
+	cloudEventsDataAttr              = "data"
+	cloudEventsContentTypeAttr       = "datacontenttype"
+	cloudEventsCorrelationAttr       = "correlationid"
+	cloudEventsPartitionKeyAttr      = "partitionkey"
+	cloudEventsContentTypeField      = "content-type"
+)
+
+// cloudEventsReservedAttributes are the CloudEvents attributes mapped to ProviderMessage fields
+var cloudEventsReservedAttributes = []string{
+	cloudEventsSpecVersionAttr,
+	cloudEventsIDAttr,
+	cloudEventsSourceAttr,
+	cloudEventsTypeAttr,
+	cloudEventsTimeAttr,
+	cloudEventsDataAttr,
+	cloudEventsContentTypeAttr,
+	cloudEventsCorrelationAttr,
+	cloudEventsPartitionKeyAttr,
+}
+
+// encodeMessage returns the body and the native attributes of the message according to its encoding.
+// prefix is the CloudEvents attribute prefix of the broker protocol binding, e.g. "ce-" or "ce_".
+func encodeMessage(msg *ProviderMessage, prefix string) ([]byte, map[string]string, error) {
+	attributes := maps.Clone(msg.traceContext)
+	if attributes == nil {
+		attributes = make(map[string]string)
+	}
+	if msg.key != "" {
+		attributes[headerMessageKey] = msg.key
+	}
+
+	switch msg.encoding {
+	case EncodingCloudEventsStructured:
+		event := map[string]any{
+			cloudEventsSpecVersionAttr: cloudEventsSpecVersion,
+			cloudEventsIDAttr:          msg.ID.String(),
+			cloudEventsSourceAttr:      msg.Origin,
+			cloudEventsTypeAttr:        msg.Action,
+			cloudEventsContentTypeAttr: cloudEventsJSONContentType,
+			cloudEventsDataAttr:        msg.Message,
+		}
+		for key, value := range getCloudEventsExtensions(msg) {
+			event[key] = value
+		}
+		if !msg.OccurredAt.IsZero() {
+			event[cloudEventsTimeAttr] = msg.OccurredAt.Format(time.RFC3339Nano)
+		}
+
+		body, err := json.Marshal(event)
+		return body, attributes, err
+	case EncodingCloudEventsBinary:
+		attributes[prefix+cloudEventsSpecVersionAttr] = cloudEventsSpecVersion
+		attributes[prefix+cloudEventsIDAttr] = msg.ID.String()
+		attributes[prefix+cloudEventsSourceAttr] = msg.Origin
+		attributes[prefix+cloudEventsTypeAttr] = msg.Action
+		attributes[cloudEventsContentTypeField] = cloudEventsJSONContentType
+		for key, value := range getCloudEventsExtensions(msg) {
+			attributes[prefix+key] = value
+		}
+		if !msg.OccurredAt.IsZero() {
+			attributes[prefix+cloudEventsTimeAttr] = msg.OccurredAt.Format(time.RFC3339Nano)
+		}
+
+		body, err := json.Marshal(msg.Message)
+		return body, attributes, err
+	default:
+		for key, value := range msg.Headers {
+			attributes[key] = value
+		}
+
+		return []byte(msg.String()), attributes, nil
+	}
+}
+
+// decodeMessage reads a message written with any encoding, keeping the encoding it was written with.
+// Binary CloudEvents are detected by the specversion attribute and structured ones by the specversion field.
+func decodeMessage(body []byte, attributes map[string]string, prefix string) (*ProviderMessage, error) {
+	pm, err := decodeMessageBody(body, attributes, prefix)
+	if err != nil {
+		return nil, err
+	}
+
+	if key := attributes[headerMessageKey]; key != "" {
+		pm.key = key
+	}
+
+	return pm, nil
+}
+
+func decodeMessageBody(body []byte, attributes map[string]string, prefix string) (*ProviderMessage, error) {
+	if attributes[prefix+cloudEventsSpecVersionAttr] != "" {
+		pm, err := decodeBinaryCloudEvent(body, attributes, prefix)
+		if err != nil {
+			return nil, err
+		}
+		pm.encoding = EncodingCloudEventsBinary
+		return pm, nil
+	}
+
+	var fields map[string]json.RawMessage
+	if err := json.Unmarshal(body, &fields); err != nil {
+		return nil, err
+	}
+
+	if _, ok := fields[cloudEventsSpecVersionAttr]; ok {
+		pm, err := decodeStructuredCloudEvent(fields)
+		if err != nil {
+			return nil, err
+		}
+		pm.encoding = EncodingCloudEventsStructured
+		return pm, nil
+	}
+
+	var pm ProviderMessage
+	if err := json.Unmarshal(body, &pm); err != nil {
+		return nil, err
+	}
+	pm.encoding = EncodingColibri
+
+	return &pm, nil
+}
+
+// decodeBinaryCloudEvent builds a message from a binary mode CloudEvent
+func decodeBinaryCloudEvent(body []byte, attributes map[string]string, prefix string) (*ProviderMessage, error) {
+	event := make(map[string]string)
+	for key, value := range attributes {
+		if name, ok := strings.CutPrefix(key, prefix); ok {
+			event[name] = value
+		}
+	}
+
+	pm := newCloudEventMessage(event)
+	if len(body) > 0 {
+		if err := json.Unmarshal(body, &pm.Message); err != nil {
+			return nil, err
+		}
+	}
+
+	return pm, nil
+}
+
+// decodeStructuredCloudEvent builds a message from a structured mode CloudEvent
+func decodeStructuredCloudEvent(fields map[string]json.RawMessage) (*ProviderMessage, error) {
+	event := make(map[string]string)
+	for key, raw := range fields {
+		var value string
+		if key != cloudEventsDataAttr && json.Unmarshal(raw, &value) == nil {
+			event[key] = value
+		}
+	}
+
+	pm := newCloudEventMessage(event)
+	if data, ok := fields[cloudEventsDataAttr]; ok {
+		if err := json.Unmarshal(data, &pm.Message); err != nil {
+			return nil, err
+		}
+	}
+
+	return pm, nil
+}
+
+// newCloudEventMessage maps the CloudEvents attributes to a message, keeping extensions as headers.
+// Event IDs that are not UUIDs are mapped to a deterministic UUID.
+func newCloudEventMessage(event map[string]string) *ProviderMessage {
+	id, err := uuid.Parse(event[cloudEventsIDAttr])
+	if err != nil {
+		id = uuid.NewSHA1(uuid.NameSpaceURL, []byte(event[cloudEventsSourceAttr]+"/"+event[cloudEventsIDAttr]))
+	}
+
+	pm := &ProviderMessage{
+		ID:            id,
+		Origin:        event[cloudEventsSourceAttr],
+		Action:        event[cloudEventsTypeAttr],
+		CorrelationID: event[cloudEventsCorrelationAttr],
+		key:           event[cloudEventsPartitionKeyAttr],
+	}
+	if occurredAt, err := time.Parse(time.RFC3339Nano, event[cloudEventsTimeAttr]); err == nil {
+		pm.OccurredAt = occurredAt
+	}
+
+	for key, value := range event {
+		if !isCloudEventsReservedAttribute(key) {
+			if pm.Headers == nil {
+				pm.Headers = make(map[string]string)
+			}
+			pm.Headers[key] = value
+		}
+	}
+
+	return pm
+}
+
+// getCloudEventsExtensions returns the correlation id, the partition key and the headers as CloudEvents extension attributes.
+// Extension names only allow lower-case letters and digits, so other characters are removed from header names.
+func getCloudEventsExtensions(msg *ProviderMessage) map[string]string {
+	extensions := make(map[string]string, len(msg.Headers)+1)
+	for key, value := range msg.Headers {
+		if name := getCloudEventsExtensionName(key); name != "" {
+			extensions[name] = value
+		}
+	}
+
+	if msg.CorrelationID != "" {
+		extensions[cloudEventsCorrelationAttr] = msg.CorrelationID
+	}
+	if msg.key != "" {
+		extensions[cloudEventsPartitionKeyAttr] = msg.key
+	}
+
+	return extensions
+}
+
+// getCloudEventsExtensionName returns the extension name of a header, or an empty string when the header can not be an extension
+func getCloudEventsExtensionName(key string) string {
+	name := strings.Map(func(r rune) rune {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || unicode.IsDigit(r)) {
+			return -1
+		}
+		return unicode.ToLower(r)
+	}, key)
+	if isCloudEventsReservedAttribute(name) {
+		return ""
+	}
+
+	return name
+}
+
+func isCloudEventsReservedAttribute(name string) bool {
+	return slices.Contains(cloudEventsReservedAttributes, name)
+}
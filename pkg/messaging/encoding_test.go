@@ -0,0 +1,141 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncoding(t *testing.T) {
+	newMessage := func(encoding Encoding) *ProviderMessage {
+		msg := NewProviderMessage(context.Background(), "user_created", map[string]any{"name": "User"})
+		msg.Origin = "colibri-test"
+		msg.CorrelationID = "correlation"
+		msg.Headers = map[string]string{"tenant-id": "tenant"}
+		msg.OccurredAt = time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+		msg.encoding = encoding
+		msg.traceContext = map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+		return msg
+	}
+
+	t.Run("Should encode colibri messages with headers as attributes", func(t *testing.T) {
+		msg := newMessage(EncodingColibri)
+
+		body, attributes, err := encodeMessage(msg, cloudEventsAttributePrefix)
+		decoded, decodeErr := decodeMessage(body, attributes, cloudEventsAttributePrefix)
+
+		assert.NoError(t, err)
+		assert.NoError(t, decodeErr)
+		assert.Equal(t, "tenant", attributes["tenant-id"])
+		assert.Contains(t, attributes, "traceparent")
+		assert.Equal(t, msg.ID, decoded.ID)
+		assert.Equal(t, msg.Headers, decoded.Headers)
+		assert.Equal(t, msg.OccurredAt, decoded.OccurredAt)
+	})
+
+	t.Run("Should encode structured CloudEvents messages", func(t *testing.T) {
+		msg := newMessage(EncodingCloudEventsStructured)
+
+		body, attributes, err := encodeMessage(msg, cloudEventsAttributePrefix)
+		var event map[string]any
+		unmarshalErr := json.Unmarshal(body, &event)
+		decoded, decodeErr := decodeMessage(body, attributes, cloudEventsAttributePrefix)
+
+		assert.NoError(t, err)
+		assert.NoError(t, unmarshalErr)
+		assert.NoError(t, decodeErr)
+		assert.Equal(t, "1.0", event["specversion"])
+		assert.Equal(t, msg.ID.String(), event["id"])
+		assert.Equal(t, "colibri-test", event["source"])
+		assert.Equal(t, "user_created", event["type"])
+		assert.Equal(t, "tenant", event["tenantid"])
+		assert.Equal(t, "correlation", event["correlationid"])
+		assert.Equal(t, "2026-01-02T03:04:05Z", event["time"])
+		assert.Equal(t, msg.ID, decoded.ID)
+		assert.Equal(t, "user_created", decoded.Action)
+		assert.Equal(t, "correlation", decoded.CorrelationID)
+		assert.Equal(t, map[string]string{"tenantid": "tenant"}, decoded.Headers)
+		assert.Equal(t, msg.OccurredAt, decoded.OccurredAt)
+		assert.Equal(t, map[string]any{"name": "User"}, decoded.Message)
+	})
+
+	t.Run("Should encode binary CloudEvents messages with the protocol prefix", func(t *testing.T) {
+		msg := newMessage(EncodingCloudEventsBinary)
+
+		body, attributes, err := encodeMessage(msg, "ce_")
+		decoded, decodeErr := decodeMessage(body, attributes, "ce_")
+
+		assert.NoError(t, err)
+		assert.NoError(t, decodeErr)
+		assert.JSONEq(t, `{"name":"User"}`, string(body))
+		assert.Equal(t, "1.0", attributes["ce_specversion"])
+		assert.Equal(t, "user_created", attributes["ce_type"])
+		assert.Equal(t, "application/json", attributes["content-type"])
+		assert.Contains(t, attributes, "traceparent")
+		assert.Equal(t, msg.ID, decoded.ID)
+		assert.Equal(t, "colibri-test", decoded.Origin)
+		assert.Equal(t, "correlation", decoded.CorrelationID)
+		assert.Equal(t, map[string]string{"tenantid": "tenant"}, decoded.Headers)
+		assert.Equal(t, map[string]any{"name": "User"}, decoded.Message)
+	})
+
+	t.Run("Should keep the ordering key with every encoding", func(t *testing.T) {
+		for _, encoding := range []Encoding{EncodingColibri, EncodingCloudEventsStructured, EncodingCloudEventsBinary} {
+			msg := newMessage(encoding)
+			msg.key = "order-1"
+
+			body, attributes, err := encodeMessage(msg, cloudEventsAttributePrefix)
+			decoded, decodeErr := decodeMessage(body, attributes, cloudEventsAttributePrefix)
+
+			assert.NoError(t, err)
+			assert.NoError(t, decodeErr)
+			assert.Equal(t, "order-1", attributes[headerMessageKey])
+			assert.Equal(t, "order-1", decoded.Key())
+			assert.NotContains(t, decoded.Headers, "partitionkey")
+		}
+	})
+
+	t.Run("Should keep the encoding the message was written with", func(t *testing.T) {
+		for _, encoding := range []Encoding{EncodingColibri, EncodingCloudEventsStructured, EncodingCloudEventsBinary} {
+			body, attributes, err := encodeMessage(newMessage(encoding), cloudEventsAttributePrefix)
+			decoded, decodeErr := decodeMessage(body, attributes, cloudEventsAttributePrefix)
+
+			assert.NoError(t, err)
+			assert.NoError(t, decodeErr)
+			assert.Equal(t, encoding, decoded.encoding)
+		}
+	})
+
+	t.Run("Should decode the CloudEvents partition key extension", func(t *testing.T) {
+		body := []byte(`{"specversion":"1.0","id":"order-1","source":"legacy","type":"order_created","partitionkey":"customer-1"}`)
+
+		result, err := decodeMessage(body, nil, cloudEventsAttributePrefix)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "customer-1", result.Key())
+	})
+
+	t.Run("Should decode CloudEvents with ids that are not UUIDs deterministically", func(t *testing.T) {
+		body := []byte(`{"specversion":"1.0","id":"order-1","source":"legacy","type":"order_created","data":{"id":1}}`)
+
+		first, err := decodeMessage(body, nil, cloudEventsAttributePrefix)
+		second, _ := decodeMessage(body, nil, cloudEventsAttributePrefix)
+
+		assert.NoError(t, err)
+		assert.NotEqual(t, uuid.Nil, first.ID)
+		assert.Equal(t, first.ID, second.ID)
+		assert.Equal(t, "order_created", first.Action)
+		assert.Equal(t, "legacy", first.Origin)
+	})
+
+	t.Run("Should return error when body is not valid JSON", func(t *testing.T) {
+		result, err := decodeMessage([]byte("invalid"), nil, cloudEventsAttributePrefix)
+
+		assert.Error(t, err)
+		assert.Nil(t, result)
+	})
+}
@@ -0,0 +1,491 @@
+package messaging
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aws/aws-sdk-go/aws"
+	"github.com/aws/aws-sdk-go/service/kms"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/cloud"
+)
+
+const (
+	// headerEncryption marks a message whose payload is encrypted and holds the algorithm.
+	// It only has lower-case letters, so it is kept as is by every encoding.
+	headerEncryption    = "encryption"
+	encryptionAES256GCM = "aes256gcm"
+
+	// messagingKeyFileEnvVar is the local key file used when no key provider is set, meant for development
+	messagingKeyFileEnvVar = "MESSAGING_KEY_FILE"
+
+	dataKeySize = 32
+
+	// kmsDefaultDataKeyMaxMessages and kmsDefaultDataKeyMaxAge bound how long a KMS data key encrypts messages
+	kmsDefaultDataKeyMaxMessages = 1000
+	kmsDefaultDataKeyMaxAge      = 5 * time.Minute
+	// kmsDefaultDataKeyCacheTTL and kmsMaxCachedDataKeys bound the data keys unwrapped by KMS kept in memory
+	kmsDefaultDataKeyCacheTTL = 5 * time.Minute
+	kmsMaxCachedDataKeys      = 1000
+)
+
+var (
+	// ErrNoKeyProvider is returned when a message is encrypted or decrypted without a key provider
+	ErrNoKeyProvider = errors.New("no messaging key provider, set one with SetKeyProvider or MESSAGING_KEY_FILE")
+	// ErrKeyNotFound is returned by key providers that do not hold the requested key
+	ErrKeyNotFound = errors.New("messaging key not found")
+	// ErrUnsupportedEncryption is returned when a message is encrypted with an unknown algorithm
+	ErrUnsupportedEncryption = errors.New("unsupported message encryption")
+)
+
+// DataKey is a key encrypting the payload of a message, together with the same key wrapped by a key encryption key
+type DataKey struct {
+	// KeyID identifies the key encryption key that wrapped the data key
+	KeyID string
+	// Plaintext is the AES-256 key encrypting the payload
+	Plaintext []byte
+	// Wrapped is the data key encrypted by the key encryption key, published with the message
+	Wrapped []byte
+}
+
+// KeyProvider generates the data keys of the messages published by producers with WithEncryption
+// and unwraps them for the consumers
+type KeyProvider interface {
+	GenerateDataKey(ctx context.Context) (*DataKey, error)
+	DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error)
+}
+
+// KMS is the part of a cloud key management service that wraps data keys, like AWS KMS or Cloud KMS
+type KMS interface {
+	Encrypt(ctx context.Context, keyID string, plaintext []byte) ([]byte, error)
+	Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error)
+}
+
+// encryptedPayload is published in place of an encrypted payload
+type encryptedPayload struct {
+	KeyID      string `json:"keyId"`
+	Key        []byte `json:"key"`
+	Nonce      []byte `json:"nonce"`
+	Ciphertext []byte `json:"ciphertext"`
+}
+
+var (
+	keyProviderMu sync.Mutex
+	keyProvider   KeyProvider
+)
+
+// WithEncryption encrypts the payload of every published message with AES-256-GCM, using a data key
+// from the key provider. The headers and the other fields of the message are not encrypted.
+func WithEncryption() ProducerOption {
+	return func(p *Producer) {
+		p.encrypt = true
+	}
+}
+
+// SetKeyProvider sets the key provider of the producers with WithEncryption and of the consumers of encrypted messages
+func SetKeyProvider(provider KeyProvider) {
+	keyProviderMu.Lock()
+	defer keyProviderMu.Unlock()
+
+	keyProvider = provider
+}
+
+// getKeyProvider returns the key provider, loading the local key file of MESSAGING_KEY_FILE when none is set
+func getKeyProvider() (KeyProvider, error) {
+	keyProviderMu.Lock()
+	defer keyProviderMu.Unlock()
+
+	if keyProvider != nil {
+		return keyProvider, nil
+	}
+
+	path := os.Getenv(messagingKeyFileEnvVar)
+	if path == "" {
+		return nil, ErrNoKeyProvider
+	}
+
+	provider, err := NewLocalKeyProvider(path)
+	if err != nil {
+		return nil, err
+	}
+	keyProvider = provider
+
+	return keyProvider, nil
+}
+
+// encryptMessage replaces the payload with its encryption by a new data key, bound to the message id
+func encryptMessage(ctx context.Context, msg *ProviderMessage) error {
+	provider, err := getKeyProvider()
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(msg.Message)
+	if err != nil {
+		return err
+	}
+
+	key, err := provider.GenerateDataKey(ctx)
+	if err != nil {
+		return err
+	}
+
+	nonce, ciphertext, err := seal(key.Plaintext, payload, msg.ID[:])
+	if err != nil {
+		return err
+	}
+
+	msg.Message = encryptedPayload{KeyID: key.KeyID, Key: key.Wrapped, Nonce: nonce, Ciphertext: ciphertext}
+	WithHeader(headerEncryption, encryptionAES256GCM)(msg)
+	return nil
+}
+
+// decryptMessage replaces an encrypted payload with the payload itself
+func decryptMessage(ctx context.Context, msg *ProviderMessage) error {
+	algorithm, ok := msg.Headers[headerEncryption]
+	if !ok {
+		return nil
+	}
+	if algorithm != encryptionAES256GCM {
+		return NewPermanentError(fmt.Errorf("%w: %s", ErrUnsupportedEncryption, algorithm))
+	}
+
+	provider, err := getKeyProvider()
+	if err != nil {
+		return err
+	}
+
+	var encrypted encryptedPayload
+	if err = msg.DecodeMessage(&encrypted); err != nil {
+		return err
+	}
+
+	key, err := provider.DecryptDataKey(ctx, encrypted.KeyID, encrypted.Key)
+	if err != nil {
+		return err
+	}
+
+	payload, err := open(key, encrypted.Nonce, encrypted.Ciphertext, msg.ID[:])
+	if err != nil {
+		return NewPermanentError(err)
+	}
+
+	var message any
+	if err = json.Unmarshal(payload, &message); err != nil {
+		return err
+	}
+
+	msg.Message = message
+	delete(msg.Headers, headerEncryption)
+	return nil
+}
+
+// seal encrypts the plaintext with AES-GCM and a random nonce, authenticating the additional data
+func seal(key, plaintext, additionalData []byte) ([]byte, []byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err = rand.Read(nonce); err != nil {
+		return nil, nil, err
+	}
+
+	return nonce, aead.Seal(nil, nonce, plaintext, additionalData), nil
+}
+
+// open decrypts a ciphertext sealed with seal
+func open(key, nonce, ciphertext, additionalData []byte) ([]byte, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != aead.NonceSize() {
+		return nil, errors.New("invalid nonce size")
+	}
+
+	return aead.Open(nil, nonce, ciphertext, additionalData)
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return cipher.NewGCM(block)
+}
+
+// newDataKey returns a random AES-256 key
+func newDataKey() ([]byte, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// LocalKeyProvider wraps data keys with AES-256 keys read from a local file, meant for development and tests.
+// It also holds the HMAC keys of the origins signing messages, so it is a SigningKeyProvider too.
+//
+// The file is JSON with base64 keys:
+//
+//	{
+//	  "current": "2026-10",
+//	  "keys": {"2026-10": "<32 bytes>", "2026-04": "<32 bytes>"},
+//	  "signing": {"orders-service": "<key>"}
+//	}
+//
+// Data keys are wrapped with the current key, and keys kept in the file still unwrap older messages.
+type LocalKeyProvider struct {
+	current string
+	keys    map[string][]byte
+	signing SigningKeys
+}
+
+type localKeyFile struct {
+	Current string            `json:"current"`
+	Keys    map[string][]byte `json:"keys"`
+	Signing map[string][]byte `json:"signing"`
+}
+
+// NewLocalKeyProvider reads the key file
+func NewLocalKeyProvider(path string) (*LocalKeyProvider, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file localKeyFile
+	if err = json.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("invalid key file %s: %w", path, err)
+	}
+
+	for id, key := range file.Keys {
+		if len(key) != dataKeySize {
+			return nil, fmt.Errorf("invalid key file %s: key %s must have %d bytes", path, id, dataKeySize)
+		}
+	}
+	if _, ok := file.Keys[file.Current]; file.Current != "" && !ok {
+		return nil, fmt.Errorf("invalid key file %s: current key %s: %w", path, file.Current, ErrKeyNotFound)
+	}
+
+	return &LocalKeyProvider{current: file.Current, keys: file.Keys, signing: file.Signing}, nil
+}
+
+// GenerateDataKey returns a new data key wrapped with the current key of the file
+func (p *LocalKeyProvider) GenerateDataKey(_ context.Context) (*DataKey, error) {
+	if p.current == "" {
+		return nil, fmt.Errorf("%w: no current key", ErrKeyNotFound)
+	}
+
+	key, err := newDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce, wrapped, err := seal(p.keys[p.current], key, []byte(p.current))
+	if err != nil {
+		return nil, err
+	}
+
+	return &DataKey{KeyID: p.current, Plaintext: key, Wrapped: append(nonce, wrapped...)}, nil
+}
+
+// DecryptDataKey unwraps the data key with the key of the file it was wrapped with
+func (p *LocalKeyProvider) DecryptDataKey(_ context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	kek, ok := p.keys[keyID]
+	if !ok {
+		return nil, fmt.Errorf("%w: %s", ErrKeyNotFound, keyID)
+	}
+
+	aead, err := newAEAD(kek)
+	if err != nil {
+		return nil, err
+	}
+	if len(wrapped) < aead.NonceSize() {
+		return nil, errors.New("invalid wrapped data key")
+	}
+
+	return aead.Open(nil, wrapped[:aead.NonceSize()], wrapped[aead.NonceSize():], []byte(keyID))
+}
+
+// SigningKey returns the HMAC key of the origin from the file
+func (p *LocalKeyProvider) SigningKey(ctx context.Context, origin string) ([]byte, error) {
+	return p.signing.SigningKey(ctx, origin)
+}
+
+// KMSKeyProvider generates data keys locally and wraps them with a key of a key management service.
+// A data key encrypts a bounded number of messages for a bounded time before a new one is generated,
+// and unwrapped data keys are cached for a while, so the service is not called for every message.
+type KMSKeyProvider struct {
+	kms   KMS
+	keyID string
+
+	maxMessages int
+	maxAge      time.Duration
+	cacheTTL    time.Duration
+
+	mu        sync.Mutex
+	current   *DataKey
+	uses      int
+	expires   time.Time
+	unwrapped map[string]cachedDataKey
+}
+
+// KMSKeyProviderOption configures optional behaviour of a KMSKeyProvider
+type KMSKeyProviderOption func(p *KMSKeyProvider)
+
+// cachedDataKey is a data key unwrapped by the KMS, kept until it expires
+type cachedDataKey struct {
+	key     []byte
+	expires time.Time
+}
+
+// WithDataKeyReuse sets how many messages a data key encrypts and for how long, 1000 messages and 5 minutes by default.
+// A max of 1 message generates a new data key for every message.
+func WithDataKeyReuse(maxMessages int, maxAge time.Duration) KMSKeyProviderOption {
+	return func(p *KMSKeyProvider) {
+		p.maxMessages = maxMessages
+		p.maxAge = maxAge
+	}
+}
+
+// WithDataKeyCacheTTL sets how long unwrapped data keys are cached, 5 minutes by default. Zero disables the cache.
+func WithDataKeyCacheTTL(ttl time.Duration) KMSKeyProviderOption {
+	return func(p *KMSKeyProvider) {
+		p.cacheTTL = ttl
+	}
+}
+
+// NewKMSKeyProvider returns a key provider wrapping data keys with the KMS key
+func NewKMSKeyProvider(kms KMS, keyID string, opts ...KMSKeyProviderOption) *KMSKeyProvider {
+	p := &KMSKeyProvider{
+		kms:         kms,
+		keyID:       keyID,
+		maxMessages: kmsDefaultDataKeyMaxMessages,
+		maxAge:      kmsDefaultDataKeyMaxAge,
+		cacheTTL:    kmsDefaultDataKeyCacheTTL,
+		unwrapped:   make(map[string]cachedDataKey),
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// GenerateDataKey returns the current data key, or a new data key wrapped by the KMS key once the current one
+// has encrypted its max messages or reached its max age
+func (p *KMSKeyProvider) GenerateDataKey(ctx context.Context) (*DataKey, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	now := time.Now()
+	if p.current != nil && p.uses < p.maxMessages && now.Before(p.expires) {
+		p.uses++
+		return p.current, nil
+	}
+
+	key, err := newDataKey()
+	if err != nil {
+		return nil, err
+	}
+
+	wrapped, err := p.kms.Encrypt(ctx, p.keyID, key)
+	if err != nil {
+		return nil, err
+	}
+
+	p.current = &DataKey{KeyID: p.keyID, Plaintext: key, Wrapped: wrapped}
+	p.uses = 1
+	p.expires = now.Add(p.maxAge)
+	p.cache(p.keyID, wrapped, key, now)
+
+	return p.current, nil
+}
+
+// DecryptDataKey unwraps the data key with the KMS key it was wrapped with, or returns it from the cache
+func (p *KMSKeyProvider) DecryptDataKey(ctx context.Context, keyID string, wrapped []byte) ([]byte, error) {
+	p.mu.Lock()
+	cached, ok := p.unwrapped[getCachedDataKeyID(keyID, wrapped)]
+	p.mu.Unlock()
+	if ok && time.Now().Before(cached.expires) {
+		return cached.key, nil
+	}
+
+	key, err := p.kms.Decrypt(ctx, keyID, wrapped)
+	if err != nil {
+		return nil, err
+	}
+
+	p.mu.Lock()
+	p.cache(keyID, wrapped, key, time.Now())
+	p.mu.Unlock()
+
+	return key, nil
+}
+
+// cache keeps the unwrapped data key until the cache TTL, dropping the expired keys first
+// and the keys closest to expiration when the cache is full. It must be called with the lock held.
+func (p *KMSKeyProvider) cache(keyID string, wrapped, key []byte, now time.Time) {
+	if p.cacheTTL <= 0 {
+		return
+	}
+
+	maps.DeleteFunc(p.unwrapped, func(_ string, cached cachedDataKey) bool { return !now.Before(cached.expires) })
+	for len(p.unwrapped) >= kmsMaxCachedDataKeys {
+		oldest := ""
+		for id, cached := range p.unwrapped {
+			if oldest == "" || cached.expires.Before(p.unwrapped[oldest].expires) {
+				oldest = id
+			}
+		}
+		delete(p.unwrapped, oldest)
+	}
+
+	p.unwrapped[getCachedDataKeyID(keyID, wrapped)] = cachedDataKey{key: key, expires: now.Add(p.cacheTTL)}
+}
+
+// getCachedDataKeyID returns the cache entry of a wrapped data key
+func getCachedDataKeyID(keyID string, wrapped []byte) string {
+	return keyID + "/" + string(wrapped)
+}
+
+// awsKMS is the KMS of AWS, using the session of the cloud package
+type awsKMS struct {
+	client *kms.KMS
+}
+
+// NewAwsKMS returns the AWS KMS, to be used with NewKMSKeyProvider. The cloud package must be initialized.
+func NewAwsKMS() KMS {
+	return &awsKMS{client: kms.New(cloud.GetAwsSession())}
+}
+
+func (k *awsKMS) Encrypt(ctx context.Context, keyID string, plaintext []byte) ([]byte, error) {
+	result, err := k.client.EncryptWithContext(ctx, &kms.EncryptInput{KeyId: aws.String(keyID), Plaintext: plaintext})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.CiphertextBlob, nil
+}
+
+func (k *awsKMS) Decrypt(ctx context.Context, keyID string, ciphertext []byte) ([]byte, error) {
+	result, err := k.client.DecryptWithContext(ctx, &kms.DecryptInput{KeyId: aws.String(keyID), CiphertextBlob: ciphertext})
+	if err != nil {
+		return nil, err
+	}
+
+	return result.Plaintext, nil
+}
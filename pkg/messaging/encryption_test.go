@@ -0,0 +1,237 @@
+package messaging
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// kmsTest wraps keys by xoring them with a key per id, counting the calls
+type kmsTest struct {
+	keys     map[string]byte
+	encrypts int
+	decrypts int
+}
+
+func (k *kmsTest) Encrypt(_ context.Context, keyID string, plaintext []byte) ([]byte, error) {
+	k.encrypts++
+	return k.xor(keyID, plaintext)
+}
+
+func (k *kmsTest) Decrypt(_ context.Context, keyID string, ciphertext []byte) ([]byte, error) {
+	k.decrypts++
+	return k.xor(keyID, ciphertext)
+}
+
+func (k *kmsTest) xor(keyID string, data []byte) ([]byte, error) {
+	key, ok := k.keys[keyID]
+	if !ok {
+		return nil, ErrKeyNotFound
+	}
+
+	result := make([]byte, len(data))
+	for i, b := range data {
+		result[i] = b ^ key
+	}
+	return result, nil
+}
+
+// writeKeyFileTest writes a local key file with a random current key and signing keys for the origins
+func writeKeyFileTest(t *testing.T, current string, origins ...string) string {
+	key := make([]byte, dataKeySize)
+	_, _ = rand.Read(key)
+	file := localKeyFile{Current: current, Keys: map[string][]byte{current: key}, Signing: map[string][]byte{}}
+	for _, origin := range origins {
+		file.Signing[origin] = []byte("secret of " + origin)
+	}
+
+	content, err := json.Marshal(file)
+	assert.NoError(t, err)
+	path := filepath.Join(t.TempDir(), "keys.json")
+	assert.NoError(t, os.WriteFile(path, content, 0o600))
+
+	return path
+}
+
+func useKeyProviderTest(t *testing.T, provider KeyProvider) {
+	previousProvider := keyProvider
+	SetKeyProvider(provider)
+	t.Cleanup(func() { SetKeyProvider(previousProvider) })
+}
+
+func TestEncryption(t *testing.T) {
+	useMemoryMessaging(t)
+	user := userMessageTest{Name: "User", Email: "user@mail.com"}
+
+	consume := func(queue string) *[]userMessageTest {
+		received := make([]userMessageTest, 0)
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				var user userMessageTest
+				if err := message.DecodeMessage(&user); err != nil {
+					return err
+				}
+				received = append(received, user)
+				return nil
+			},
+			qName: queue,
+		})
+		return &received
+	}
+
+	t.Run("Should publish the payload encrypted and consume it decrypted with every encoding", func(t *testing.T) {
+		provider, err := NewLocalKeyProvider(writeKeyFileTest(t, "2026-10"))
+		assert.NoError(t, err)
+		useKeyProviderTest(t, provider)
+
+		for _, encoding := range []Encoding{EncodingColibri, EncodingCloudEventsStructured, EncodingCloudEventsBinary} {
+			queue := "ENCRYPTION_QUEUE_" + string(encoding)
+			received := consume(queue)
+
+			err := NewProducer(queue, WithEncryption(), WithEncoding(encoding)).Publish(context.Background(), "create", user)
+
+			assert.NoError(t, err)
+			assert.Equal(t, []userMessageTest{user}, *received)
+			published := Memory().Published(queue)[0]
+			assert.Equal(t, encryptionAES256GCM, published.Header(headerEncryption))
+			assert.NotContains(t, published.String(), user.Email)
+		}
+	})
+
+	t.Run("Should encrypt offloaded payloads before uploading them", func(t *testing.T) {
+		useKeyProviderTest(t, NewKMSKeyProvider(&kmsTest{keys: map[string]byte{"alias/messaging": 42}}, "alias/messaging"))
+		store := &claimCheckStoreTest{objects: make(map[string][]byte)}
+		previousStore := claimCheckStore
+		SetClaimCheckStore(store)
+		defer SetClaimCheckStore(previousStore)
+		received := consume("ENCRYPTION_CLAIM_CHECK_QUEUE")
+
+		err := NewProducer("ENCRYPTION_CLAIM_CHECK_QUEUE", WithEncryption(),
+			WithClaimCheck("bucket", WithClaimCheckThreshold(10), WithClaimCheckRetention(ClaimCheckRetain))).
+			Publish(context.Background(), "create", user)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []userMessageTest{user}, *received)
+		assert.Len(t, store.objects, 1)
+		for _, payload := range store.objects {
+			assert.NotContains(t, string(payload), user.Email)
+		}
+	})
+
+	t.Run("Should send messages that can not be decrypted to the dead-letter queue", func(t *testing.T) {
+		useKeyProviderTest(t, NewKMSKeyProvider(&kmsTest{keys: map[string]byte{"alias/messaging": 42}}, "alias/messaging"))
+		received := consume("ENCRYPTION_TAMPERED_QUEUE")
+		msg := &ProviderMessage{Action: "create", Message: user}
+		assert.NoError(t, encryptMessage(context.Background(), msg))
+		var encrypted encryptedPayload
+		assert.NoError(t, msg.DecodeMessage(&encrypted))
+		encrypted.Ciphertext[0] ^= 1
+		msg.Message = encrypted
+
+		assert.NoError(t, instance.producer(context.Background(), NewProducer("ENCRYPTION_TAMPERED_QUEUE"), msg))
+
+		assert.Empty(t, *received)
+		deadLetters := Memory().DeadLetters("ENCRYPTION_TAMPERED_QUEUE")
+		assert.Len(t, deadLetters, 1)
+		assert.Equal(t, 1, deadLetters[0].Attempts)
+	})
+
+	t.Run("Should unwrap data keys with older keys of the key file", func(t *testing.T) {
+		oldPath := writeKeyFileTest(t, "2026-04")
+		oldProvider, err := NewLocalKeyProvider(oldPath)
+		assert.NoError(t, err)
+		dataKey, err := oldProvider.GenerateDataKey(context.Background())
+		assert.NoError(t, err)
+
+		rotated := &LocalKeyProvider{current: "2026-10", keys: map[string][]byte{"2026-10": make([]byte, dataKeySize), "2026-04": oldProvider.keys["2026-04"]}}
+		key, err := rotated.DecryptDataKey(context.Background(), dataKey.KeyID, dataKey.Wrapped)
+
+		assert.NoError(t, err)
+		assert.Equal(t, dataKey.Plaintext, key)
+		_, err = rotated.DecryptDataKey(context.Background(), "2025-10", dataKey.Wrapped)
+		assert.ErrorIs(t, err, ErrKeyNotFound)
+	})
+
+	t.Run("Should load the key file of the environment when no key provider is set", func(t *testing.T) {
+		useKeyProviderTest(t, nil)
+		t.Setenv(messagingKeyFileEnvVar, writeKeyFileTest(t, "2026-10"))
+		received := consume("ENCRYPTION_KEY_FILE_QUEUE")
+
+		err := NewProducer("ENCRYPTION_KEY_FILE_QUEUE", WithEncryption()).Publish(context.Background(), "create", user)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []userMessageTest{user}, *received)
+	})
+
+	t.Run("Should return error when publishing encrypted messages without a key provider", func(t *testing.T) {
+		useKeyProviderTest(t, nil)
+
+		err := NewProducer("ENCRYPTION_NO_PROVIDER_TOPIC", WithEncryption()).Publish(context.Background(), "create", user)
+
+		assert.True(t, errors.Is(err, ErrNoKeyProvider))
+		assert.Empty(t, Memory().Published("ENCRYPTION_NO_PROVIDER_TOPIC"))
+	})
+
+	t.Run("Should return error when the key file is invalid", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "keys.json")
+		assert.NoError(t, os.WriteFile(path, []byte(`{"current":"k1","keys":{"k1":"c2hvcnQ="}}`), 0o600))
+
+		_, err := NewLocalKeyProvider(path)
+
+		assert.Error(t, err)
+	})
+
+	t.Run("Should reuse a KMS data key for a bounded number of messages", func(t *testing.T) {
+		kms := &kmsTest{keys: map[string]byte{"alias/messaging": 42}}
+		provider := NewKMSKeyProvider(kms, "alias/messaging", WithDataKeyReuse(2, time.Minute))
+
+		first, _ := provider.GenerateDataKey(context.Background())
+		second, _ := provider.GenerateDataKey(context.Background())
+		third, err := provider.GenerateDataKey(context.Background())
+
+		assert.NoError(t, err)
+		assert.Equal(t, first.Wrapped, second.Wrapped)
+		assert.NotEqual(t, first.Wrapped, third.Wrapped)
+		assert.Equal(t, 2, kms.encrypts)
+	})
+
+	t.Run("Should generate a new KMS data key once the current one reaches its max age", func(t *testing.T) {
+		kms := &kmsTest{keys: map[string]byte{"alias/messaging": 42}}
+		provider := NewKMSKeyProvider(kms, "alias/messaging", WithDataKeyReuse(100, 10*time.Millisecond))
+
+		first, _ := provider.GenerateDataKey(context.Background())
+		time.Sleep(20 * time.Millisecond)
+		second, err := provider.GenerateDataKey(context.Background())
+
+		assert.NoError(t, err)
+		assert.NotEqual(t, first.Wrapped, second.Wrapped)
+		assert.Equal(t, 2, kms.encrypts)
+	})
+
+	t.Run("Should cache unwrapped KMS data keys until the TTL", func(t *testing.T) {
+		kms := &kmsTest{keys: map[string]byte{"alias/messaging": 42}}
+		dataKey, err := NewKMSKeyProvider(kms, "alias/messaging").GenerateDataKey(context.Background())
+		assert.NoError(t, err)
+		provider := NewKMSKeyProvider(kms, "alias/messaging", WithDataKeyCacheTTL(20*time.Millisecond))
+
+		first, _ := provider.DecryptDataKey(context.Background(), dataKey.KeyID, dataKey.Wrapped)
+		second, _ := provider.DecryptDataKey(context.Background(), dataKey.KeyID, dataKey.Wrapped)
+		decryptsBeforeExpiration := kms.decrypts
+		time.Sleep(30 * time.Millisecond)
+		third, err := provider.DecryptDataKey(context.Background(), dataKey.KeyID, dataKey.Wrapped)
+
+		assert.NoError(t, err)
+		assert.Equal(t, dataKey.Plaintext, first)
+		assert.Equal(t, first, second)
+		assert.Equal(t, first, third)
+		assert.Equal(t, 1, decryptsBeforeExpiration)
+		assert.Equal(t, 2, kms.decrypts)
+	})
+}
@@ -2,24 +2,97 @@ package messaging
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
+	"fmt"
 	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
 
+	cloudmonitoring "cloud.google.com/go/monitoring/apiv3/v2"
+	"cloud.google.com/go/monitoring/apiv3/v2/monitoringpb"
 	"cloud.google.com/go/pubsub"
 	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"google.golang.org/api/iterator"
+	"google.golang.org/protobuf/types/known/timestamppb"
 )
 
+const (
+	couldNotReadSubscriptionConfig = "could not read config of subscription %s"
+
+	// gcpUndeliveredMessagesMetric is the Cloud Monitoring metric with the backlog of a subscription
+	gcpUndeliveredMessagesMetric = "pubsub.googleapis.com/subscription/num_undelivered_messages"
+	// gcpBacklogWindow is how far back the last backlog sample is searched, since the metric is sampled every minute
+	gcpBacklogWindow = 5 * time.Minute
+	// gcpDLQSuffix names the subscription of the dead-letter topic read by ReadDeadLetters
+	gcpDLQSuffix = "_DLQ"
+	// gcpDeadLetterWait is how long the dead-letter subscription is read without receiving a message before stopping
+	gcpDeadLetterWait = 2 * time.Second
+)
+
+var errBacklogNotAvailable = errors.New("subscription backlog not available")
+
 type gcpMessaging struct {
-	client *pubsub.Client
+	client   *pubsub.Client
+	attempts sync.Map
+
+	metricMu sync.Mutex
+	// metricClient reads the subscription backlog from Cloud Monitoring, created on first use
+	metricClient *cloudmonitoring.MetricClient
 }
 
-type gcpOriginalMessage struct{}
+type gcpOriginalMessage struct {
+	m               *gcpMessaging
+	msg             *pubsub.Message
+	deadLetterTopic string
+	attempt         int
+}
 
+// Ack acknowledges the message.
 func (g gcpOriginalMessage) Ack() error {
+	g.m.attempts.Delete(g.msg.ID)
+	g.msg.Ack()
 	return nil
 }
 
-func (g gcpOriginalMessage) Nack(_ bool, _ error) error {
+// Nack redelivers the message when requeue is true. Otherwise, it publishes the message to the subscription
+// dead-letter topic with the failure reason and attempt count as attributes and acknowledges it.
+// Messages of subscriptions without a dead-letter topic are discarded.
+func (g gcpOriginalMessage) Nack(requeue bool, err error) error {
+	if requeue {
+		g.msg.Nack()
+		return nil
+	}
+
+	if g.deadLetterTopic == "" {
+		logging.Debug(context.Background()).Msgf(messageSentToDLQ, g.msg.ID, "<none>", errorReason(err))
+		return g.Ack()
+	}
+
+	attributes := make(map[string]string, len(g.msg.Attributes)+2)
+	for key, value := range g.msg.Attributes {
+		attributes[key] = value
+	}
+	attributes[headerFailureReason] = errorReason(err)
+	attributes[headerAttempts] = strconv.Itoa(g.attempt)
+
+	ctx := context.Background()
+	if _, pubErr := g.m.topic(g.deadLetterTopic).Publish(ctx, &pubsub.Message{Data: g.msg.Data, Attributes: attributes}).Get(ctx); pubErr != nil {
+		logging.Error(ctx).Err(pubErr).Msgf(couldNotSendToDLQ, g.msg.ID)
+		g.msg.Nack()
+		return pubErr
+	}
+
+	logging.Debug(ctx).Msgf(messageSentToDLQ, g.msg.ID, g.deadLetterTopic, errorReason(err))
+	return g.Ack()
+}
+
+// NackWithDelay nacks the message after the delay. The client keeps extending the message lease meanwhile,
+// and the subscription retry policy applies on top of it.
+func (g gcpOriginalMessage) NackWithDelay(delay time.Duration, _ error) error {
+	time.AfterFunc(delay, g.msg.Nack)
 	return nil
 }
 
@@ -29,36 +102,98 @@ func newGcpMessaging() *gcpMessaging {
 		logging.Fatal(context.Background()).Err(err).Msg(connectionError)
 	}
 
-	return &gcpMessaging{client}
+	return &gcpMessaging{client: client}
 }
 
 func (m *gcpMessaging) producer(ctx context.Context, p *Producer, msg *ProviderMessage) error {
+	body, attributes, err := encodeMessage(msg, cloudEventsAttributePrefix)
+	if err != nil {
+		return err
+	}
+
 	topic := m.client.Topic(p.topic)
-	result := topic.Publish(ctx, &pubsub.Message{Data: []byte(msg.String())})
-	_, err := result.Get(ctx)
+	topic.EnableMessageOrdering = msg.key != ""
+	result := topic.Publish(ctx, &pubsub.Message{Data: body, Attributes: attributes, OrderingKey: msg.key})
+	_, err = result.Get(ctx)
 	return err
 }
 
+// producerBatch publishes the messages through one topic handle, so the client batches them in publish requests
+// of up to pubsub.MaxPublishRequestCount messages, and waits for the result of each message.
+func (m *gcpMessaging) producerBatch(ctx context.Context, p *Producer, msgs []*ProviderMessage) []error {
+	topic := m.client.Topic(p.topic)
+	topic.PublishSettings.CountThreshold = min(max(len(msgs), 1), pubsub.MaxPublishRequestCount)
+	topic.EnableMessageOrdering = slices.ContainsFunc(msgs, func(msg *ProviderMessage) bool { return msg.key != "" })
+	defer topic.Stop()
+
+	errs := make([]error, len(msgs))
+	results := make([]*pubsub.PublishResult, len(msgs))
+	for i, msg := range msgs {
+		body, attributes, err := encodeMessage(msg, cloudEventsAttributePrefix)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+
+		results[i] = topic.Publish(ctx, &pubsub.Message{Data: body, Attributes: attributes, OrderingKey: msg.key})
+	}
+
+	for i, result := range results {
+		if result != nil {
+			_, errs[i] = result.Get(ctx)
+		}
+	}
+
+	return errs
+}
+
 func (m *gcpMessaging) consumer(ctx context.Context, c *consumer) (chan *ProviderMessage, error) {
-	ch := make(chan *ProviderMessage, 1)
+	ch := make(chan *ProviderMessage, c.options.Prefetch)
 	sub := m.client.Subscription(c.queue)
 
+	var deadLetterTopic string
+	if cfg, err := sub.Config(ctx); err != nil {
+		logging.Error(ctx).Err(err).Msgf(couldNotReadSubscriptionConfig, c.queue)
+	} else if cfg.DeadLetterPolicy != nil {
+		deadLetterTopic = cfg.DeadLetterPolicy.DeadLetterTopic
+	}
+
+	sub.ReceiveSettings.MaxOutstandingMessages = c.options.MaxInFlight + c.options.Prefetch
+	sub.ReceiveSettings.NumGoroutines = c.options.Concurrency
+
+	receiveCtx, cancel := context.WithCancel(ctx)
 	go func() {
-		if err := sub.Receive(ctx, func(innerCtx context.Context, msg *pubsub.Message) {
+		<-c.done
+		cancel()
+	}()
+
+	c.Add(1)
+	go func() {
+		defer c.Done()
+
+		if err := sub.Receive(receiveCtx, func(innerCtx context.Context, msg *pubsub.Message) {
 			if c.isCanceled() {
-				c.Done()
+				msg.Nack()
 				return
 			}
 
-			var pm ProviderMessage
-			if err := json.Unmarshal(msg.Data, &pm); err != nil {
+			pm, err := decodeMessage(msg.Data, msg.Attributes, cloudEventsAttributePrefix)
+			if err != nil {
 				logging.Error(ctx).Err(err).Msgf(couldNotReadMsgBody, msg.ID, c.queue)
+				// Nack publishes the message to the dead-letter topic and acknowledges it, logging when publishing fails
+				_ = gcpOriginalMessage{m: m, msg: msg, deadLetterTopic: deadLetterTopic, attempt: m.getAttempt(msg)}.Nack(false, err)
 				return
 			}
 
-			pm.addOriginBrokerNotification(gcpOriginalMessage{})
-			ch <- &pm
-			msg.Ack()
+			if msg.OrderingKey != "" {
+				pm.key = msg.OrderingKey
+			}
+			pm.attempt = m.getAttempt(msg)
+			pm.traceContext = msg.Attributes
+			pm.addOriginBrokerNotification(gcpOriginalMessage{m: m, msg: msg, deadLetterTopic: deadLetterTopic, attempt: pm.attempt})
+			if !c.deliver(ch, pm) {
+				msg.Nack()
+			}
 		}); err != nil {
 			logging.Error(ctx).Err(err).Msgf(couldNotReceiveMsg, c.queue)
 		}
@@ -66,3 +201,160 @@ func (m *gcpMessaging) consumer(ctx context.Context, c *consumer) (chan *Provide
 
 	return ch, nil
 }
+
+// queueDepth returns the last backlog of the subscription reported to Cloud Monitoring
+func (m *gcpMessaging) queueDepth(ctx context.Context, queue string) (int64, error) {
+	client, err := m.getMetricClient(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	series, err := client.ListTimeSeries(ctx, &monitoringpb.ListTimeSeriesRequest{
+		Name:   fmt.Sprintf("projects/%s", m.client.Project()),
+		Filter: fmt.Sprintf(`metric.type = "%s" AND resource.labels.subscription_id = "%s"`, gcpUndeliveredMessagesMetric, queue),
+		Interval: &monitoringpb.TimeInterval{
+			StartTime: timestamppb.New(now.Add(-gcpBacklogWindow)),
+			EndTime:   timestamppb.New(now),
+		},
+		View: monitoringpb.ListTimeSeriesRequest_FULL,
+	}).Next()
+	if errors.Is(err, iterator.Done) || (err == nil && len(series.GetPoints()) == 0) {
+		return 0, errBacklogNotAvailable
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	// points are ordered from the newest
+	return series.GetPoints()[0].GetValue().GetInt64Value(), nil
+}
+
+// readDeadLetters receives up to limit messages from the <queue>_DLQ subscription of the dead-letter topic.
+// The client extends the lease of the messages until every one is resolved, and Pub/Sub can only replay them to a topic.
+func (m *gcpMessaging) readDeadLetters(ctx context.Context, queue string, limit int) ([]*DeadLetter, error) {
+	sub := m.client.Subscription(queue + gcpDLQSuffix)
+	sub.ReceiveSettings.Synchronous = true
+	sub.ReceiveSettings.MaxOutstandingMessages = limit
+
+	receiveCtx, cancel := context.WithCancel(context.Background())
+	received := make(chan *pubsub.Message)
+	collected := make(chan struct{})
+	receiveErr := make(chan error, 1)
+	go func() {
+		receiveErr <- sub.Receive(receiveCtx, func(_ context.Context, msg *pubsub.Message) {
+			select {
+			case received <- msg:
+			case <-collected:
+				msg.Nack()
+			}
+		})
+	}()
+
+	var pending sync.WaitGroup
+	deadLetters := make([]*DeadLetter, 0, limit)
+	// undecodable messages are held until the read is over, so they are not received again by the same read
+	undecodable := make([]*pubsub.Message, 0)
+	err := func() error {
+		defer close(collected)
+		defer func() {
+			for _, msg := range undecodable {
+				msg.Nack()
+			}
+		}()
+		for len(deadLetters) < limit {
+			select {
+			case msg := <-received:
+				pm, err := decodeMessage(msg.Data, msg.Attributes, cloudEventsAttributePrefix)
+				if err != nil {
+					logging.Error(ctx).Err(err).Msgf(couldNotReadMsgBody, msg.ID, queue+gcpDLQSuffix)
+					undecodable = append(undecodable, msg)
+					continue
+				}
+				pending.Add(1)
+				deadLetters = append(deadLetters, newGcpDeadLetter(queue, pm, msg, pending.Done))
+			case err := <-receiveErr:
+				return err
+			case <-time.After(gcpDeadLetterWait):
+				return nil
+			case <-ctx.Done():
+				return ctx.Err()
+			}
+		}
+		return nil
+	}()
+	if err != nil {
+		releaseDeadLetters(ctx, deadLetters)
+		cancel()
+		return nil, err
+	}
+
+	go func() {
+		pending.Wait()
+		cancel()
+	}()
+
+	return deadLetters, nil
+}
+
+// newGcpDeadLetter returns the dead letter of a message received from the DLQ subscription, calling done once it is resolved
+func newGcpDeadLetter(queue string, pm *ProviderMessage, msg *pubsub.Message, done func()) *DeadLetter {
+	return &DeadLetter{
+		Message:  pm,
+		Queue:    queue,
+		Reason:   msg.Attributes[headerFailureReason],
+		Attempts: getDeadLetterAttempts(msg.Attributes[headerAttempts]),
+		remove: func(context.Context) error {
+			defer done()
+			msg.Ack()
+			return nil
+		},
+		release: func(context.Context) error {
+			defer done()
+			msg.Nack()
+			return nil
+		},
+	}
+}
+
+// getMetricClient returns the Cloud Monitoring client, creating it on first use
+func (m *gcpMessaging) getMetricClient(ctx context.Context) (*cloudmonitoring.MetricClient, error) {
+	m.metricMu.Lock()
+	defer m.metricMu.Unlock()
+
+	if m.metricClient == nil {
+		client, err := cloudmonitoring.NewMetricClient(ctx)
+		if err != nil {
+			return nil, err
+		}
+		m.metricClient = client
+	}
+
+	return m.metricClient, nil
+}
+
+// getAttempt returns the delivery attempt reported by Pub/Sub, which is only set for subscriptions with a
+// dead-letter policy, or counts the deliveries of the message in this process otherwise
+func (m *gcpMessaging) getAttempt(msg *pubsub.Message) int {
+	if msg.DeliveryAttempt != nil {
+		return max(*msg.DeliveryAttempt, 1)
+	}
+
+	attempt := 1
+	if previous, ok := m.attempts.Load(msg.ID); ok {
+		attempt = previous.(int) + 1
+	}
+	m.attempts.Store(msg.ID, attempt)
+
+	return attempt
+}
+
+// topic returns the topic referenced by a full resource name like projects/<project>/topics/<topic>
+func (m *gcpMessaging) topic(name string) *pubsub.Topic {
+	parts := strings.Split(name, "/")
+	if len(parts) == 4 && parts[0] == "projects" && parts[2] == "topics" {
+		return m.client.TopicInProject(parts[3], parts[1])
+	}
+
+	return m.client.Topic(name)
+}
@@ -0,0 +1,163 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/transaction"
+)
+
+const (
+	idempotencyDefaultRetention = 24 * time.Hour
+	idempotencyDefaultLease     = 5 * time.Minute
+
+	duplicatedMessageSkipped   = "message %s already processed by queue %s, skipping"
+	couldNotReleaseIdempotency = "could not release idempotency marker of message %s"
+)
+
+// ErrMessageInProgress is returned when another worker is processing the same message, so it is retried later
+var ErrMessageInProgress = errors.New("message is being processed by another consumer")
+
+// inProgressError is ErrMessageInProgress with the in-progress lease, after which the message is delivered again.
+// The consumer defers such messages outside the retry policy, so they are neither dead-lettered nor counted as attempts.
+type inProgressError struct {
+	lease time.Duration
+}
+
+func (e inProgressError) Error() string {
+	return ErrMessageInProgress.Error()
+}
+
+func (e inProgressError) Unwrap() error {
+	return ErrMessageInProgress
+}
+
+// DeduplicationState is the state of a message in a DeduplicationStore
+type DeduplicationState int
+
+const (
+	// DeduplicationNew means the message was not seen before and is now marked as in progress
+	DeduplicationNew DeduplicationState = iota
+	// DeduplicationInProgress means another worker holds the in-progress marker of the message
+	DeduplicationInProgress
+	// DeduplicationProcessed means the message was already processed within the retention window
+	DeduplicationProcessed
+)
+
+// DeduplicationStore records the messages processed by an idempotent consumer
+type DeduplicationStore interface {
+	// Begin marks the message as in progress for the lease duration, unless it is already in progress or processed.
+	Begin(ctx context.Context, key string, lease time.Duration) (DeduplicationState, error)
+	// Complete marks the message as processed for the retention duration.
+	Complete(ctx context.Context, key string, retention time.Duration) error
+	// Release removes the in-progress marker so the message can be processed again.
+	Release(ctx context.Context, key string) error
+}
+
+// IdempotentConsumer wraps a QueueConsumer, skipping messages already processed
+type IdempotentConsumer struct {
+	qc        QueueConsumer
+	store     DeduplicationStore
+	retention time.Duration
+	lease     time.Duration
+	tx        transaction.Transaction
+}
+
+// IdempotencyOption configures optional behaviour of an IdempotentConsumer
+type IdempotencyOption func(c *IdempotentConsumer)
+
+// WithRetention sets how long processed message IDs are kept. Duplicates arriving later are processed again.
+func WithRetention(retention time.Duration) IdempotencyOption {
+	return func(c *IdempotentConsumer) {
+		c.retention = retention
+	}
+}
+
+// WithInProgressLease sets how long the in-progress marker lives, bounding how long a crashed worker blocks a message
+func WithInProgressLease(lease time.Duration) IdempotencyOption {
+	return func(c *IdempotentConsumer) {
+		c.lease = lease
+	}
+}
+
+// WithTransaction runs the handler and the processed record in the same transaction.
+// With sqlDB.NewTransaction and a SQL store, the handler writes and the record are committed atomically.
+func WithTransaction(tx transaction.Transaction) IdempotencyOption {
+	return func(c *IdempotentConsumer) {
+		c.tx = tx
+	}
+}
+
+// NewIdempotentConsumer returns a consumer that records processed message IDs in the store and skips duplicates
+func NewIdempotentConsumer(qc QueueConsumer, store DeduplicationStore, opts ...IdempotencyOption) *IdempotentConsumer {
+	c := &IdempotentConsumer{
+		qc:        qc,
+		store:     store,
+		retention: idempotencyDefaultRetention,
+		lease:     idempotencyDefaultLease,
+	}
+	for _, opt := range opts {
+		opt(c)
+	}
+
+	return c
+}
+
+// QueueName returns the queue name of the wrapped consumer
+func (c *IdempotentConsumer) QueueName() string {
+	return c.qc.QueueName()
+}
+
+// TopicName returns the topic name of the wrapped consumer, or its queue name when it does not define one
+func (c *IdempotentConsumer) TopicName() string {
+	if tc, ok := c.qc.(TopicConsumer); ok {
+		return tc.TopicName()
+	}
+
+	return c.qc.QueueName()
+}
+
+// Consume processes the message with the wrapped consumer unless it was already processed.
+// Messages being processed by another worker return ErrMessageInProgress so the broker redelivers them once the lease expires.
+func (c *IdempotentConsumer) Consume(ctx context.Context, msg *ProviderMessage) error {
+	key := fmt.Sprintf("%s:%s", c.qc.QueueName(), msg.ID)
+
+	state, err := c.store.Begin(ctx, key, c.lease)
+	if err != nil {
+		return err
+	}
+
+	switch state {
+	case DeduplicationProcessed:
+		logging.Debug(ctx).Msgf(duplicatedMessageSkipped, msg.ID, c.qc.QueueName())
+		return nil
+	case DeduplicationInProgress:
+		return inProgressError{lease: c.lease}
+	}
+
+	process := func(ctx context.Context) error {
+		if err := c.qc.Consume(ctx, msg); err != nil {
+			return err
+		}
+
+		return c.store.Complete(ctx, key, c.retention)
+	}
+
+	if c.tx != nil {
+		err = c.tx.Execute(ctx, process)
+	} else {
+		err = process(ctx)
+	}
+
+	if err != nil {
+		if releaseErr := c.store.Release(ctx, key); releaseErr != nil {
+			logging.Error(ctx).Err(releaseErr).Msgf(couldNotReleaseIdempotency, msg.ID)
+		}
+		return err
+	}
+
+	return nil
+}
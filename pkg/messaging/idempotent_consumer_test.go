@@ -0,0 +1,148 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/test"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/database/cacheDB"
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIdempotentConsumer(t *testing.T) {
+	test.InitializeMemoryCacheDBTest()
+	cacheDB.Initialize()
+
+	ctx := context.Background()
+	store := NewCacheDeduplicationStore()
+
+	t.Run("Should skip messages already processed", func(t *testing.T) {
+		calls := 0
+		consumer := NewIdempotentConsumer(&queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				calls++
+				return nil
+			},
+			qName: "IDEMPOTENT_QUEUE",
+		}, store)
+		msg := NewProviderMessage(ctx, "create", userMessageTest{Name: "User"})
+
+		firstErr := consumer.Consume(ctx, msg)
+		secondErr := consumer.Consume(ctx, msg)
+
+		assert.NoError(t, firstErr)
+		assert.NoError(t, secondErr)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Should process message again when handler fails", func(t *testing.T) {
+		calls := 0
+		consumer := NewIdempotentConsumer(&queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				calls++
+				if calls == 1 {
+					return errors.New("service unavailable")
+				}
+				return nil
+			},
+			qName: "IDEMPOTENT_RETRY_QUEUE",
+		}, store)
+		msg := NewProviderMessage(ctx, "create", userMessageTest{Name: "User"})
+
+		firstErr := consumer.Consume(ctx, msg)
+		secondErr := consumer.Consume(ctx, msg)
+
+		assert.Error(t, firstErr)
+		assert.NoError(t, secondErr)
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("Should return in progress error while another worker processes the message", func(t *testing.T) {
+		consumer := NewIdempotentConsumer(&queueConsumerTest{
+			fn:    func(ctx context.Context, message *ProviderMessage) error { return nil },
+			qName: "IDEMPOTENT_IN_PROGRESS_QUEUE",
+		}, store)
+		msg := NewProviderMessage(ctx, "create", userMessageTest{Name: "User"})
+		state, beginErr := store.Begin(ctx, "IDEMPOTENT_IN_PROGRESS_QUEUE:"+msg.ID.String(), time.Minute)
+
+		err := consumer.Consume(ctx, msg)
+
+		assert.NoError(t, beginErr)
+		assert.Equal(t, DeduplicationNew, state)
+		assert.ErrorIs(t, err, ErrMessageInProgress)
+	})
+
+	t.Run("Should process message again after retention expires", func(t *testing.T) {
+		calls := 0
+		consumer := NewIdempotentConsumer(&queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				calls++
+				return nil
+			},
+			qName: "IDEMPOTENT_RETENTION_QUEUE",
+		}, store, WithRetention(100*time.Millisecond))
+		msg := &ProviderMessage{ID: uuid.New(), Action: "create"}
+
+		assert.NoError(t, consumer.Consume(ctx, msg))
+		time.Sleep(300 * time.Millisecond)
+		assert.NoError(t, consumer.Consume(ctx, msg))
+
+		assert.Equal(t, 2, calls)
+	})
+
+	t.Run("Should skip duplicated deliveries through the broker", func(t *testing.T) {
+		useMemoryMessaging(t)
+		Memory().Bind("IDEMPOTENT_TOPIC", "IDEMPOTENT_BROKER_QUEUE")
+		calls := 0
+		newConsumerTest(t, NewIdempotentConsumer(&queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				calls++
+				return nil
+			},
+			qName: "IDEMPOTENT_BROKER_QUEUE",
+			tName: "IDEMPOTENT_TOPIC",
+		}, store))
+
+		assert.NoError(t, NewProducer("IDEMPOTENT_TOPIC").Publish(ctx, "create", userMessageTest{Name: "User"}))
+		published := Memory().Published("IDEMPOTENT_TOPIC")[0]
+		duplicate, err := copyProviderMessage(published)
+		assert.NoError(t, err)
+		q := Memory().lookupQueue("IDEMPOTENT_BROKER_QUEUE")
+		Memory().enqueue(q, memoryDelivery{msg: duplicate, attempt: 1})
+
+		assert.Equal(t, 1, calls)
+		assert.Len(t, Memory().Acked("IDEMPOTENT_BROKER_QUEUE"), 2)
+	})
+
+	t.Run("Should defer in progress duplicates without dead-lettering them under the default retry policy", func(t *testing.T) {
+		useMemoryMessaging(t)
+		Memory().SetSynchronous(false)
+		attempts := make(chan int, 1)
+		newConsumerTest(t, NewIdempotentConsumer(&queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				attempts <- message.Attempt()
+				return nil
+			},
+			qName: "IDEMPOTENT_DEFER_QUEUE",
+		}, store, WithInProgressLease(50*time.Millisecond)))
+		msg := NewProviderMessage(ctx, "create", userMessageTest{Name: "User"})
+		_, beginErr := store.Begin(ctx, "IDEMPOTENT_DEFER_QUEUE:"+msg.ID.String(), time.Minute)
+
+		assert.NoError(t, instance.producer(ctx, NewProducer("IDEMPOTENT_DEFER_QUEUE"), msg))
+		deferred := Memory().DeadLetters("IDEMPOTENT_DEFER_QUEUE")
+		assert.NoError(t, store.Release(ctx, "IDEMPOTENT_DEFER_QUEUE:"+msg.ID.String()))
+
+		assert.NoError(t, beginErr)
+		assert.Empty(t, deferred)
+		select {
+		case attempt := <-attempts:
+			assert.Equal(t, 1, attempt)
+		case <-time.After(time.Second):
+			assert.Fail(t, "deferred message was not delivered again")
+		}
+		assert.Empty(t, Memory().DeadLetters("IDEMPOTENT_DEFER_QUEUE"))
+	})
+}
@@ -0,0 +1,417 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/segmentio/kafka-go"
+)
+
+const (
+	kafkaDefaultBrokers    = "localhost:9092"
+	kafkaBrokersEnvVar     = "KAFKA_BROKERS"
+	kafkaDLQTopicSuffix    = "_DLQ"
+	kafkaRetryTopicSuffix  = "_RETRY"
+	kafkaBatchTimeout      = 10 * time.Millisecond
+	kafkaFetchErrorBackoff = time.Second
+
+	kafkaHeaderMessageID     = "messageId"
+	kafkaHeaderCorrelationID = "correlationId"
+	kafkaHeaderAction        = "action"
+	kafkaHeaderOrigin        = "origin"
+	kafkaHeaderRetryAt       = "retryAt"
+
+	// kafkaCloudEventsPrefix is the CloudEvents Kafka protocol binding prefix of headers
+	kafkaCloudEventsPrefix = "ce_"
+
+	couldNotCommitMsg = "could not commit message %s from topic %s"
+)
+
+type kafkaMessaging struct {
+	brokers []string
+	writer  *kafka.Writer
+}
+
+type kafkaOriginalMessage struct {
+	m         *kafkaMessaging
+	committer *kafkaCommitter
+	msg       kafka.Message
+	queue     string
+}
+
+// kafkaCommitter commits the offsets of the messages fetched by a reader once they are acked or nacked.
+// Workers complete messages out of order, but Kafka offsets are cumulative, so only the highest offset
+// of each partition below which every message is completed is committed.
+type kafkaCommitter struct {
+	mu         sync.Mutex
+	commit     func(ctx context.Context, msgs ...kafka.Message) error
+	partitions map[int]*kafkaPartitionOffsets
+}
+
+// kafkaPartitionOffsets are the messages of a partition fetched and not committed yet, in offset order
+type kafkaPartitionOffsets struct {
+	pending   []kafka.Message
+	completed map[int64]bool
+}
+
+func newKafkaCommitter(commit func(ctx context.Context, msgs ...kafka.Message) error) *kafkaCommitter {
+	return &kafkaCommitter{commit: commit, partitions: make(map[int]*kafkaPartitionOffsets)}
+}
+
+// fetched tracks a message handed to the workers. A message at or below a pending offset
+// is fetched again after a rebalance, so the partition starts over from it.
+func (k *kafkaCommitter) fetched(msg kafka.Message) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	p, ok := k.partitions[msg.Partition]
+	if !ok || (len(p.pending) > 0 && msg.Offset <= p.pending[len(p.pending)-1].Offset) {
+		p = &kafkaPartitionOffsets{completed: make(map[int64]bool)}
+		k.partitions[msg.Partition] = p
+	}
+	p.pending = append(p.pending, msg)
+}
+
+// complete marks the message as completed and commits the partition up to its last contiguous completed message
+func (k *kafkaCommitter) complete(ctx context.Context, msg kafka.Message) error {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	p, ok := k.partitions[msg.Partition]
+	if !ok {
+		return nil
+	}
+	p.completed[msg.Offset] = true
+
+	var last *kafka.Message
+	for len(p.pending) > 0 && p.completed[p.pending[0].Offset] {
+		last = &p.pending[0]
+		delete(p.completed, last.Offset)
+		p.pending = p.pending[1:]
+	}
+	if last == nil {
+		return nil
+	}
+
+	return k.commit(ctx, *last)
+}
+
+// Ack commits the message offset for the consumer group, once the messages before it are completed too.
+func (k kafkaOriginalMessage) Ack() error {
+	return k.committer.complete(context.Background(), k.msg)
+}
+
+// Nack republishes the message to the retry topic of the queue when requeue is true, or to the DLQ topic otherwise,
+// and then commits the offset so the consumer group moves on.
+func (k kafkaOriginalMessage) Nack(requeue bool, err error) error {
+	if requeue {
+		return k.retry(0, getKafkaAttempt(k.msg)+1)
+	}
+
+	ctx := context.Background()
+	topic := k.queue + kafkaDLQTopicSuffix
+	headers := setKafkaHeader(k.msg.Headers, headerAttempts, strconv.Itoa(getKafkaAttempt(k.msg)))
+	if err != nil {
+		headers = setKafkaHeader(headers, headerFailureReason, err.Error())
+	}
+
+	if writeErr := k.write(ctx, topic, headers); writeErr != nil {
+		return writeErr
+	}
+
+	logging.Debug(ctx).Msgf(messageSentToDLQ, getKafkaHeader(k.msg.Headers, kafkaHeaderMessageID), topic, errorReason(err))
+	return k.committer.complete(ctx, k.msg)
+}
+
+// NackWithDelay republishes the message to the retry topic of the queue, to be consumed once the delay has passed
+func (k kafkaOriginalMessage) NackWithDelay(delay time.Duration, _ error) error {
+	return k.retry(delay, getKafkaAttempt(k.msg)+1)
+}
+
+// Defer republishes the message to the retry topic of the queue like NackWithDelay, keeping its attempt count.
+func (k kafkaOriginalMessage) Defer(delay time.Duration) error {
+	return k.retry(delay, getKafkaAttempt(k.msg))
+}
+
+// retry republishes the message to the retry topic of the queue, which only the consumer group of the queue reads,
+// so other groups of the topic do not process it again. The retry topic reader holds it until the delay has passed.
+func (k kafkaOriginalMessage) retry(delay time.Duration, attempt int) error {
+	ctx := context.Background()
+	headers := setKafkaHeader(k.msg.Headers, headerAttempts, strconv.Itoa(attempt))
+	headers = setKafkaHeader(headers, kafkaHeaderRetryAt, strconv.FormatInt(time.Now().Add(delay).UnixMilli(), 10))
+
+	if err := k.write(ctx, k.queue+kafkaRetryTopicSuffix, headers); err != nil {
+		return err
+	}
+
+	return k.committer.complete(ctx, k.msg)
+}
+
+// write publishes the message with the given headers to the topic
+func (k kafkaOriginalMessage) write(ctx context.Context, topic string, headers []kafka.Header) error {
+	return k.m.writer.WriteMessages(ctx, kafka.Message{
+		Topic:   topic,
+		Key:     k.msg.Key,
+		Value:   k.msg.Value,
+		Headers: headers,
+	})
+}
+
+func newKafkaMessaging() *kafkaMessaging {
+	brokers := os.Getenv(kafkaBrokersEnvVar)
+	if brokers == "" {
+		brokers = kafkaDefaultBrokers
+	}
+
+	m := &kafkaMessaging{brokers: strings.Split(brokers, ",")}
+
+	conn, err := kafka.Dial("tcp", m.brokers[0])
+	if err != nil {
+		logging.Fatal(context.Background()).Err(err).Msg(connectionError)
+	}
+	_ = conn.Close()
+
+	m.writer = &kafka.Writer{
+		Addr:                   kafka.TCP(m.brokers...),
+		Balancer:               &kafka.Hash{},
+		BatchTimeout:           kafkaBatchTimeout,
+		RequiredAcks:           kafka.RequireAll,
+		AllowAutoTopicCreation: true,
+	}
+
+	return m
+}
+
+func (m *kafkaMessaging) producer(ctx context.Context, p *Producer, msg *ProviderMessage) error {
+	kafkaMsg, err := getKafkaMessage(p.topic, msg)
+	if err != nil {
+		return err
+	}
+
+	return m.writer.WriteMessages(ctx, kafkaMsg)
+}
+
+// producerBatch writes the messages in one call, so the writer batches them per partition
+func (m *kafkaMessaging) producerBatch(ctx context.Context, p *Producer, msgs []*ProviderMessage) []error {
+	errs := make([]error, len(msgs))
+	kafkaMsgs := make([]kafka.Message, 0, len(msgs))
+	positions := make([]int, 0, len(msgs))
+	for i, msg := range msgs {
+		kafkaMsg, err := getKafkaMessage(p.topic, msg)
+		if err != nil {
+			errs[i] = err
+			continue
+		}
+		kafkaMsgs = append(kafkaMsgs, kafkaMsg)
+		positions = append(positions, i)
+	}
+
+	if len(kafkaMsgs) == 0 {
+		return errs
+	}
+
+	err := m.writer.WriteMessages(ctx, kafkaMsgs...)
+	var writeErrs kafka.WriteErrors
+	switch {
+	case errors.As(err, &writeErrs):
+		for j, writeErr := range writeErrs {
+			errs[positions[j]] = writeErr
+		}
+	case err != nil:
+		for _, i := range positions {
+			errs[i] = err
+		}
+	}
+
+	return errs
+}
+
+// consumer reads the topic and the retry topic of the queue, each with its own reader,
+// so retries waiting for their delay do not hold back the messages of the topic
+func (m *kafkaMessaging) consumer(ctx context.Context, c *consumer) (chan *ProviderMessage, error) {
+	readers := []*kafka.Reader{
+		kafka.NewReader(kafka.ReaderConfig{
+			Brokers:     m.brokers,
+			GroupID:     c.queue,
+			Topic:       c.topic,
+			StartOffset: kafka.FirstOffset,
+		}),
+		kafka.NewReader(kafka.ReaderConfig{
+			Brokers:     m.brokers,
+			GroupID:     c.queue + kafkaRetryTopicSuffix,
+			Topic:       c.queue + kafkaRetryTopicSuffix,
+			StartOffset: kafka.FirstOffset,
+		}),
+	}
+
+	ch := make(chan *ProviderMessage, c.options.Prefetch)
+	readCtx, cancel := context.WithCancel(ctx)
+	go func() {
+		<-c.done
+		cancel()
+	}()
+
+	for _, r := range readers {
+		c.Add(1)
+		go m.processMessages(readCtx, c, r, ch)
+	}
+
+	return ch, nil
+}
+
+// processMessages fetches messages without committing them, leaving the commit to Ack or Nack
+func (m *kafkaMessaging) processMessages(ctx context.Context, c *consumer, r *kafka.Reader, ch chan<- *ProviderMessage) {
+	defer c.Done()
+	committer := newKafkaCommitter(r.CommitMessages)
+	defer func() {
+		if err := r.Close(); err != nil {
+			logging.Error(ctx).Err(err).Msgf(closingQueueConsumer, c.queue)
+		}
+	}()
+
+	for {
+		msg, err := r.FetchMessage(ctx)
+		if err != nil {
+			if ctx.Err() != nil || errors.Is(err, context.Canceled) {
+				return
+			}
+
+			logging.Error(ctx).Err(err).Msgf(couldNotReceiveMsg, c.queue)
+			time.Sleep(kafkaFetchErrorBackoff)
+			continue
+		}
+
+		if !waitKafkaRetry(ctx, msg) {
+			return
+		}
+
+		committer.fetched(msg)
+		attributes := getKafkaAttributes(msg.Headers)
+		pm, err := decodeMessage(msg.Value, attributes, kafkaCloudEventsPrefix)
+		if err != nil {
+			logging.Error(ctx).Err(err).Msgf(couldNotReadMsgBody, getKafkaHeader(msg.Headers, kafkaHeaderMessageID), c.queue)
+			if err = (kafkaOriginalMessage{m: m, committer: committer, msg: msg, queue: c.queue}).Nack(false, err); err != nil {
+				logging.Error(ctx).Err(err).Msgf(couldNotCommitMsg, getKafkaHeader(msg.Headers, kafkaHeaderMessageID), msg.Topic)
+			}
+			continue
+		}
+
+		if pm.CorrelationID == "" {
+			pm.CorrelationID = getKafkaHeader(msg.Headers, kafkaHeaderCorrelationID)
+		}
+		pm.key = string(msg.Key)
+		pm.attempt = getKafkaAttempt(msg)
+		pm.traceContext = attributes
+
+		pm.addOriginBrokerNotification(kafkaOriginalMessage{m: m, committer: committer, msg: msg, queue: c.queue})
+		if !c.deliver(ch, pm) {
+			return
+		}
+	}
+}
+
+// getKafkaHeader returns the value of the first header with the given key
+func getKafkaHeader(headers []kafka.Header, key string) string {
+	for _, h := range headers {
+		if h.Key == key {
+			return string(h.Value)
+		}
+	}
+
+	return ""
+}
+
+// waitKafkaRetry waits until a message of a retry topic is due, returning false when ctx is done first.
+// Messages of a retry partition are held in order, so a retry with a long delay also holds the retries behind it.
+func waitKafkaRetry(ctx context.Context, msg kafka.Message) bool {
+	retryAt, err := strconv.ParseInt(getKafkaHeader(msg.Headers, kafkaHeaderRetryAt), 10, 64)
+	if err != nil {
+		return true
+	}
+
+	wait := time.Until(time.UnixMilli(retryAt))
+	if wait <= 0 {
+		return true
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// getKafkaAttempt returns the delivery attempt stored in the message headers, starting at 1
+func getKafkaAttempt(msg kafka.Message) int {
+	attempt, err := strconv.Atoi(getKafkaHeader(msg.Headers, headerAttempts))
+	if err != nil {
+		return 1
+	}
+
+	return max(attempt, 1)
+}
+
+// getKafkaMessage encodes the message as a Kafka message of the topic, keyed by the message ordering key
+func getKafkaMessage(topic string, msg *ProviderMessage) (kafka.Message, error) {
+	body, attributes, err := encodeMessage(msg, kafkaCloudEventsPrefix)
+	if err != nil {
+		return kafka.Message{}, err
+	}
+
+	headers := []kafka.Header{
+		{Key: kafkaHeaderMessageID, Value: []byte(msg.ID.String())},
+		{Key: kafkaHeaderCorrelationID, Value: []byte(msg.CorrelationID)},
+		{Key: kafkaHeaderAction, Value: []byte(msg.Action)},
+		{Key: kafkaHeaderOrigin, Value: []byte(msg.Origin)},
+	}
+	for key, value := range attributes {
+		headers = append(headers, kafka.Header{Key: key, Value: []byte(value)})
+	}
+
+	return kafka.Message{
+		Topic:   topic,
+		Key:     []byte(msg.key),
+		Value:   body,
+		Headers: headers,
+	}, nil
+}
+
+// getKafkaAttributes returns the message headers as message attributes
+func getKafkaAttributes(headers []kafka.Header) map[string]string {
+	carrier := make(map[string]string, len(headers))
+	for _, h := range headers {
+		carrier[h.Key] = string(h.Value)
+	}
+
+	return carrier
+}
+
+// setKafkaHeader returns a copy of headers with the given key set to value
+func setKafkaHeader(headers []kafka.Header, key, value string) []kafka.Header {
+	result := make([]kafka.Header, 0, len(headers)+1)
+	for _, h := range headers {
+		if h.Key != key {
+			result = append(result, h)
+		}
+	}
+
+	return append(result, kafka.Header{Key: key, Value: []byte(value)})
+}
+
+// errorReason returns the error message or an empty string when err is nil
+func errorReason(err error) string {
+	if err == nil {
+		return ""
+	}
+
+	return err.Error()
+}
@@ -0,0 +1,95 @@
+package messaging
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/segmentio/kafka-go"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKafkaHeaders(t *testing.T) {
+	headers := []kafka.Header{
+		{Key: kafkaHeaderMessageID, Value: []byte("id")},
+		{Key: kafkaHeaderCorrelationID, Value: []byte("correlation")},
+	}
+
+	t.Run("Should return header value", func(t *testing.T) {
+		assert.Equal(t, "correlation", getKafkaHeader(headers, kafkaHeaderCorrelationID))
+		assert.Empty(t, getKafkaHeader(headers, kafkaHeaderAction))
+	})
+
+	t.Run("Should replace header value without changing original headers", func(t *testing.T) {
+		result := setKafkaHeader(headers, kafkaHeaderCorrelationID, "other")
+
+		assert.Len(t, result, 2)
+		assert.Equal(t, "other", getKafkaHeader(result, kafkaHeaderCorrelationID))
+		assert.Equal(t, "correlation", getKafkaHeader(headers, kafkaHeaderCorrelationID))
+	})
+}
+
+func TestKafkaRetry(t *testing.T) {
+	retryAt := func(at time.Time) kafka.Message {
+		return kafka.Message{Headers: []kafka.Header{{Key: kafkaHeaderRetryAt, Value: []byte(strconv.FormatInt(at.UnixMilli(), 10))}}}
+	}
+
+	t.Run("Should not wait for messages without retry time or already due", func(t *testing.T) {
+		assert.True(t, waitKafkaRetry(context.Background(), kafka.Message{}))
+		assert.True(t, waitKafkaRetry(context.Background(), retryAt(time.Now().Add(-time.Minute))))
+	})
+
+	t.Run("Should wait until the retry time", func(t *testing.T) {
+		start := time.Now()
+
+		assert.True(t, waitKafkaRetry(context.Background(), retryAt(start.Add(50*time.Millisecond))))
+		assert.GreaterOrEqual(t, time.Since(start), 40*time.Millisecond)
+	})
+
+	t.Run("Should stop waiting when the context is done", func(t *testing.T) {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+		defer cancel()
+
+		assert.False(t, waitKafkaRetry(ctx, retryAt(time.Now().Add(time.Hour))))
+	})
+}
+
+func TestKafkaCommitter(t *testing.T) {
+	message := func(partition int, offset int64) kafka.Message {
+		return kafka.Message{Partition: partition, Offset: offset}
+	}
+	newCommitterTest := func() (*kafkaCommitter, *[]kafka.Message) {
+		committed := make([]kafka.Message, 0)
+		return newKafkaCommitter(func(_ context.Context, msgs ...kafka.Message) error {
+			committed = append(committed, msgs...)
+			return nil
+		}), &committed
+	}
+
+	t.Run("Should commit only the highest contiguous completed offset of each partition", func(t *testing.T) {
+		committer, committed := newCommitterTest()
+		for offset := range int64(3) {
+			committer.fetched(message(0, offset))
+		}
+		committer.fetched(message(1, 10))
+
+		assert.NoError(t, committer.complete(context.Background(), message(0, 2)))
+		assert.NoError(t, committer.complete(context.Background(), message(0, 1)))
+		assert.Empty(t, *committed)
+
+		assert.NoError(t, committer.complete(context.Background(), message(1, 10)))
+		assert.NoError(t, committer.complete(context.Background(), message(0, 0)))
+		assert.Equal(t, []kafka.Message{message(1, 10), message(0, 2)}, *committed)
+	})
+
+	t.Run("Should start a partition over when its messages are fetched again after a rebalance", func(t *testing.T) {
+		committer, committed := newCommitterTest()
+		committer.fetched(message(0, 5))
+		committer.fetched(message(0, 6))
+		committer.fetched(message(0, 5))
+
+		assert.NoError(t, committer.complete(context.Background(), message(0, 5)))
+		assert.Equal(t, []kafka.Message{message(0, 5)}, *committed)
+	})
+}
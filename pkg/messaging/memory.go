@@ -0,0 +1,544 @@
+package messaging
+
+import (
+	"context"
+	"maps"
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+)
+
+const memoryDefaultMaxAttempts = 3
+
+// MemoryDeadLetter is a message moved to the dead-letter list of a queue by the in-memory broker
+type MemoryDeadLetter struct {
+	Message  *ProviderMessage
+	Reason   string
+	Attempts int
+}
+
+// MemoryBroker is an in-process broker used by COLIBRI_MESSAGING=MEMORY.
+//
+// Topics are bound to queues in code with Bind; a queue with the same name as a topic is bound implicitly.
+// Every bound queue receives its own copy of each published message (fan-out).
+// When synchronous, Publish only returns after every consumer has processed the message, which makes tests deterministic,
+// and delayed messages are held until DeliverScheduled is called.
+type MemoryBroker struct {
+	mu          sync.Mutex
+	bindings    map[string][]string
+	queues      map[string]*memoryQueue
+	published   map[string][]*ProviderMessage
+	scheduled   []memoryScheduled
+	synchronous bool
+	maxAttempts int
+	replyTo     string
+}
+
+type memoryScheduled struct {
+	topic string
+	msg   *ProviderMessage
+}
+
+type memoryQueue struct {
+	mu          sync.Mutex
+	name        string
+	consumer    *consumer
+	ch          chan *ProviderMessage
+	pending     []memoryDelivery
+	dispatching bool
+	forwarding  []memoryDelivery
+	forwarder   bool
+	acked       []*ProviderMessage
+	deadLetters []MemoryDeadLetter
+}
+
+type memoryDelivery struct {
+	msg     *ProviderMessage
+	attempt int
+}
+
+type memoryOriginalMessage struct {
+	b       *MemoryBroker
+	q       *memoryQueue
+	msg     *ProviderMessage
+	attempt int
+}
+
+// Ack records the message as processed by the queue.
+func (m memoryOriginalMessage) Ack() error {
+	m.q.mu.Lock()
+	defer m.q.mu.Unlock()
+
+	m.q.acked = append(m.q.acked, m.msg)
+	return nil
+}
+
+// Nack redelivers the message when requeue is true and the queue has attempts left, or moves it to the dead-letter list otherwise.
+func (m memoryOriginalMessage) Nack(requeue bool, err error) error {
+	if requeue && m.attempt < m.b.getMaxAttempts() {
+		m.b.enqueue(m.q, memoryDelivery{msg: m.msg, attempt: m.attempt + 1})
+		return nil
+	}
+
+	m.q.mu.Lock()
+	defer m.q.mu.Unlock()
+
+	m.q.deadLetters = append(m.q.deadLetters, MemoryDeadLetter{Message: m.msg, Reason: errorReason(err), Attempts: m.attempt})
+	logging.Debug(context.Background()).Msgf(messageSentToDLQ, m.msg.ID, m.q.name, errorReason(err))
+	return nil
+}
+
+// NackWithDelay redelivers the message after the delay, or right away when the broker is synchronous.
+func (m memoryOriginalMessage) NackWithDelay(delay time.Duration, _ error) error {
+	d := memoryDelivery{msg: m.msg, attempt: m.attempt + 1}
+	if m.b.isSynchronous() {
+		m.b.enqueue(m.q, d)
+		return nil
+	}
+
+	time.AfterFunc(delay, func() { m.b.enqueue(m.q, d) })
+	return nil
+}
+
+// Defer redelivers the message after the delay keeping its attempt count, even when the broker is synchronous,
+// since the message is deferred until something else happens, like another worker finishing it.
+func (m memoryOriginalMessage) Defer(delay time.Duration) error {
+	d := memoryDelivery{msg: m.msg, attempt: m.attempt}
+	time.AfterFunc(delay, func() { m.b.enqueue(m.q, d) })
+	return nil
+}
+
+func newMemoryMessaging() *MemoryBroker {
+	return &MemoryBroker{
+		bindings:    make(map[string][]string),
+		queues:      make(map[string]*memoryQueue),
+		published:   make(map[string][]*ProviderMessage),
+		synchronous: config.IsTestEnvironment(),
+		maxAttempts: memoryDefaultMaxAttempts,
+	}
+}
+
+// Memory returns the in-memory broker, or nil when messaging is not initialized with COLIBRI_MESSAGING=MEMORY
+func Memory() *MemoryBroker {
+	b, _ := instance.(*MemoryBroker)
+	return b
+}
+
+// Bind routes messages published to the topic to the queue
+func (b *MemoryBroker) Bind(topic, queue string) *MemoryBroker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.bindings[topic] = append(b.bindings[topic], queue)
+	b.getQueue(queue)
+	return b
+}
+
+// SetSynchronous defines whether Publish delivers messages to consumers before returning
+func (b *MemoryBroker) SetSynchronous(synchronous bool) *MemoryBroker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.synchronous = synchronous
+	return b
+}
+
+// SetMaxAttempts defines how many times a requeued message is delivered before it is dead-lettered
+func (b *MemoryBroker) SetMaxAttempts(maxAttempts int) *MemoryBroker {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.maxAttempts = maxAttempts
+	return b
+}
+
+// Published returns the messages published to the topic, in publish order
+func (b *MemoryBroker) Published(topic string) []*ProviderMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return append([]*ProviderMessage(nil), b.published[topic]...)
+}
+
+// Scheduled returns the delayed messages of the topic held by a synchronous broker, in publish order
+func (b *MemoryBroker) Scheduled(topic string) []*ProviderMessage {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	result := make([]*ProviderMessage, 0)
+	for _, s := range b.scheduled {
+		if s.topic == topic {
+			result = append(result, s.msg)
+		}
+	}
+
+	return result
+}
+
+// DeliverScheduled publishes the delayed messages held by a synchronous broker, regardless of their delay
+func (b *MemoryBroker) DeliverScheduled() error {
+	b.mu.Lock()
+	scheduled := b.scheduled
+	b.scheduled = nil
+	b.mu.Unlock()
+
+	for _, s := range scheduled {
+		if err := b.producer(context.Background(), NewProducer(s.topic), s.msg); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Acked returns the messages acknowledged by the consumer of the queue
+func (b *MemoryBroker) Acked(queue string) []*ProviderMessage {
+	q := b.lookupQueue(queue)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]*ProviderMessage(nil), q.acked...)
+}
+
+// DeadLetters returns the messages moved to the dead-letter list of the queue
+func (b *MemoryBroker) DeadLetters(queue string) []MemoryDeadLetter {
+	q := b.lookupQueue(queue)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return append([]MemoryDeadLetter(nil), q.deadLetters...)
+}
+
+// Reset clears the published, acknowledged and dead-lettered messages, keeping bindings and consumers
+func (b *MemoryBroker) Reset() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.published = make(map[string][]*ProviderMessage)
+	b.scheduled = nil
+	for _, q := range b.queues {
+		q.mu.Lock()
+		q.acked = nil
+		q.deadLetters = nil
+		q.pending = nil
+		q.mu.Unlock()
+	}
+}
+
+// PublishedMessages decodes the payloads of the messages published to the topic with the given action.
+// An empty action matches every message.
+func PublishedMessages[T any](topic, action string) ([]T, error) {
+	b := Memory()
+	if b == nil {
+		return nil, nil
+	}
+
+	result := make([]T, 0)
+	for _, msg := range b.Published(topic) {
+		if action != "" && msg.Action != action {
+			continue
+		}
+
+		var model T
+		if err := msg.DecodeMessage(&model); err != nil {
+			return nil, err
+		}
+		result = append(result, model)
+	}
+
+	return result, nil
+}
+
+func (b *MemoryBroker) producer(_ context.Context, p *Producer, msg *ProviderMessage) error {
+	b.mu.Lock()
+	b.published[p.topic] = append(b.published[p.topic], msg)
+	queues := b.boundQueues(p.topic)
+	b.mu.Unlock()
+
+	for _, q := range queues {
+		delivery, err := copyProviderMessage(msg)
+		if err != nil {
+			return err
+		}
+		b.enqueue(q, memoryDelivery{msg: delivery, attempt: 1})
+	}
+
+	return nil
+}
+
+// producerWithDelay publishes the message after the delay, or holds it until DeliverScheduled when the broker is synchronous
+func (b *MemoryBroker) producerWithDelay(ctx context.Context, p *Producer, msg *ProviderMessage, delay time.Duration) error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.synchronous {
+		b.scheduled = append(b.scheduled, memoryScheduled{topic: p.topic, msg: msg})
+		return nil
+	}
+
+	time.AfterFunc(delay, func() {
+		if err := b.producer(ctx, p, msg); err != nil {
+			logging.Error(ctx).Err(err).Msgf(couldNotSendMsg, msg.ID, p.topic)
+		}
+	})
+	return nil
+}
+
+// replyQueue returns the reply queue of the broker. Replies are handed to the waiting requests without being queued.
+func (b *MemoryBroker) replyQueue(_ context.Context) (string, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if b.replyTo == "" {
+		b.replyTo = getReplyQueueName()
+	}
+
+	return b.replyTo, nil
+}
+
+func (b *MemoryBroker) reply(ctx context.Context, _ string, msg *ProviderMessage) error {
+	reply, err := copyProviderMessage(msg)
+	if err != nil {
+		return err
+	}
+
+	deliverReply(context.WithoutCancel(ctx), reply)
+	return nil
+}
+
+func (b *MemoryBroker) consumer(_ context.Context, c *consumer) (chan *ProviderMessage, error) {
+	b.mu.Lock()
+	q := b.getQueue(c.queue)
+	b.mu.Unlock()
+
+	q.mu.Lock()
+	q.consumer = c
+	backlog := q.pending
+	q.pending = nil
+	q.mu.Unlock()
+
+	for _, d := range backlog {
+		b.enqueue(q, d)
+	}
+
+	return q.ch, nil
+}
+
+// enqueue delivers the message to the queue consumer, keeping it pending while the queue has no running consumer
+func (b *MemoryBroker) enqueue(q *memoryQueue, d memoryDelivery) {
+	q.mu.Lock()
+	if q.consumer == nil || q.consumer.isCanceled() || b.isSynchronous() {
+		q.pending = append(q.pending, d)
+		q.mu.Unlock()
+		b.dispatch(q)
+		return
+	}
+	q.mu.Unlock()
+
+	b.forward(q, d)
+}
+
+// deliver returns the copy of the message handed to the consumer, so redeliveries are not affected by the consumer
+// changing it, like resolving its claim-check or decrypting its payload
+func (b *MemoryBroker) deliver(q *memoryQueue, d memoryDelivery) *ProviderMessage {
+	msg := *d.msg
+	msg.Headers = maps.Clone(d.msg.Headers)
+	msg.attempt = d.attempt
+	msg.addOriginBrokerNotification(memoryOriginalMessage{b: b, q: q, msg: d.msg, attempt: d.attempt})
+
+	return &msg
+}
+
+// forward sends the message to the queue consumer in the background, keeping the enqueue order.
+// When the consumer stops, the messages not sent yet are kept pending for the next consumer of the queue.
+func (b *MemoryBroker) forward(q *memoryQueue, d memoryDelivery) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	q.forwarding = append(q.forwarding, d)
+	if q.forwarder {
+		return
+	}
+	q.forwarder = true
+
+	go func() {
+		for {
+			q.mu.Lock()
+			c := q.consumer
+			if len(q.forwarding) == 0 || c == nil || c.isCanceled() {
+				q.pending = append(q.forwarding, q.pending...)
+				q.forwarding = nil
+				q.forwarder = false
+				q.mu.Unlock()
+				return
+			}
+			next := q.forwarding[0]
+			q.forwarding = q.forwarding[1:]
+			q.mu.Unlock()
+
+			if !c.deliver(q.ch, b.deliver(q, next)) {
+				q.mu.Lock()
+				q.forwarding = append([]memoryDelivery{next}, q.forwarding...)
+				q.mu.Unlock()
+			}
+		}
+	}()
+}
+
+// dispatch processes pending messages inline until the queue is empty or its consumer is paused or stopped.
+// Nested publishes to a queue that is already dispatching are processed by the outer call, keeping delivery order.
+func (b *MemoryBroker) dispatch(q *memoryQueue) {
+	q.mu.Lock()
+	if q.consumer == nil || q.dispatching || q.consumer.isPaused() || q.consumer.isCanceled() {
+		q.mu.Unlock()
+		return
+	}
+	q.dispatching = true
+	q.mu.Unlock()
+
+	for {
+		q.mu.Lock()
+		if len(q.pending) == 0 {
+			q.dispatching = false
+			q.mu.Unlock()
+			return
+		}
+		c := q.consumer
+		if c.isPaused() || c.isCanceled() {
+			q.dispatching = false
+			q.mu.Unlock()
+			return
+		}
+		d := q.pending[0]
+		q.pending = q.pending[1:]
+		q.mu.Unlock()
+
+		processMessage(c, b.deliver(q, d))
+	}
+}
+
+// resume processes the messages held while the consumer was paused when the broker is synchronous
+func (b *MemoryBroker) resume(c *consumer) {
+	if !b.isSynchronous() {
+		return
+	}
+
+	b.dispatch(b.lookupQueue(c.queue))
+}
+
+// readDeadLetters takes up to limit messages out of the dead-letter list of the queue until they are released
+func (b *MemoryBroker) readDeadLetters(_ context.Context, queue string, limit int) ([]*DeadLetter, error) {
+	q := b.lookupQueue(queue)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	held := q.deadLetters[:min(limit, len(q.deadLetters))]
+	q.deadLetters = slices.Clone(q.deadLetters[len(held):])
+
+	deadLetters := make([]*DeadLetter, 0, len(held))
+	for _, d := range held {
+		deadLetters = append(deadLetters, &DeadLetter{
+			Message:  d.Message,
+			Queue:    queue,
+			Reason:   d.Reason,
+			Attempts: d.Attempts,
+			remove:   func(context.Context) error { return nil },
+			release: func(context.Context) error {
+				q.mu.Lock()
+				defer q.mu.Unlock()
+
+				q.deadLetters = append(q.deadLetters, d)
+				return nil
+			},
+			requeue: func(context.Context) error {
+				msg, err := copyProviderMessage(d.Message)
+				if err != nil {
+					return err
+				}
+
+				b.enqueue(q, memoryDelivery{msg: msg, attempt: 1})
+				return nil
+			},
+		})
+	}
+
+	return deadLetters, nil
+}
+
+// queueDepth returns the messages of the queue not yet handed to its consumer
+func (b *MemoryBroker) queueDepth(_ context.Context, queue string) (int64, error) {
+	q := b.lookupQueue(queue)
+	q.mu.Lock()
+	defer q.mu.Unlock()
+
+	return int64(len(q.pending) + len(q.forwarding) + len(q.ch)), nil
+}
+
+// boundQueues returns the queues bound to the topic, including the queue with the same name as the topic
+func (b *MemoryBroker) boundQueues(topic string) []*memoryQueue {
+	names := b.bindings[topic]
+	if _, ok := b.queues[topic]; ok && !slices.Contains(names, topic) {
+		names = append([]string{topic}, names...)
+	}
+
+	queues := make([]*memoryQueue, 0, len(names))
+	for _, name := range names {
+		queues = append(queues, b.getQueue(name))
+	}
+
+	return queues
+}
+
+// getQueue returns the queue with the given name, creating it when it does not exist. The caller must hold b.mu.
+func (b *MemoryBroker) getQueue(name string) *memoryQueue {
+	q, ok := b.queues[name]
+	if !ok {
+		q = &memoryQueue{name: name, ch: make(chan *ProviderMessage)}
+		b.queues[name] = q
+	}
+
+	return q
+}
+
+// lookupQueue returns the queue with the given name, creating it when it does not exist
+func (b *MemoryBroker) lookupQueue(name string) *memoryQueue {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.getQueue(name)
+}
+
+func (b *MemoryBroker) isSynchronous() bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.synchronous
+}
+
+func (b *MemoryBroker) getMaxAttempts() int {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	return b.maxAttempts
+}
+
+// copyProviderMessage returns a copy of the message as a broker would deliver it, encoded and decoded with its encoding
+func copyProviderMessage(msg *ProviderMessage) (*ProviderMessage, error) {
+	body, attributes, err := encodeMessage(msg, cloudEventsAttributePrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	pm, err := decodeMessage(body, attributes, cloudEventsAttributePrefix)
+	if err != nil {
+		return nil, err
+	}
+	pm.key = msg.key
+	pm.encoding = msg.encoding
+	pm.traceContext = attributes
+
+	return pm, nil
+}
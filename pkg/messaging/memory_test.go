@@ -0,0 +1,351 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"os"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/test"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/propagation"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type orderedMessageTest struct {
+	Sequence int `json:"sequence"`
+}
+
+func TestMemoryMessaging(t *testing.T) {
+	useMemoryMessaging(t)
+
+	t.Run("Asynchronous delivery", func(t *testing.T) {
+		Memory().SetSynchronous(false).
+			Bind(testTopicName, testQueueName).
+			Bind(testFailTopicName, testFailQueueName)
+		executeMessagingTest(t)
+		Memory().SetSynchronous(true)
+	})
+
+	t.Run("Should fan out messages to every bound queue synchronously", func(t *testing.T) {
+		Memory().Bind("MEMORY_FANOUT_TOPIC", "MEMORY_FANOUT_QUEUE_A").Bind("MEMORY_FANOUT_TOPIC", "MEMORY_FANOUT_QUEUE_B")
+		received := make([]string, 0)
+		for _, queue := range []string{"MEMORY_FANOUT_QUEUE_A", "MEMORY_FANOUT_QUEUE_B"} {
+			newConsumerTest(t, &queueConsumerTest{
+				fn: func(ctx context.Context, message *ProviderMessage) error {
+					received = append(received, queue)
+					return nil
+				},
+				qName: queue,
+			})
+		}
+
+		err := NewProducer("MEMORY_FANOUT_TOPIC").Publish(context.Background(), "create", userMessageTest{Name: "User"})
+
+		assert.NoError(t, err)
+		assert.ElementsMatch(t, []string{"MEMORY_FANOUT_QUEUE_A", "MEMORY_FANOUT_QUEUE_B"}, received)
+		assert.Len(t, Memory().Acked("MEMORY_FANOUT_QUEUE_A"), 1)
+		assert.Len(t, Memory().Acked("MEMORY_FANOUT_QUEUE_B"), 1)
+	})
+
+	t.Run("Should deliver backlog when consumer is registered", func(t *testing.T) {
+		Memory().Bind("MEMORY_BACKLOG_TOPIC", "MEMORY_BACKLOG_QUEUE")
+		err := NewProducer("MEMORY_BACKLOG_TOPIC").Publish(context.Background(), "create", userMessageTest{Name: "User"})
+		assert.NoError(t, err)
+
+		var received *ProviderMessage
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				received = message
+				return nil
+			},
+			qName: "MEMORY_BACKLOG_QUEUE",
+		})
+
+		assert.NotNil(t, received)
+		assert.Equal(t, "create", received.Action)
+	})
+
+	t.Run("Should move failed messages to dead letters", func(t *testing.T) {
+		Memory().Bind("MEMORY_DLQ_TOPIC", "MEMORY_DLQ_QUEUE")
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				return errors.New("email not valid")
+			},
+			qName: "MEMORY_DLQ_QUEUE",
+		})
+
+		err := NewProducer("MEMORY_DLQ_TOPIC").Publish(context.Background(), "create", userMessageTest{Name: "User"})
+		deadLetters := Memory().DeadLetters("MEMORY_DLQ_QUEUE")
+
+		assert.NoError(t, err)
+		assert.Len(t, deadLetters, 1)
+		assert.Equal(t, "email not valid", deadLetters[0].Reason)
+	})
+
+	t.Run("Should redeliver requeued messages until max attempts", func(t *testing.T) {
+		Memory().SetMaxAttempts(3)
+		q := Memory().lookupQueue("MEMORY_REQUEUE_QUEUE")
+		msg := NewProviderMessage(context.Background(), "create", userMessageTest{Name: "User"})
+		attempts := 0
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				attempts++
+				_ = message.Nack(true, errors.New("temporary"))
+				return nil
+			},
+			qName: "MEMORY_REQUEUE_QUEUE",
+		})
+
+		Memory().enqueue(q, memoryDelivery{msg: msg, attempt: 1})
+
+		assert.Equal(t, 3, attempts)
+		assert.Len(t, Memory().DeadLetters("MEMORY_REQUEUE_QUEUE"), 1)
+	})
+
+	t.Run("Should retry failed messages with the consumer retry policy before dead-lettering", func(t *testing.T) {
+		Memory().Bind("MEMORY_RETRY_TOPIC", "MEMORY_RETRY_QUEUE")
+		attempts := make([]int, 0)
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				attempts = append(attempts, message.Attempt())
+				return errors.New("service unavailable")
+			},
+			qName: "MEMORY_RETRY_QUEUE",
+		}, WithRetryPolicy(RetryPolicy{MaxAttempts: 3, InitialBackoff: time.Millisecond}))
+
+		err := NewProducer("MEMORY_RETRY_TOPIC").Publish(context.Background(), "create", userMessageTest{Name: "User"})
+		deadLetters := Memory().DeadLetters("MEMORY_RETRY_QUEUE")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []int{1, 2, 3}, attempts)
+		assert.Len(t, deadLetters, 1)
+		assert.Equal(t, 3, deadLetters[0].Attempts)
+		assert.Equal(t, "service unavailable", deadLetters[0].Reason)
+	})
+
+	t.Run("Should return published messages by topic and action", func(t *testing.T) {
+		Memory().Reset()
+		producer := NewProducer("MEMORY_ASSERT_TOPIC")
+		assert.NoError(t, producer.Publish(context.Background(), "create", userMessageTest{Name: "Created"}))
+		assert.NoError(t, producer.Publish(context.Background(), "delete", userMessageTest{Name: "Deleted"}))
+
+		all, allErr := PublishedMessages[userMessageTest]("MEMORY_ASSERT_TOPIC", "")
+		created, createdErr := PublishedMessages[userMessageTest]("MEMORY_ASSERT_TOPIC", "create")
+
+		assert.NoError(t, allErr)
+		assert.Len(t, all, 2)
+		assert.NoError(t, createdErr)
+		assert.Equal(t, []userMessageTest{{Name: "Created"}}, created)
+	})
+
+	t.Run("Should process messages concurrently with worker pool", func(t *testing.T) {
+		Memory().SetSynchronous(false).Bind("MEMORY_CONCURRENCY_TOPIC", "MEMORY_CONCURRENCY_QUEUE")
+		defer Memory().SetSynchronous(true)
+
+		const workers = 4
+		var running, maxRunning atomic.Int32
+		release := make(chan struct{})
+		var processed sync.WaitGroup
+		processed.Add(workers)
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				defer processed.Done()
+				current := running.Add(1)
+				for {
+					previous := maxRunning.Load()
+					if current <= previous || maxRunning.CompareAndSwap(previous, current) {
+						break
+					}
+				}
+				<-release
+				running.Add(-1)
+				return nil
+			},
+			qName: "MEMORY_CONCURRENCY_QUEUE",
+		}, WithConcurrency(workers), WithPrefetch(workers))
+
+		for range workers {
+			assert.NoError(t, NewProducer("MEMORY_CONCURRENCY_TOPIC").Publish(context.Background(), "create", userMessageTest{Name: "User"}))
+		}
+
+		assert.Eventually(t, func() bool { return running.Load() == workers }, time.Second, 5*time.Millisecond)
+		close(release)
+		processed.Wait()
+
+		assert.Equal(t, int32(workers), maxRunning.Load())
+		assert.Eventually(t, func() bool { return len(Memory().Acked("MEMORY_CONCURRENCY_QUEUE")) == workers }, time.Second, 5*time.Millisecond)
+	})
+
+	t.Run("Should propagate trace context from producer to consumer", func(t *testing.T) {
+		previousPropagator := otel.GetTextMapPropagator()
+		otel.SetTextMapPropagator(propagation.TraceContext{})
+		defer otel.SetTextMapPropagator(previousPropagator)
+
+		Memory().Bind("MEMORY_TRACE_TOPIC", "MEMORY_TRACE_QUEUE")
+		var consumerSpan trace.SpanContext
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				consumerSpan = trace.SpanContextFromContext(ctx)
+				return nil
+			},
+			qName: "MEMORY_TRACE_QUEUE",
+		})
+
+		ctx, span := sdktrace.NewTracerProvider().Tracer("test").Start(context.Background(), "request")
+		err := NewProducer("MEMORY_TRACE_TOPIC").Publish(ctx, "create", userMessageTest{Name: "User"})
+		span.End()
+
+		assert.NoError(t, err)
+		assert.Contains(t, Memory().Published("MEMORY_TRACE_TOPIC")[0].traceContext, "traceparent")
+		assert.True(t, consumerSpan.IsValid())
+		assert.Equal(t, trace.SpanContextFromContext(ctx).TraceID(), consumerSpan.TraceID())
+	})
+
+	t.Run("Should deliver headers and occurredAt with CloudEvents encoding", func(t *testing.T) {
+		Memory().Bind("MEMORY_CLOUDEVENTS_TOPIC", "MEMORY_CLOUDEVENTS_QUEUE")
+		var received *ProviderMessage
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				received = message
+				return nil
+			},
+			qName: "MEMORY_CLOUDEVENTS_QUEUE",
+		})
+
+		err := NewProducer("MEMORY_CLOUDEVENTS_TOPIC", WithEncoding(EncodingCloudEventsBinary)).
+			Publish(context.Background(), "create", userMessageTest{Name: "User"}, WithHeader("tenant", "colibri"))
+
+		assert.NoError(t, err)
+		assert.NotNil(t, received)
+		assert.Equal(t, "create", received.Action)
+		assert.Equal(t, "colibri", received.Header("tenant"))
+		assert.False(t, received.OccurredAt.IsZero())
+		assert.Equal(t, "1.0", received.traceContext["ce-specversion"])
+	})
+
+	t.Run("Should hold delayed messages until they are delivered when synchronous", func(t *testing.T) {
+		Memory().Bind("MEMORY_DELAYED_TOPIC", "MEMORY_DELAYED_QUEUE")
+		received := make([]string, 0)
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				received = append(received, message.Action)
+				return nil
+			},
+			qName: "MEMORY_DELAYED_QUEUE",
+		})
+		producer := NewProducer("MEMORY_DELAYED_TOPIC")
+
+		afterErr := producer.PublishAfter(context.Background(), 30*time.Minute, "remind", userMessageTest{Name: "User"})
+		atErr := producer.PublishAt(context.Background(), time.Now().Add(-time.Minute), "create", userMessageTest{Name: "User"})
+
+		assert.NoError(t, afterErr)
+		assert.NoError(t, atErr)
+		assert.Equal(t, []string{"create"}, received)
+		assert.Len(t, Memory().Scheduled("MEMORY_DELAYED_TOPIC"), 1)
+
+		assert.NoError(t, Memory().DeliverScheduled())
+		assert.Equal(t, []string{"create", "remind"}, received)
+		assert.Empty(t, Memory().Scheduled("MEMORY_DELAYED_TOPIC"))
+	})
+
+	t.Run("Should deliver delayed messages after the delay when asynchronous", func(t *testing.T) {
+		Memory().SetSynchronous(false)
+		defer Memory().SetSynchronous(true)
+		Memory().Bind("MEMORY_DELAYED_ASYNC_TOPIC", "MEMORY_DELAYED_ASYNC_QUEUE")
+		delivered := make(chan time.Time, 1)
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				delivered <- time.Now()
+				return nil
+			},
+			qName: "MEMORY_DELAYED_ASYNC_QUEUE",
+		})
+
+		publishedAt := time.Now()
+		err := NewProducer("MEMORY_DELAYED_ASYNC_TOPIC").PublishAfter(context.Background(), 100*time.Millisecond, "remind", userMessageTest{Name: "User"})
+
+		assert.NoError(t, err)
+		select {
+		case deliveredAt := <-delivered:
+			assert.GreaterOrEqual(t, deliveredAt.Sub(publishedAt), 100*time.Millisecond)
+		case <-time.After(time.Second):
+			t.Fatal("delayed message not delivered")
+		}
+	})
+
+	t.Run("Should process messages sharing a key in order with concurrent workers", func(t *testing.T) {
+		Memory().SetSynchronous(false)
+		defer Memory().SetSynchronous(true)
+		Memory().Bind("MEMORY_ORDERED_TOPIC", "MEMORY_ORDERED_QUEUE")
+
+		const messagesPerKey = 10
+		var mu sync.Mutex
+		received := make(map[string][]int)
+		var processed sync.WaitGroup
+		processed.Add(2 * messagesPerKey)
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				defer processed.Done()
+				var event orderedMessageTest
+				if err := message.DecodeMessage(&event); err != nil {
+					return err
+				}
+				time.Sleep(time.Duration(messagesPerKey-event.Sequence) * time.Millisecond)
+
+				mu.Lock()
+				received[message.Key()] = append(received[message.Key()], event.Sequence)
+				mu.Unlock()
+				return nil
+			},
+			qName: "MEMORY_ORDERED_QUEUE",
+		}, WithConcurrency(4), WithPrefetch(4))
+
+		producer := NewProducer("MEMORY_ORDERED_TOPIC")
+		for i := range messagesPerKey {
+			assert.NoError(t, producer.PublishWithKey(context.Background(), "order-1", "update", orderedMessageTest{Sequence: i}))
+			assert.NoError(t, producer.PublishWithKey(context.Background(), "order-2", "update", orderedMessageTest{Sequence: i}))
+		}
+		processed.Wait()
+
+		expected := []int{0, 1, 2, 3, 4, 5, 6, 7, 8, 9}
+		assert.Equal(t, expected, received["order-1"])
+		assert.Equal(t, expected, received["order-2"])
+	})
+
+	t.Run("Should work with test producer using same topic and queue", func(t *testing.T) {
+		Memory().SetSynchronous(false)
+		defer Memory().SetSynchronous(true)
+		msg := &testProducer{ID: 1, Name: "TEST PRODUCER"}
+
+		resp, err := NewTestProducer[testProducer](
+			func() error { return NewProducer("MEMORY_TEST_PRODUCER").Publish(context.Background(), "TEST", msg) },
+			"MEMORY_TEST_PRODUCER",
+			1,
+		).Execute()
+
+		assert.NoError(t, err)
+		assert.Equal(t, msg, resp)
+	})
+}
+
+// useMemoryMessaging initializes messaging with the in-memory broker and restores the previous state on cleanup.
+func useMemoryMessaging(t *testing.T) {
+	previousInstance := instance
+
+	instance = nil
+	test.InitializeMemoryMessaging()
+	Initialize()
+
+	t.Cleanup(func() {
+		instance = previousInstance
+		_ = os.Unsetenv(config.ENV_COLIBRI_MESSAGING)
+		config.COLIBRI_MESSAGING = config.MESSAGING_CLOUD_DEFAULT
+	})
+}
@@ -2,6 +2,8 @@ package messaging
 
 import (
 	"context"
+	"errors"
+	"time"
 
 	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
 	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
@@ -33,6 +35,20 @@ type messaging interface {
 	consumer(ctx context.Context, c *consumer) (chan *ProviderMessage, error)
 }
 
+// delayedMessaging is implemented by brokers that delay published messages natively.
+// producerWithDelay returns errDelayNotSupported when the broker can not delay the message, so it is scheduled instead.
+type delayedMessaging interface {
+	producerWithDelay(ctx context.Context, p *Producer, msg *ProviderMessage, delay time.Duration) error
+}
+
+// batchMessaging is implemented by brokers that publish many messages per request.
+// producerBatch returns one error per message, in the same order, which is nil for published messages.
+type batchMessaging interface {
+	producerBatch(ctx context.Context, p *Producer, msgs []*ProviderMessage) []error
+}
+
+var errDelayNotSupported = errors.New("message delay not supported by the broker")
+
 var instance messaging
 
 type messagingObserver struct {
@@ -56,9 +72,16 @@ func Initialize() {
 		return
 	}
 
-	if config.COLIBRI_MESSAGING == config.MESSAGING_RABBITMQ {
+	switch config.COLIBRI_MESSAGING {
+	case config.MESSAGING_RABBITMQ:
 		instance = newRabbitMQMessaging()
-	} else {
+	case config.MESSAGING_KAFKA:
+		instance = newKafkaMessaging()
+	case config.MESSAGING_NATS:
+		instance = newNatsMessaging()
+	case config.MESSAGING_MEMORY:
+		instance = newMemoryMessaging()
+	default:
 		switch config.CLOUD {
 		case config.CLOUD_AWS:
 			instance = newAwsMessaging()
@@ -29,6 +29,7 @@ const (
 type queueConsumerTest struct {
 	fn    func(ctx context.Context, n *ProviderMessage) error
 	qName string
+	tName string
 }
 
 func (q *queueConsumerTest) Consume(ctx context.Context, pm *ProviderMessage) error {
@@ -39,6 +40,13 @@ func (q *queueConsumerTest) QueueName() string {
 	return q.qName
 }
 
+func (q *queueConsumerTest) TopicName() string {
+	if q.tName == "" {
+		return q.qName
+	}
+	return q.tName
+}
+
 func TestMessaging(t *testing.T) {
 	t.Run("TestMessaging_GCP", func(t *testing.T) {
 		test.InitializeGcpEmulator()
@@ -72,6 +80,32 @@ func TestMessaging(t *testing.T) {
 			logging.Info(context.Background()).Msg("Cleaning up RabbitMQ container")
 		})
 	})
+
+	t.Run("TestMessaging_Kafka", func(t *testing.T) {
+		test.InitializeKafka()
+		Initialize()
+		executeMessagingTest(t)
+		t.Cleanup(func() {
+			instance = nil
+			_ = os.Unsetenv("KAFKA_BROKERS")
+			_ = os.Unsetenv("COLIBRI_MESSAGING")
+			config.COLIBRI_MESSAGING = config.MESSAGING_CLOUD_DEFAULT
+			logging.Info(context.Background()).Msg("Cleaning up Kafka container")
+		})
+	})
+
+	t.Run("TestMessaging_NATS", func(t *testing.T) {
+		test.InitializeNats()
+		Initialize()
+		executeMessagingTest(t)
+		t.Cleanup(func() {
+			instance = nil
+			_ = os.Unsetenv("NATS_URL")
+			_ = os.Unsetenv("COLIBRI_MESSAGING")
+			config.COLIBRI_MESSAGING = config.MESSAGING_CLOUD_DEFAULT
+			logging.Info(context.Background()).Msg("Cleaning up NATS container")
+		})
+	})
 }
 
 func executeMessagingTest(t *testing.T) {
@@ -87,11 +121,12 @@ func executeMessagingTest(t *testing.T) {
 				return nil
 			},
 			qName: testQueueName,
+			tName: testTopicName,
 		}
 
 		producer := NewProducer(testTopicName)
 
-		NewConsumer(&qc)
+		newConsumerTest(t, &qc)
 
 		model := userMessageTest{"User Name", "user@email.com"}
 		if err := producer.Publish(context.Background(), "create", model); err != nil {
@@ -119,11 +154,12 @@ func executeMessagingTest(t *testing.T) {
 				return err
 			},
 			qName: testFailQueueName,
+			tName: testFailTopicName,
 		}
 
 		producer := NewProducer(testFailTopicName)
 
-		NewConsumer(&qc)
+		newConsumerTest(t, &qc)
 
 		model := userMessageTest{"User Name", "user@email.com"}
 		if err := producer.Publish(context.Background(), "create", model); err != nil {
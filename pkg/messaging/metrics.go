@@ -0,0 +1,134 @@
+package messaging
+
+import (
+	"context"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const (
+	// queueDepthInterval is how often the depth of the consumed queues is read from the broker
+	queueDepthInterval = 30 * time.Second
+
+	couldNotReadQueueDepth = "could not read depth of queue %s"
+)
+
+var (
+	messagesConsumed = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "colibri",
+		Subsystem: "messaging",
+		Name:      "messages_consumed_total",
+		Help:      "Number of messages handed to the consumers.",
+	}, []string{"queue", "action"})
+
+	messagesAcked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "colibri",
+		Subsystem: "messaging",
+		Name:      "messages_acked_total",
+		Help:      "Number of messages processed and acknowledged.",
+	}, []string{"queue", "action"})
+
+	messagesNacked = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "colibri",
+		Subsystem: "messaging",
+		Name:      "messages_nacked_total",
+		Help:      "Number of messages whose processing failed and that are delivered again.",
+	}, []string{"queue", "action"})
+
+	messagesDeadLettered = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "colibri",
+		Subsystem: "messaging",
+		Name:      "messages_dead_lettered_total",
+		Help:      "Number of messages whose processing failed and that are sent to the dead-letter queue.",
+	}, []string{"queue", "action"})
+
+	messageProcessingDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "colibri",
+		Subsystem: "messaging",
+		Name:      "message_processing_duration_seconds",
+		Help:      "Latency of the consumers processing a message.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"queue", "action"})
+
+	messagesPublished = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "colibri",
+		Subsystem: "messaging",
+		Name:      "messages_published_total",
+		Help:      "Number of messages published.",
+	}, []string{"topic"})
+
+	publishErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: "colibri",
+		Subsystem: "messaging",
+		Name:      "publish_errors_total",
+		Help:      "Number of messages that could not be published.",
+	}, []string{"topic"})
+
+	publishDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "colibri",
+		Subsystem: "messaging",
+		Name:      "publish_duration_seconds",
+		Help:      "Latency of publishing a message, or a batch of messages, to the broker.",
+		Buckets:   []float64{.001, .0025, .005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"topic"})
+
+	queueDepth = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: "colibri",
+		Subsystem: "messaging",
+		Name:      "queue_depth",
+		Help:      "Approximate number of messages waiting in the consumed queues, for brokers exposing it.",
+	}, []string{"queue"})
+)
+
+// depthMessaging is implemented by brokers that read the number of messages waiting in a queue cheaply
+type depthMessaging interface {
+	queueDepth(ctx context.Context, queue string) (int64, error)
+}
+
+// observePublish records the latency and outcome of publishing count messages to the topic
+func observePublish(topic string, start time.Time, count int, errs ...error) {
+	publishDuration.WithLabelValues(topic).Observe(time.Since(start).Seconds())
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+		}
+	}
+	messagesPublished.WithLabelValues(topic).Add(float64(count - failed))
+	publishErrors.WithLabelValues(topic).Add(float64(failed))
+}
+
+// watchQueueDepth updates the depth gauge of the consumer queue until the consumer stops
+func watchQueueDepth(broker depthMessaging, c *consumer) {
+	ticker := time.NewTicker(queueDepthInterval)
+	defer ticker.Stop()
+	defer queueDepth.DeleteLabelValues(c.queue)
+
+	for {
+		updateQueueDepth(broker, c.queue)
+
+		select {
+		case <-c.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// updateQueueDepth reads the depth of the queue from the broker, keeping the last value when it can not be read
+func updateQueueDepth(broker depthMessaging, queue string) {
+	ctx, cancel := context.WithTimeout(context.Background(), queueDepthInterval)
+	defer cancel()
+
+	depth, err := broker.queueDepth(ctx, queue)
+	if err != nil {
+		logging.Debug(ctx).Err(err).Msgf(couldNotReadQueueDepth, queue)
+		return
+	}
+
+	queueDepth.WithLabelValues(queue).Set(float64(depth))
+}
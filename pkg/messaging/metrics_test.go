@@ -0,0 +1,70 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMessagingMetrics(t *testing.T) {
+	useMemoryMessaging(t)
+
+	t.Run("Should count consumed, acked, nacked and dead-lettered messages by queue and action", func(t *testing.T) {
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				var user userMessageTest
+				_ = message.DecodeMessage(&user)
+				if user.Name == "Invalid" {
+					return errors.New("invalid user")
+				}
+				return nil
+			},
+			qName: "METRICS_QUEUE",
+		})
+		producer := NewProducer("METRICS_QUEUE")
+
+		assert.NoError(t, producer.Publish(context.Background(), "create", userMessageTest{Name: "User"}))
+		assert.NoError(t, producer.Publish(context.Background(), "create", userMessageTest{Name: "Invalid"}))
+
+		attempts := float64(DefaultRetryPolicy.maxAttempts())
+		assert.Equal(t, 1+attempts, testutil.ToFloat64(messagesConsumed.WithLabelValues("METRICS_QUEUE", "create")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(messagesAcked.WithLabelValues("METRICS_QUEUE", "create")))
+		assert.Equal(t, attempts-1, testutil.ToFloat64(messagesNacked.WithLabelValues("METRICS_QUEUE", "create")))
+		assert.Equal(t, float64(1), testutil.ToFloat64(messagesDeadLettered.WithLabelValues("METRICS_QUEUE", "create")))
+		assert.GreaterOrEqual(t, testutil.CollectAndCount(messageProcessingDuration), 1)
+	})
+
+	t.Run("Should count published messages and publish errors by topic", func(t *testing.T) {
+		previousInstance := instance
+		instance = &failingMessagingTest{MemoryBroker: Memory(), name: "Invalid"}
+		defer func() { instance = previousInstance }()
+
+		_, err := NewProducer("METRICS_PUBLISH_TOPIC").PublishBatch(context.Background(), "create",
+			[]any{userMessageTest{Name: "User"}, userMessageTest{Name: "Invalid"}})
+		publishErr := NewProducer("METRICS_PUBLISH_TOPIC").Publish(context.Background(), "create", userMessageTest{Name: "Invalid"})
+
+		assert.Error(t, err)
+		assert.Error(t, publishErr)
+		assert.Equal(t, float64(1), testutil.ToFloat64(messagesPublished.WithLabelValues("METRICS_PUBLISH_TOPIC")))
+		assert.Equal(t, float64(2), testutil.ToFloat64(publishErrors.WithLabelValues("METRICS_PUBLISH_TOPIC")))
+	})
+
+	t.Run("Should set the depth of a queue with messages waiting", func(t *testing.T) {
+		consumer := newConsumerTest(t, &queueConsumerTest{
+			fn:    func(ctx context.Context, message *ProviderMessage) error { return nil },
+			qName: "METRICS_DEPTH_QUEUE",
+		})
+		consumer.Pause()
+		defer consumer.Resume()
+		for range 2 {
+			assert.NoError(t, NewProducer("METRICS_DEPTH_QUEUE").Publish(context.Background(), "create", userMessageTest{Name: "User"}))
+		}
+
+		updateQueueDepth(Memory(), "METRICS_DEPTH_QUEUE")
+
+		assert.Equal(t, float64(2), testutil.ToFloat64(queueDepth.WithLabelValues("METRICS_DEPTH_QUEUE")))
+	})
+}
@@ -0,0 +1,419 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/nats-io/nats.go"
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const (
+	natsDefaultURL        = nats.DefaultURL
+	natsURLEnvVar         = "NATS_URL"
+	natsMaxDeliverEnvVar  = "NATS_MAX_DELIVER"
+	natsAckWaitEnvVar     = "NATS_ACK_WAIT_SECONDS"
+	natsDefaultMaxDeliver = 5
+	natsDefaultAckWait    = 30 * time.Second
+	natsDLQSuffix         = "_DLQ"
+	natsAdvisoriesSuffix  = "_ADVISORIES"
+	natsFetchErrorBackoff = time.Second
+	natsProvisionTimeout  = 10 * time.Second
+
+	natsHeaderCorrelationID = "Colibri-Correlation-Id"
+	natsHeaderAction        = "Colibri-Action"
+	natsHeaderOrigin        = "Colibri-Origin"
+
+	// natsMaxDeliveriesAdvisory is the subject of the advisories JetStream publishes for the messages of a consumer
+	// that reached its max deliver, by stream and consumer name
+	natsMaxDeliveriesAdvisory = "$JS.EVENT.ADVISORY.CONSUMER.MAX_DELIVERIES.%s.%s"
+
+	couldNotProvisionStream = "could not provision stream %s"
+)
+
+// errNatsMaxDeliveries is the failure reason of messages redelivered more times than the consumer allows,
+// usually because the consumer stopped or timed out before acknowledging them
+var errNatsMaxDeliveries = errors.New("message reached the maximum number of deliveries")
+
+// natsMaxDeliveries is the part of a max deliveries advisory used to find the message in its stream
+type natsMaxDeliveries struct {
+	StreamSeq  uint64 `json:"stream_seq"`
+	Deliveries uint64 `json:"deliveries"`
+}
+
+type natsMessaging struct {
+	conn       *nats.Conn
+	js         jetstream.JetStream
+	maxDeliver int
+	ackWait    time.Duration
+	streams    sync.Map
+}
+
+type natsOriginalMessage struct {
+	m   *natsMessaging
+	msg jetstream.Msg
+	dlq string
+}
+
+// Ack acknowledges the message.
+func (n natsOriginalMessage) Ack() error {
+	return n.msg.Ack()
+}
+
+// Nack redelivers the message when requeue is true, or copies it to the DLQ stream and terminates it otherwise.
+// When the copy fails the message is redelivered, so it is never terminated without reaching the DLQ stream.
+func (n natsOriginalMessage) Nack(requeue bool, err error) error {
+	if requeue {
+		return n.msg.Nak()
+	}
+
+	var deliveries uint64
+	if metadata, metadataErr := n.msg.Metadata(); metadataErr == nil {
+		deliveries = metadata.NumDelivered
+	}
+
+	if dlqErr := n.m.deadLetter(context.Background(), n.msg.Headers(), n.msg.Data(), deliveries, n.dlq, err); dlqErr != nil {
+		_ = n.msg.Nak()
+		return dlqErr
+	}
+
+	return n.msg.TermWithReason(errorReason(err))
+}
+
+// NackWithDelay asks JetStream to redeliver the message after the delay.
+func (n natsOriginalMessage) NackWithDelay(delay time.Duration, _ error) error {
+	return n.msg.NakWithDelay(delay)
+}
+
+func newNatsMessaging() *natsMessaging {
+	url := os.Getenv(natsURLEnvVar)
+	if url == "" {
+		url = natsDefaultURL
+	}
+
+	conn, err := nats.Connect(url)
+	if err != nil {
+		logging.Fatal(context.Background()).Err(err).Msg(connectionError)
+	}
+
+	js, err := jetstream.New(conn)
+	if err != nil {
+		logging.Fatal(context.Background()).Err(err).Msg(connectionError)
+	}
+
+	config, err := loadNatsConfigFile()
+	if err != nil {
+		logging.Fatal(context.Background()).Err(err).Msg(connectionError)
+	}
+	if config != nil {
+		natsConfigs.Lock()
+		natsConfigs.list = append([]NatsConfig{*config}, natsConfigs.list...)
+		natsConfigs.Unlock()
+	}
+
+	return &natsMessaging{
+		conn:       conn,
+		js:         js,
+		maxDeliver: getNatsIntEnv(natsMaxDeliverEnvVar, natsDefaultMaxDeliver),
+		ackWait:    time.Duration(getNatsIntEnv(natsAckWaitEnvVar, int(natsDefaultAckWait.Seconds()))) * time.Second,
+	}
+}
+
+func (m *natsMessaging) producer(ctx context.Context, p *Producer, msg *ProviderMessage) error {
+	if _, err := m.provisionStream(ctx, newNatsStreamConfig(p.topic)); err != nil {
+		return err
+	}
+
+	body, attributes, err := encodeMessage(msg, cloudEventsAttributePrefix)
+	if err != nil {
+		return err
+	}
+
+	header := nats.Header{}
+	header.Set(natsHeaderCorrelationID, msg.CorrelationID)
+	header.Set(natsHeaderAction, msg.Action)
+	header.Set(natsHeaderOrigin, msg.Origin)
+	for key, value := range attributes {
+		header.Set(key, value)
+	}
+
+	_, err = m.js.PublishMsg(ctx, &nats.Msg{
+		Subject: p.topic,
+		Header:  header,
+		Data:    body,
+	}, jetstream.WithMsgID(msg.ID.String()))
+
+	return err
+}
+
+func (m *natsMessaging) consumer(ctx context.Context, c *consumer) (chan *ProviderMessage, error) {
+	provisionCtx, cancel := context.WithTimeout(ctx, natsProvisionTimeout)
+	defer cancel()
+
+	stream, err := m.provisionStream(provisionCtx, newNatsStreamConfig(c.topic))
+	if err != nil {
+		return nil, err
+	}
+
+	dlq := c.queue + natsDLQSuffix
+	if _, err = m.provisionStream(provisionCtx, newNatsStreamConfig(dlq)); err != nil {
+		return nil, err
+	}
+
+	settings := getNatsConsumer(c.queue)
+	maxDeliver := settings.MaxDeliver
+	if maxDeliver <= 0 {
+		maxDeliver = max(m.maxDeliver, c.options.Retry.maxAttempts())
+	}
+	ackWait := time.Duration(settings.AckWaitSeconds) * time.Second
+	if ackWait <= 0 {
+		ackWait = m.ackWait
+	}
+
+	durable := getNatsName(c.queue)
+	cons, err := m.provisionConsumer(provisionCtx, stream, jetstream.ConsumerConfig{
+		Durable:       durable,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       ackWait,
+		MaxDeliver:    maxDeliver,
+		FilterSubject: c.topic,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	advisories, err := m.consumeMaxDeliveries(ctx, provisionCtx, c, stream, durable, dlq)
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := cons.Messages(jetstream.PullMaxMessages(c.options.Prefetch))
+	if err != nil {
+		advisories.Stop()
+		return nil, err
+	}
+
+	ch := make(chan *ProviderMessage, c.options.Prefetch)
+	go func() {
+		<-c.done
+		iter.Stop()
+		advisories.Stop()
+	}()
+
+	c.Add(1)
+	go m.processMessages(ctx, c, iter, dlq, ch)
+
+	return ch, nil
+}
+
+// processMessages pulls messages from the durable consumer until it is stopped
+func (m *natsMessaging) processMessages(ctx context.Context, c *consumer, iter jetstream.MessagesContext, dlq string, ch chan<- *ProviderMessage) {
+	defer c.Done()
+
+	for {
+		msg, err := iter.Next()
+		if err != nil {
+			if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+				return
+			}
+
+			logging.Error(ctx).Err(err).Msgf(couldNotReceiveMsg, c.queue)
+			time.Sleep(natsFetchErrorBackoff)
+			continue
+		}
+
+		original := natsOriginalMessage{m: m, msg: msg, dlq: dlq}
+		attributes := getNatsAttributes(msg.Headers())
+		pm, err := decodeMessage(msg.Data(), attributes, cloudEventsAttributePrefix)
+		if err != nil {
+			logging.Error(ctx).Err(err).Msgf(couldNotReadMsgBody, msg.Headers().Get(nats.MsgIdHdr), c.queue)
+			if err = original.Nack(false, err); err != nil {
+				logging.Error(ctx).Err(err).Msgf(couldNotSendToDLQ, msg.Headers().Get(nats.MsgIdHdr))
+			}
+			continue
+		}
+
+		if pm.CorrelationID == "" {
+			pm.CorrelationID = msg.Headers().Get(natsHeaderCorrelationID)
+		}
+
+		pm.traceContext = attributes
+		if metadata, err := msg.Metadata(); err == nil {
+			pm.attempt = int(metadata.NumDelivered)
+		}
+
+		pm.addOriginBrokerNotification(original)
+		if !c.deliver(ch, pm) {
+			_ = msg.Nak()
+			return
+		}
+	}
+}
+
+// consumeMaxDeliveries moves the messages that reached the max deliver of the durable consumer to the DLQ stream.
+// JetStream keeps these messages in their stream and only publishes an advisory about them, so the advisories are
+// captured by a work queue stream and moved by a durable consumer shared by every instance of the queue, which keeps
+// them until their message reaches the DLQ stream, even across restarts.
+func (m *natsMessaging) consumeMaxDeliveries(ctx, provisionCtx context.Context, c *consumer, stream, durable, dlq string) (jetstream.MessagesContext, error) {
+	source, err := m.js.Stream(provisionCtx, stream)
+	if err != nil {
+		return nil, err
+	}
+
+	subject := fmt.Sprintf(natsMaxDeliveriesAdvisory, stream, durable)
+	advisoryStream, err := m.provisionStream(provisionCtx, jetstream.StreamConfig{
+		Name:      getNatsName(stream + "_" + durable + natsAdvisoriesSuffix),
+		Subjects:  []string{subject},
+		Retention: jetstream.WorkQueuePolicy,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	cons, err := m.provisionConsumer(provisionCtx, advisoryStream, jetstream.ConsumerConfig{
+		Durable:       durable,
+		AckPolicy:     jetstream.AckExplicitPolicy,
+		AckWait:       m.ackWait,
+		FilterSubject: subject,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	iter, err := cons.Messages()
+	if err != nil {
+		return nil, err
+	}
+
+	c.Add(1)
+	go m.moveMaxDeliveries(ctx, c, iter, source, dlq)
+
+	return iter, nil
+}
+
+// moveMaxDeliveries copies the message of each advisory to the DLQ stream until the consumer is stopped.
+// Advisories whose message could not be copied are redelivered.
+func (m *natsMessaging) moveMaxDeliveries(ctx context.Context, c *consumer, iter jetstream.MessagesContext, source jetstream.Stream, dlq string) {
+	defer c.Done()
+
+	for {
+		advisory, err := iter.Next()
+		if err != nil {
+			if errors.Is(err, jetstream.ErrMsgIteratorClosed) {
+				return
+			}
+
+			logging.Error(ctx).Err(err).Msgf(couldNotReceiveMsg, c.queue)
+			time.Sleep(natsFetchErrorBackoff)
+			continue
+		}
+
+		var event natsMaxDeliveries
+		if err = json.Unmarshal(advisory.Data(), &event); err != nil {
+			logging.Error(ctx).Err(err).Msgf(couldNotReadMsgBody, advisory.Headers().Get(nats.MsgIdHdr), c.queue)
+			_ = advisory.Term()
+			continue
+		}
+
+		msg, err := source.GetMsg(ctx, event.StreamSeq)
+		if errors.Is(err, jetstream.ErrMsgNotFound) {
+			// the stream limits removed the message before it could be moved
+			_ = advisory.Ack()
+			continue
+		}
+		if err == nil {
+			err = m.deadLetter(ctx, msg.Header, msg.Data, event.Deliveries, dlq, errNatsMaxDeliveries)
+		}
+		if err != nil {
+			logging.Error(ctx).Err(err).Msgf(couldNotSendToDLQ, strconv.FormatUint(event.StreamSeq, 10))
+			_ = advisory.NakWithDelay(natsFetchErrorBackoff)
+			continue
+		}
+
+		_ = advisory.Ack()
+	}
+}
+
+// deadLetter copies the message to the DLQ stream with the failure reason and the number of deliveries
+func (m *natsMessaging) deadLetter(ctx context.Context, msgHeader nats.Header, data []byte, deliveries uint64, dlq string, reason error) error {
+	header := nats.Header{}
+	for key, values := range msgHeader {
+		header[key] = values
+	}
+	header.Set(headerFailureReason, errorReason(reason))
+	if deliveries > 0 {
+		header.Set(headerAttempts, strconv.FormatUint(deliveries, 10))
+	}
+
+	if _, err := m.js.PublishMsg(ctx, &nats.Msg{Subject: dlq, Header: header, Data: data}); err != nil {
+		return err
+	}
+
+	logging.Debug(ctx).Msgf(messageSentToDLQ, header.Get(nats.MsgIdHdr), dlq, errorReason(reason))
+	return nil
+}
+
+// provisionStream returns the name of the stream storing the subject of the config, once per process.
+// An existing stream storing the subject is used as it is. Otherwise, the declared stream storing the subject is
+// created, or the given one when there is none. A stream created meanwhile with the same name is used as it is.
+func (m *natsMessaging) provisionStream(ctx context.Context, config jetstream.StreamConfig) (string, error) {
+	subject := config.Subjects[0]
+	if name, ok := m.streams.Load(subject); ok {
+		return name.(string), nil
+	}
+
+	name, err := m.js.StreamNameBySubject(ctx, subject)
+	if errors.Is(err, jetstream.ErrStreamNotFound) {
+		config = getNatsStreamConfig(subject, config)
+		name = config.Name
+		if _, err = m.js.CreateStream(ctx, config); errors.Is(err, jetstream.ErrStreamNameAlreadyInUse) {
+			err = nil
+		}
+	}
+	if err != nil {
+		logging.Error(ctx).Err(err).Msgf(couldNotProvisionStream, subject)
+		return "", err
+	}
+
+	m.streams.Store(subject, name)
+	return name, nil
+}
+
+// provisionConsumer creates the durable consumer on the stream, or returns the existing one without updating it
+func (m *natsMessaging) provisionConsumer(ctx context.Context, stream string, config jetstream.ConsumerConfig) (jetstream.Consumer, error) {
+	cons, err := m.js.CreateConsumer(ctx, stream, config)
+	if errors.Is(err, jetstream.ErrConsumerExists) {
+		return m.js.Consumer(ctx, stream, config.Durable)
+	}
+
+	return cons, err
+}
+
+// getNatsIntEnv returns the integer value of an environment variable or the fallback when it is not set or invalid
+func getNatsIntEnv(name string, fallback int) int {
+	value, err := strconv.Atoi(os.Getenv(name))
+	if err != nil || value <= 0 {
+		return fallback
+	}
+
+	return value
+}
+
+// getNatsAttributes returns the message headers as message attributes.
+// NATS canonicalizes header keys, so they are lower-cased back to the W3C and CloudEvents names.
+func getNatsAttributes(header nats.Header) map[string]string {
+	carrier := make(map[string]string, len(header))
+	for key := range header {
+		carrier[strings.ToLower(key)] = header.Get(key)
+	}
+
+	return carrier
+}
@@ -0,0 +1,162 @@
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"unicode"
+
+	"github.com/nats-io/nats.go/jetstream"
+)
+
+const natsConfigFileEnvVar = "NATS_CONFIG_FILE"
+
+// NatsConfig is a set of JetStream streams and durable consumers provisioned by the NATS provider. It is declared
+// from code with DeclareNatsConfig, or from a JSON file whose path is set in the NATS_CONFIG_FILE environment variable.
+// Streams and consumers are only created when missing: existing ones are used as they are and never updated, so the
+// settings applied by operators are kept. Subjects without a configured or existing stream get a stream of their own.
+type NatsConfig struct {
+	Streams   []NatsStream   `json:"streams"`
+	Consumers []NatsConsumer `json:"consumers"`
+}
+
+// NatsStream is a stream of a NatsConfig
+type NatsStream struct {
+	// Name is the stream name. Characters not allowed in stream names, such as dots and wildcards, are replaced by _.
+	Name string `json:"name"`
+	// Subjects are the subjects stored by the stream, which may use the * and > wildcards. The default is the name.
+	Subjects []string `json:"subjects,omitempty"`
+	// Retention is limits, interest or workqueue. The default is limits.
+	Retention jetstream.RetentionPolicy `json:"retention,omitempty"`
+	// Storage is file or memory. The default is file.
+	Storage  jetstream.StorageType `json:"storage,omitempty"`
+	Replicas int                   `json:"replicas,omitempty"`
+}
+
+// NatsConsumer is the durable consumer of a queue in a NatsConfig
+type NatsConsumer struct {
+	// Queue is the queue name of the consumer, which the durable consumer is named after
+	Queue string `json:"queue"`
+	// MaxDeliver is how many times a message is delivered before it is moved to the DLQ stream. The default is the
+	// greater of NATS_MAX_DELIVER and the attempts of the consumer retry policy.
+	MaxDeliver int `json:"maxDeliver,omitempty"`
+	// AckWaitSeconds is how long a delivered message waits for its acknowledgement before being redelivered.
+	// The default is NATS_ACK_WAIT_SECONDS.
+	AckWaitSeconds int `json:"ackWaitSeconds,omitempty"`
+}
+
+var natsConfigs struct {
+	sync.Mutex
+	list []NatsConfig
+}
+
+// DeclareNatsConfig declares streams and consumers to provision on NATS. They are provisioned when the producers and
+// consumers of their subjects and queues are first used, so it should be called before them.
+func DeclareNatsConfig(config NatsConfig) {
+	natsConfigs.Lock()
+	defer natsConfigs.Unlock()
+
+	natsConfigs.list = append(natsConfigs.list, config)
+}
+
+// getNatsConfigs returns the declared configs
+func getNatsConfigs() []NatsConfig {
+	natsConfigs.Lock()
+	defer natsConfigs.Unlock()
+
+	return append([]NatsConfig(nil), natsConfigs.list...)
+}
+
+// loadNatsConfigFile reads the config of the NATS_CONFIG_FILE file, or returns nil when it is not set
+func loadNatsConfigFile() (*NatsConfig, error) {
+	path := os.Getenv(natsConfigFileEnvVar)
+	if path == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var config NatsConfig
+	if err = json.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("invalid NATS config file %s: %w", path, err)
+	}
+
+	return &config, nil
+}
+
+// getNatsStreamConfig returns the config of the first declared stream storing the subject, or the fallback
+func getNatsStreamConfig(subject string, fallback jetstream.StreamConfig) jetstream.StreamConfig {
+	for _, config := range getNatsConfigs() {
+		for _, stream := range config.Streams {
+			subjects := stream.Subjects
+			if len(subjects) == 0 {
+				subjects = []string{stream.Name}
+			}
+
+			for _, pattern := range subjects {
+				if matchNatsSubject(pattern, subject) {
+					return jetstream.StreamConfig{
+						Name:      getNatsName(stream.Name),
+						Subjects:  subjects,
+						Retention: stream.Retention,
+						Storage:   stream.Storage,
+						Replicas:  stream.Replicas,
+					}
+				}
+			}
+		}
+	}
+
+	return fallback
+}
+
+// getNatsConsumer returns the declared consumer of the queue, or a consumer with the defaults when there is none
+func getNatsConsumer(queue string) NatsConsumer {
+	for _, config := range getNatsConfigs() {
+		for _, consumer := range config.Consumers {
+			if consumer.Queue == queue {
+				return consumer
+			}
+		}
+	}
+
+	return NatsConsumer{Queue: queue}
+}
+
+// newNatsStreamConfig returns the config of a stream storing only the subject, named after it
+func newNatsStreamConfig(subject string) jetstream.StreamConfig {
+	return jetstream.StreamConfig{Name: getNatsName(subject), Subjects: []string{subject}}
+}
+
+// getNatsName replaces the characters JetStream does not allow in stream and consumer names with _
+func getNatsName(name string) string {
+	return strings.Map(func(r rune) rune {
+		if r == '.' || r == '*' || r == '>' || r == '/' || r == '\\' || unicode.IsSpace(r) || !unicode.IsPrint(r) {
+			return '_'
+		}
+
+		return r
+	}, name)
+}
+
+// matchNatsSubject reports whether the subject matches the pattern, where * matches a token and > the remaining ones
+func matchNatsSubject(pattern, subject string) bool {
+	patternTokens := strings.Split(pattern, ".")
+	subjectTokens := strings.Split(subject, ".")
+
+	for i, token := range patternTokens {
+		if token == ">" {
+			return len(subjectTokens) > i
+		}
+		if i >= len(subjectTokens) || (token != "*" && token != subjectTokens[i]) {
+			return false
+		}
+	}
+
+	return len(patternTokens) == len(subjectTokens)
+}
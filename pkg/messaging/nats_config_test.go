@@ -0,0 +1,114 @@
+package messaging
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/nats-io/nats.go/jetstream"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNatsConfig(t *testing.T) {
+	declare := func(t *testing.T, config NatsConfig) {
+		DeclareNatsConfig(config)
+		t.Cleanup(func() {
+			natsConfigs.Lock()
+			natsConfigs.list = nil
+			natsConfigs.Unlock()
+		})
+	}
+
+	t.Run("Should load the config file", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nats.json")
+		content := `{
+			"streams": [{"name": "ORDERS", "subjects": ["orders.>"], "retention": "workqueue", "storage": "memory", "replicas": 3}],
+			"consumers": [{"queue": "ORDERS_QUEUE", "maxDeliver": 10, "ackWaitSeconds": 60}]
+		}`
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		t.Setenv(natsConfigFileEnvVar, path)
+
+		config, err := loadNatsConfigFile()
+
+		assert.NoError(t, err)
+		assert.Equal(t, &NatsConfig{
+			Streams: []NatsStream{{
+				Name:      "ORDERS",
+				Subjects:  []string{"orders.>"},
+				Retention: jetstream.WorkQueuePolicy,
+				Storage:   jetstream.MemoryStorage,
+				Replicas:  3,
+			}},
+			Consumers: []NatsConsumer{{Queue: "ORDERS_QUEUE", MaxDeliver: 10, AckWaitSeconds: 60}},
+		}, config)
+	})
+
+	t.Run("Should not load a config when the file is not set", func(t *testing.T) {
+		t.Setenv(natsConfigFileEnvVar, "")
+
+		config, err := loadNatsConfigFile()
+
+		assert.NoError(t, err)
+		assert.Nil(t, config)
+	})
+
+	t.Run("Should return error when the config file is invalid", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "nats.json")
+		assert.NoError(t, os.WriteFile(path, []byte(`{"streams": [{"name": "ORDERS", "retention": "forever"}]}`), 0o600))
+		t.Setenv(natsConfigFileEnvVar, path)
+
+		config, err := loadNatsConfigFile()
+
+		assert.Error(t, err)
+		assert.Nil(t, config)
+	})
+
+	t.Run("Should return the declared stream storing the subject", func(t *testing.T) {
+		declare(t, NatsConfig{Streams: []NatsStream{
+			{Name: "PAYMENTS", Subjects: []string{"payments.*"}},
+			{Name: "ORDERS", Subjects: []string{"orders.>"}, Retention: jetstream.InterestPolicy, Replicas: 3},
+		}})
+
+		config := getNatsStreamConfig("orders.eu.created", newNatsStreamConfig("orders.eu.created"))
+
+		assert.Equal(t, jetstream.StreamConfig{
+			Name:      "ORDERS",
+			Subjects:  []string{"orders.>"},
+			Retention: jetstream.InterestPolicy,
+			Replicas:  3,
+		}, config)
+	})
+
+	t.Run("Should return a stream named after the subject when none is declared for it", func(t *testing.T) {
+		declare(t, NatsConfig{Streams: []NatsStream{{Name: "PAYMENTS", Subjects: []string{"payments.*"}}}})
+
+		config := getNatsStreamConfig("payments.eu.created", newNatsStreamConfig("payments.eu.created"))
+
+		assert.Equal(t, jetstream.StreamConfig{Name: "payments_eu_created", Subjects: []string{"payments.eu.created"}}, config)
+	})
+
+	t.Run("Should return the declared consumer of the queue", func(t *testing.T) {
+		declare(t, NatsConfig{Consumers: []NatsConsumer{{Queue: "ORDERS_QUEUE", MaxDeliver: 10}}})
+
+		assert.Equal(t, NatsConsumer{Queue: "ORDERS_QUEUE", MaxDeliver: 10}, getNatsConsumer("ORDERS_QUEUE"))
+		assert.Equal(t, NatsConsumer{Queue: "PAYMENTS_QUEUE"}, getNatsConsumer("PAYMENTS_QUEUE"))
+	})
+
+	t.Run("Should replace the characters not allowed in names", func(t *testing.T) {
+		assert.Equal(t, "orders_created", getNatsName("orders.created"))
+		assert.Equal(t, "orders____", getNatsName("orders.*.>"))
+		assert.Equal(t, "my_queue_v1", getNatsName("my queue/v1"))
+		assert.Equal(t, "ORDERS_QUEUE", getNatsName("ORDERS_QUEUE"))
+	})
+
+	t.Run("Should match subjects with wildcards", func(t *testing.T) {
+		assert.True(t, matchNatsSubject("orders", "orders"))
+		assert.True(t, matchNatsSubject("orders.*", "orders.created"))
+		assert.True(t, matchNatsSubject("orders.>", "orders.eu.created"))
+		assert.True(t, matchNatsSubject("*.created", "orders.created"))
+		assert.False(t, matchNatsSubject("orders.*", "orders.eu.created"))
+		assert.False(t, matchNatsSubject("orders.>", "orders"))
+		assert.False(t, matchNatsSubject("orders", "orders.created"))
+		assert.False(t, matchNatsSubject("payments.*", "orders.created"))
+	})
+}
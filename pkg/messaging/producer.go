@@ -2,6 +2,9 @@ package messaging
 
 import (
 	"context"
+	"errors"
+	"strconv"
+	"time"
 
 	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
 	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
@@ -12,42 +15,265 @@ import (
 )
 
 type Producer struct {
-	topic string
+	topic        string
+	partitionKey func(ctx context.Context, action string, message any) string
+	encoding     Encoding
+	claimCheck   *claimCheck
+	encrypt      bool
+	sign         bool
 }
 
-func NewProducer(topicName string) *Producer {
-	return &Producer{topicName}
+// PublishResult is the outcome of publishing one message of a batch
+type PublishResult struct {
+	// ID is the id of the message
+	ID uuid.UUID
+	// Err is the error that prevented the message from being published, or nil when it was published
+	Err error
 }
 
-func (p *Producer) Publish(ctx context.Context, action string, message any) error {
+// ProducerOption configures optional behaviour of a Producer
+type ProducerOption func(p *Producer)
+
+// PublishOption configures a single published message
+type PublishOption func(msg *ProviderMessage)
+
+// WithEncoding sets how the producer writes messages to the broker. The default is EncodingColibri.
+// Consumers detect the encoding of each message, so they read every encoding.
+func WithEncoding(encoding Encoding) ProducerOption {
+	return func(p *Producer) {
+		p.encoding = encoding
+	}
+}
+
+// WithHeader sets a header of the published message
+func WithHeader(key, value string) PublishOption {
+	return func(msg *ProviderMessage) {
+		if msg.Headers == nil {
+			msg.Headers = make(map[string]string)
+		}
+		msg.Headers[key] = value
+	}
+}
+
+// WithHeaders sets headers of the published message
+func WithHeaders(headers map[string]string) PublishOption {
+	return func(msg *ProviderMessage) {
+		for key, value := range headers {
+			WithHeader(key, value)(msg)
+		}
+	}
+}
+
+// withKey sets the ordering key of the published message
+func withKey(key string) PublishOption {
+	return func(msg *ProviderMessage) {
+		msg.key = key
+	}
+}
+
+// WithPartitionKey sets a function that derives the partition key of each published message.
+// It is used as the ordering key of the messages published without PublishWithKey.
+func WithPartitionKey(fn func(ctx context.Context, action string, message any) string) ProducerOption {
+	return func(p *Producer) {
+		p.partitionKey = fn
+	}
+}
+
+func NewProducer(topicName string, opts ...ProducerOption) *Producer {
+	p := &Producer{topic: topicName, encoding: EncodingColibri}
+	for _, opt := range opts {
+		opt(p)
+	}
+
+	return p
+}
+
+// Publish sends the message to the producer topic
+func (p *Producer) Publish(ctx context.Context, action string, message any, opts ...PublishOption) error {
+	return p.publish(ctx, action, message, 0, opts...)
+}
+
+// PublishWithKey sends the message to the producer topic with an ordering key.
+// Messages with the same key are delivered in publish order and processed one at a time by the consumer workers.
+func (p *Producer) PublishWithKey(ctx context.Context, key, action string, message any, opts ...PublishOption) error {
+	return p.publish(ctx, action, message, 0, append(opts, withKey(key))...)
+}
+
+// PublishAt sends the message to the producer topic so it is delivered at the given time.
+// Brokers that support delays hold the message natively; longer delays and other brokers use the scheduler,
+// which must be started with InitializeScheduler. Past times publish the message right away.
+func (p *Producer) PublishAt(ctx context.Context, at time.Time, action string, message any, opts ...PublishOption) error {
+	return p.publish(ctx, action, message, time.Until(at), opts...)
+}
+
+// PublishAfter sends the message to the producer topic so it is delivered after the delay, like PublishAt
+func (p *Producer) PublishAfter(ctx context.Context, delay time.Duration, action string, message any, opts ...PublishOption) error {
+	return p.publish(ctx, action, message, delay, opts...)
+}
+
+// PublishBatch sends the messages to the producer topic with the same action, using the broker batch API when available.
+// It returns one result per message, in the same order, and an error joining the failures,
+// so the caller can retry only the messages that failed.
+func (p *Producer) PublishBatch(ctx context.Context, action string, messages []any, opts ...PublishOption) ([]PublishResult, error) {
 	if instance == nil {
 		logging.Fatal(context.Background()).Msg(messagingNotInitialized)
 	}
-	correlationID := ctx.Value(logging.CorrelationIDParam)
-	if correlationID == nil {
-		correlationID = uuid.New().String()
+	correlationID := getCorrelationID(ctx)
+
+	txn, txnCtx := p.startTransaction(ctx, action, correlationID)
+	monitoring.AddTransactionAttribute(txn, "batchSize", strconv.Itoa(len(messages)))
+	defer monitoring.EndTransaction(txn)
+
+	start := time.Now()
+	results := make([]PublishResult, len(messages))
+	msgs := make([]*ProviderMessage, 0, len(messages))
+	positions := make([]int, 0, len(messages))
+	for i, message := range messages {
+		msg := p.newMessage(ctx, txnCtx, action, message, correlationID, opts...)
+		results[i].ID = msg.ID
+		if results[i].Err = p.seal(ctx, msg); results[i].Err == nil {
+			msgs = append(msgs, msg)
+			positions = append(positions, i)
+		}
+	}
+
+	for i, err := range p.sendBatch(ctx, msgs) {
+		results[positions[i]].Err = err
+	}
+
+	failures := make([]error, 0)
+	defer func() { observePublish(p.topic, start, len(results), failures...) }()
+	for _, result := range results {
+		if result.Err != nil {
+			logging.Error(ctx).Err(result.Err).Msgf(couldNotSendMsg, result.ID, p.topic)
+			failures = append(failures, result.Err)
+		}
+	}
+
+	err := errors.Join(failures...)
+	if err != nil {
+		monitoring.NoticeError(txn, err)
 	}
 
-	txn, _ := monitoring.StartTransaction(ctx, messagingProducerTransaction, colibrimonitoringbase.SpanKindProducer)
+	return results, err
+}
+
+func (p *Producer) publish(ctx context.Context, action string, message any, delay time.Duration, opts ...PublishOption) error {
+	if instance == nil {
+		logging.Fatal(context.Background()).Msg(messagingNotInitialized)
+	}
+	correlationID := getCorrelationID(ctx)
+
+	txn, txnCtx := p.startTransaction(ctx, action, correlationID)
+	defer monitoring.EndTransaction(txn)
+
+	msg := p.newMessage(ctx, txnCtx, action, message, correlationID, opts...)
+	start := time.Now()
+	err := p.seal(ctx, msg)
+	if err == nil {
+		err = p.send(ctx, msg, delay)
+	}
+	observePublish(p.topic, start, 1, err)
+	if err != nil {
+		logging.Error(ctx).Err(err).Msgf(couldNotSendMsg, msg.ID, p.topic)
+		monitoring.NoticeError(txn, err)
+		return err
+	}
+
+	return nil
+}
+
+// startTransaction starts the producer transaction of a publish
+func (p *Producer) startTransaction(ctx context.Context, action, correlationID string) (any, context.Context) {
+	txn, txnCtx := monitoring.StartTransaction(ctx, messagingProducerTransaction, colibrimonitoringbase.SpanKindProducer)
 	monitoring.AddTransactionAttribute(txn, "topic", p.topic)
-	monitoring.AddTransactionAttribute(txn, "correlationId", correlationID.(string))
+	monitoring.AddTransactionAttribute(txn, "correlationId", correlationID)
 	monitoring.AddTransactionAttribute(txn, "action", action)
-	defer monitoring.EndTransaction(txn)
 
+	return txn, txnCtx
+}
+
+// newMessage returns the message to publish, carrying the trace context of the producer transaction
+func (p *Producer) newMessage(ctx, txnCtx context.Context, action string, message any, correlationID string, opts ...PublishOption) *ProviderMessage {
 	msg := &ProviderMessage{
 		ID:            uuid.New(),
 		Origin:        config.APP_NAME,
 		Action:        action,
 		Message:       message,
 		AuthContext:   security.GetAuthenticationContext(ctx),
-		CorrelationID: correlationID.(string),
+		CorrelationID: correlationID,
+		OccurredAt:    time.Now().UTC(),
+		traceContext:  make(map[string]string),
+		encoding:      p.encoding,
+	}
+	for _, opt := range opts {
+		opt(msg)
 	}
+	monitoring.InjectTraceContext(txnCtx, msg.traceContext)
 
-	if err := instance.producer(ctx, p, msg); err != nil {
-		logging.Error(ctx).Err(err).Msgf(couldNotSendMsg, msg.ID, p.topic)
-		monitoring.NoticeError(txn, err)
-		return err
+	if msg.key == "" && p.partitionKey != nil {
+		msg.key = p.partitionKey(ctx, action, message)
+	}
+
+	return msg
+}
+
+// seal encrypts the payload, replaces it with a claim-check reference when it is too large and signs the message,
+// as configured on the producer. Consumers undo it in the opposite order.
+func (p *Producer) seal(ctx context.Context, msg *ProviderMessage) error {
+	if p.encrypt {
+		if err := encryptMessage(ctx, msg); err != nil {
+			return err
+		}
+	}
+
+	if p.claimCheck != nil {
+		if err := p.claimCheck.offload(ctx, p.topic, msg); err != nil {
+			return err
+		}
+	}
+
+	if p.sign {
+		return signMessage(ctx, msg)
 	}
 
 	return nil
 }
+
+// sendBatch publishes the messages with the broker batch API, or one by one when the broker has none
+func (p *Producer) sendBatch(ctx context.Context, msgs []*ProviderMessage) []error {
+	if broker, ok := instance.(batchMessaging); ok {
+		return broker.producerBatch(ctx, p, msgs)
+	}
+
+	errs := make([]error, len(msgs))
+	for i, msg := range msgs {
+		errs[i] = instance.producer(ctx, p, msg)
+	}
+
+	return errs
+}
+
+// send publishes the message to the broker, delaying it natively when supported or scheduling it otherwise
+func (p *Producer) send(ctx context.Context, msg *ProviderMessage, delay time.Duration) error {
+	if delay <= 0 {
+		return instance.producer(ctx, p, msg)
+	}
+
+	if broker, ok := instance.(delayedMessaging); ok {
+		if err := broker.producerWithDelay(ctx, p, msg, delay); !errors.Is(err, errDelayNotSupported) {
+			return err
+		}
+	}
+
+	return scheduleMessage(ctx, p.topic, msg, time.Now().Add(delay))
+}
+
+// getCorrelationID returns the correlation id of the context, or a new one when it has none
+func getCorrelationID(ctx context.Context) string {
+	if correlationID, ok := ctx.Value(logging.CorrelationIDParam).(string); ok {
+		return correlationID
+	}
+
+	return uuid.New().String()
+}
@@ -0,0 +1,72 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+// failingMessagingTest is the in-memory broker failing to publish the messages of the users with the given name
+type failingMessagingTest struct {
+	*MemoryBroker
+	name string
+}
+
+func (m *failingMessagingTest) producer(ctx context.Context, p *Producer, msg *ProviderMessage) error {
+	if user, ok := msg.Message.(userMessageTest); ok && user.Name == m.name {
+		return errors.New("broker unavailable")
+	}
+
+	return m.MemoryBroker.producer(ctx, p, msg)
+}
+
+func TestProducerPublishBatch(t *testing.T) {
+	useMemoryMessaging(t)
+
+	t.Run("Should publish every message of the batch with one result per message", func(t *testing.T) {
+		Memory().Bind("PRODUCER_BATCH_TOPIC", "PRODUCER_BATCH_QUEUE")
+		received := make([]string, 0)
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				var user userMessageTest
+				_ = message.DecodeMessage(&user)
+				received = append(received, user.Name)
+				return nil
+			},
+			qName: "PRODUCER_BATCH_QUEUE",
+		})
+		ctx := context.WithValue(context.Background(), logging.CorrelationIDParam, "batch-correlation")
+
+		results, err := NewProducer("PRODUCER_BATCH_TOPIC").PublishBatch(ctx, "create",
+			[]any{userMessageTest{Name: "User 1"}, userMessageTest{Name: "User 2"}}, WithHeader("source", "import"))
+
+		published := Memory().Published("PRODUCER_BATCH_TOPIC")
+		assert.NoError(t, err)
+		assert.Len(t, results, 2)
+		assert.Equal(t, []string{"User 1", "User 2"}, received)
+		assert.Equal(t, published[0].ID, results[0].ID)
+		assert.Equal(t, published[1].ID, results[1].ID)
+		assert.NoError(t, results[0].Err)
+		assert.Equal(t, "batch-correlation", published[1].CorrelationID)
+		assert.Equal(t, "import", published[1].Header("source"))
+	})
+
+	t.Run("Should return the failures of the batch so only they are retried", func(t *testing.T) {
+		previousInstance := instance
+		instance = &failingMessagingTest{MemoryBroker: Memory(), name: "Invalid"}
+		defer func() { instance = previousInstance }()
+
+		results, err := NewProducer("PRODUCER_BATCH_FAIL_TOPIC").PublishBatch(context.Background(), "create",
+			[]any{userMessageTest{Name: "User"}, userMessageTest{Name: "Invalid"}, userMessageTest{Name: "User"}})
+
+		assert.EqualError(t, err, "broker unavailable")
+		assert.Len(t, results, 3)
+		assert.NoError(t, results[0].Err)
+		assert.EqualError(t, results[1].Err, "broker unavailable")
+		assert.NoError(t, results[2].Err)
+		assert.Len(t, previousInstance.(*MemoryBroker).Published("PRODUCER_BATCH_FAIL_TOPIC"), 2)
+	})
+}
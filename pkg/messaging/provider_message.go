@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"time"
 
 	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
 	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/security"
@@ -18,9 +19,27 @@ type ProviderMessage struct {
 	Message       any                             `json:"message"`
 	AuthContext   *security.AuthenticationContext `json:"authenticationContext"`
 	CorrelationID string                          `json:"correlationId,omitempty"`
+	Headers       map[string]string               `json:"headers,omitempty"`
+	OccurredAt    time.Time                       `json:"occurredAt,omitzero"`
+	key           string
+	attempt       int
+	traceContext  map[string]string
+	encoding      Encoding
+	claimCheck    *claimCheckReference
 	n             any
 }
 
+// delayedNacker is implemented by original messages whose broker can redeliver them after a delay
+type delayedNacker interface {
+	NackWithDelay(delay time.Duration, err error) error
+}
+
+// deferrer is implemented by original messages whose broker can redeliver them after a delay without counting
+// a delivery attempt, because the attempt count is kept by the messaging package rather than by the broker
+type deferrer interface {
+	Defer(delay time.Duration) error
+}
+
 // NewProviderMessage returns a new ProviderMessage
 func NewProviderMessage(ctx context.Context, action string, message any) *ProviderMessage {
 	return &ProviderMessage{
@@ -29,6 +48,7 @@ func NewProviderMessage(ctx context.Context, action string, message any) *Provid
 		Action:      action,
 		Message:     message,
 		AuthContext: security.GetAuthenticationContext(ctx),
+		OccurredAt:  time.Now().UTC(),
 	}
 }
 
@@ -63,6 +83,16 @@ func (msg *ProviderMessage) DecodeMessage(model any) error {
 	return nil
 }
 
+// Header returns the value of a message header, or an empty string when it is not set
+func (msg *ProviderMessage) Header(key string) string {
+	return msg.Headers[key]
+}
+
+// Key returns the ordering key the message was published with, or an empty string when it has none
+func (msg *ProviderMessage) Key() string {
+	return msg.key
+}
+
 // addOriginBrokerNotification add reference of an origin broker message to send dlq if an error occurs
 func (msg *ProviderMessage) addOriginBrokerNotification(n any) {
 	msg.n = n
@@ -85,3 +115,26 @@ func (msg *ProviderMessage) Nack(requeue bool, err error) error {
 	}
 	return nil
 }
+
+// Attempt returns how many times the message has been delivered, starting at 1.
+func (msg *ProviderMessage) Attempt() int {
+	return max(msg.attempt, 1)
+}
+
+// NackWithDelay rejects the message and asks the broker to deliver it again after the delay.
+// Brokers that can not delay a redelivery requeue the message immediately.
+func (msg *ProviderMessage) NackWithDelay(delay time.Duration, err error) error {
+	if originalMessage, ok := msg.n.(delayedNacker); ok {
+		return originalMessage.NackWithDelay(delay, err)
+	}
+	return msg.Nack(true, err)
+}
+
+// deferDelivery asks the broker to deliver the message again after the delay, keeping its attempt count when the
+// broker allows it. SQS, Pub/Sub and NATS count the deliveries themselves, so a deferred delivery counts there.
+func (msg *ProviderMessage) deferDelivery(delay time.Duration, err error) error {
+	if originalMessage, ok := msg.n.(deferrer); ok {
+		return originalMessage.Defer(delay)
+	}
+	return msg.NackWithDelay(delay, err)
+}
@@ -11,3 +11,11 @@ type QueueConsumer interface {
 	// QueueName retrieves the name of the queue associated with the consumer.
 	QueueName() string
 }
+
+// TopicConsumer is an optional interface for a QueueConsumer on brokers where consumer groups subscribe directly to a topic, such as Kafka.
+// When it is not implemented, the topic name is the queue name.
+type TopicConsumer interface {
+
+	// TopicName retrieves the name of the topic the consumer group subscribes to.
+	TopicName() string
+}
@@ -0,0 +1,195 @@
+package messaging
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"sync"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+)
+
+const (
+	rabbitMQTopologyFileEnvVar  = "RABBITMQ_TOPOLOGY_FILE"
+	rabbitMQDefaultExchangeType = amqp.ExchangeTopic
+	dlqQueueSuffix              = "dlq"
+)
+
+// RabbitMQTopology is a set of exchanges, queues and bindings declared every time the connection is opened or
+// recovered. It is declared from code with DeclareRabbitMQTopology, or from a JSON file whose path is set in the
+// RABBITMQ_TOPOLOGY_FILE environment variable. Every exchange and queue is durable.
+type RabbitMQTopology struct {
+	Exchanges []RabbitMQExchange `json:"exchanges"`
+	Queues    []RabbitMQQueue    `json:"queues"`
+	Bindings  []RabbitMQBinding  `json:"bindings"`
+}
+
+// RabbitMQExchange is an exchange of a RabbitMQTopology
+type RabbitMQExchange struct {
+	Name string `json:"name"`
+	// Type is the exchange type, such as topic, direct, fanout or x-consistent-hash. The default is topic.
+	Type      string         `json:"type,omitempty"`
+	Arguments map[string]any `json:"arguments,omitempty"`
+}
+
+// RabbitMQQueue is a queue of a RabbitMQTopology
+type RabbitMQQueue struct {
+	Name string `json:"name"`
+	// DeadLetter declares the <name>.dlx exchange bound to the <name>.dlq queue, which receive the rejected messages
+	DeadLetter bool           `json:"deadLetter,omitempty"`
+	Arguments  map[string]any `json:"arguments,omitempty"`
+}
+
+// RabbitMQBinding routes the messages of an exchange to a queue
+type RabbitMQBinding struct {
+	Exchange string `json:"exchange"`
+	Queue    string `json:"queue"`
+	// RoutingKey is the binding key. The default is the exchange name, which is the routing key used by producers.
+	RoutingKey string         `json:"routingKey,omitempty"`
+	Arguments  map[string]any `json:"arguments,omitempty"`
+}
+
+// rabbitMQDeclarer is the part of an AMQP channel used to declare a topology
+type rabbitMQDeclarer interface {
+	ExchangeDeclare(name, kind string, durable, autoDelete, internal, noWait bool, args amqp.Table) error
+	QueueDeclare(name string, durable, autoDelete, exclusive, noWait bool, args amqp.Table) (amqp.Queue, error)
+	QueueBind(name, key, exchange string, noWait bool, args amqp.Table) error
+}
+
+var rabbitMQTopologies struct {
+	sync.Mutex
+	list []RabbitMQTopology
+}
+
+// NewRabbitMQTopicTopology returns the topology of a topic exchange bound to each queue, with dead-lettering
+// enabled on the queues, like the exchanges and queues the consumers of the topic expect
+func NewRabbitMQTopicTopology(topic string, queues ...string) RabbitMQTopology {
+	topology := RabbitMQTopology{Exchanges: []RabbitMQExchange{{Name: topic}}}
+	for _, queue := range queues {
+		topology.Queues = append(topology.Queues, RabbitMQQueue{Name: queue, DeadLetter: true})
+		topology.Bindings = append(topology.Bindings, RabbitMQBinding{Exchange: topic, Queue: queue})
+	}
+
+	return topology
+}
+
+// DeclareRabbitMQTopology declares the topology on RabbitMQ, again after every reconnection.
+// It can be called before Initialize, in which case the topology is declared when the connection is opened.
+func DeclareRabbitMQTopology(topology RabbitMQTopology) error {
+	rabbitMQTopologies.Lock()
+	rabbitMQTopologies.list = append(rabbitMQTopologies.list, topology)
+	rabbitMQTopologies.Unlock()
+
+	m, ok := instance.(*rabbitMQMessaging)
+	if !ok {
+		return nil
+	}
+
+	return m.declareTopologies([]RabbitMQTopology{topology})
+}
+
+// getRabbitMQTopologies returns the topologies declared from code
+func getRabbitMQTopologies() []RabbitMQTopology {
+	rabbitMQTopologies.Lock()
+	defer rabbitMQTopologies.Unlock()
+
+	return append([]RabbitMQTopology(nil), rabbitMQTopologies.list...)
+}
+
+// loadRabbitMQTopologyFile reads the topology of the RABBITMQ_TOPOLOGY_FILE file, or returns nil when it is not set
+func loadRabbitMQTopologyFile() (*RabbitMQTopology, error) {
+	path := os.Getenv(rabbitMQTopologyFileEnvVar)
+	if path == "" {
+		return nil, nil
+	}
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var topology RabbitMQTopology
+	if err = json.Unmarshal(content, &topology); err != nil {
+		return nil, fmt.Errorf("invalid RabbitMQ topology file %s: %w", path, err)
+	}
+
+	return &topology, nil
+}
+
+// declare declares the exchanges, then the queues with their dead-letter exchanges, then the bindings
+func (t RabbitMQTopology) declare(ch rabbitMQDeclarer) error {
+	for _, exchange := range t.Exchanges {
+		kind := exchange.Type
+		if kind == "" {
+			kind = rabbitMQDefaultExchangeType
+		}
+
+		if err := ch.ExchangeDeclare(exchange.Name, kind, true, false, false, false, getRabbitMQArguments(exchange.Arguments)); err != nil {
+			return err
+		}
+	}
+
+	for _, queue := range t.Queues {
+		if err := queue.declare(ch); err != nil {
+			return err
+		}
+	}
+
+	for _, binding := range t.Bindings {
+		routingKey := binding.RoutingKey
+		if routingKey == "" {
+			routingKey = binding.Exchange
+		}
+
+		if err := ch.QueueBind(binding.Queue, routingKey, binding.Exchange, false, getRabbitMQArguments(binding.Arguments)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (q RabbitMQQueue) declare(ch rabbitMQDeclarer) error {
+	args := getRabbitMQArguments(q.Arguments)
+	if q.DeadLetter {
+		dlx := fmt.Sprintf("%s.%s", q.Name, dlqExchangeSuffix)
+		dlq := fmt.Sprintf("%s.%s", q.Name, dlqQueueSuffix)
+		if err := ch.ExchangeDeclare(dlx, amqp.ExchangeDirect, true, false, false, false, nil); err != nil {
+			return err
+		}
+		if _, err := ch.QueueDeclare(dlq, true, false, false, false, nil); err != nil {
+			return err
+		}
+		if err := ch.QueueBind(dlq, q.Name, dlx, false, nil); err != nil {
+			return err
+		}
+
+		if args == nil {
+			args = amqp.Table{}
+		}
+		args["x-dead-letter-exchange"] = dlx
+		args["x-dead-letter-routing-key"] = q.Name
+	}
+
+	_, err := ch.QueueDeclare(q.Name, true, false, false, false, args)
+	return err
+}
+
+// getRabbitMQArguments returns the arguments as an AMQP table. Whole JSON numbers are converted to integers,
+// since RabbitMQ rejects floating point values for arguments such as x-message-ttl.
+func getRabbitMQArguments(arguments map[string]any) amqp.Table {
+	if len(arguments) == 0 {
+		return nil
+	}
+
+	table := make(amqp.Table, len(arguments))
+	for key, value := range arguments {
+		if number, ok := value.(float64); ok && number == math.Trunc(number) {
+			value = int64(number)
+		}
+		table[key] = value
+	}
+
+	return table
+}
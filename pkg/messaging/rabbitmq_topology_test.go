@@ -0,0 +1,113 @@
+package messaging
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	amqp "github.com/rabbitmq/amqp091-go"
+	"github.com/stretchr/testify/assert"
+)
+
+// rabbitMQDeclarerTest records the declarations of a topology
+type rabbitMQDeclarerTest struct {
+	declarations []string
+	queueArgs    map[string]amqp.Table
+	err          error
+}
+
+func (d *rabbitMQDeclarerTest) ExchangeDeclare(name, kind string, _, _, _, _ bool, _ amqp.Table) error {
+	d.declarations = append(d.declarations, fmt.Sprintf("exchange %s %s", name, kind))
+	return d.err
+}
+
+func (d *rabbitMQDeclarerTest) QueueDeclare(name string, _, _, _, _ bool, args amqp.Table) (amqp.Queue, error) {
+	d.declarations = append(d.declarations, fmt.Sprintf("queue %s", name))
+	if d.queueArgs == nil {
+		d.queueArgs = make(map[string]amqp.Table)
+	}
+	d.queueArgs[name] = args
+	return amqp.Queue{Name: name}, d.err
+}
+
+func (d *rabbitMQDeclarerTest) QueueBind(name, key, exchange string, _ bool, _ amqp.Table) error {
+	d.declarations = append(d.declarations, fmt.Sprintf("bind %s %s %s", exchange, key, name))
+	return d.err
+}
+
+func TestRabbitMQTopology(t *testing.T) {
+	t.Run("Should declare the topic topology with the dead-letter exchange and queue", func(t *testing.T) {
+		declarer := &rabbitMQDeclarerTest{}
+
+		err := NewRabbitMQTopicTopology("USER_CREATE", "USER_CREATE_QUEUE").declare(declarer)
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{
+			"exchange USER_CREATE topic",
+			"exchange USER_CREATE_QUEUE.dlx direct",
+			"queue USER_CREATE_QUEUE.dlq",
+			"bind USER_CREATE_QUEUE.dlx USER_CREATE_QUEUE USER_CREATE_QUEUE.dlq",
+			"queue USER_CREATE_QUEUE",
+			"bind USER_CREATE USER_CREATE USER_CREATE_QUEUE",
+		}, declarer.declarations)
+		assert.Equal(t, amqp.Table{
+			"x-dead-letter-exchange":    "USER_CREATE_QUEUE.dlx",
+			"x-dead-letter-routing-key": "USER_CREATE_QUEUE",
+		}, declarer.queueArgs["USER_CREATE_QUEUE"])
+	})
+
+	t.Run("Should stop at the first declaration error", func(t *testing.T) {
+		declarer := &rabbitMQDeclarerTest{err: errors.New("PRECONDITION_FAILED")}
+
+		err := NewRabbitMQTopicTopology("USER_CREATE", "USER_CREATE_QUEUE").declare(declarer)
+
+		assert.EqualError(t, err, "PRECONDITION_FAILED")
+		assert.Len(t, declarer.declarations, 1)
+	})
+
+	t.Run("Should load the topology file with integer arguments", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "topology.json")
+		content := `{
+			"exchanges": [{"name": "ORDERS", "type": "x-consistent-hash", "arguments": {"hash-header": "x-message-key"}}],
+			"queues": [{"name": "ORDERS_QUEUE", "arguments": {"x-message-ttl": 60000}}],
+			"bindings": [{"exchange": "ORDERS", "queue": "ORDERS_QUEUE", "routingKey": "1"}]
+		}`
+		assert.NoError(t, os.WriteFile(path, []byte(content), 0o600))
+		t.Setenv(rabbitMQTopologyFileEnvVar, path)
+		declarer := &rabbitMQDeclarerTest{}
+
+		topology, err := loadRabbitMQTopologyFile()
+		declareErr := topology.declare(declarer)
+
+		assert.NoError(t, err)
+		assert.NoError(t, declareErr)
+		assert.Equal(t, []string{
+			"exchange ORDERS x-consistent-hash",
+			"queue ORDERS_QUEUE",
+			"bind ORDERS 1 ORDERS_QUEUE",
+		}, declarer.declarations)
+		assert.Equal(t, amqp.Table{"x-message-ttl": int64(60000)}, declarer.queueArgs["ORDERS_QUEUE"])
+	})
+
+	t.Run("Should not load a topology when the file is not set", func(t *testing.T) {
+		t.Setenv(rabbitMQTopologyFileEnvVar, "")
+
+		topology, err := loadRabbitMQTopologyFile()
+
+		assert.NoError(t, err)
+		assert.Nil(t, topology)
+	})
+
+	t.Run("Should return error when the topology file is invalid", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "topology.json")
+		assert.NoError(t, os.WriteFile(path, []byte("invalid"), 0o600))
+		t.Setenv(rabbitMQTopologyFileEnvVar, path)
+
+		topology, err := loadRabbitMQTopologyFile()
+
+		assert.Error(t, err)
+		assert.Nil(t, topology)
+	})
+}
@@ -0,0 +1,199 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/monitoring"
+	"github.com/google/uuid"
+)
+
+const (
+	// headerReplyTo holds the reply queue of a request. It only has lower-case letters, so every encoding keeps it.
+	headerReplyTo = "replyto"
+	// headerInReplyTo holds the id of the request a reply answers
+	headerInReplyTo = "inreplyto"
+
+	replyQueueSuffix = "reply"
+
+	replyWithoutRequest = "discarding reply %s to request %s, which is no longer waiting"
+	couldNotOpenReply   = "discarding reply %s to request %s, which could not be verified or decrypted"
+)
+
+var (
+	// ErrRequestTimeout is returned by Request when no reply arrives before the timeout
+	ErrRequestTimeout = errors.New("request timed out waiting for a reply")
+	// ErrRequestReplyNotSupported is returned when the broker does not support request/reply
+	ErrRequestReplyNotSupported = errors.New("request/reply not supported by the broker")
+	// ErrNoReplyTo is returned by ReplyTo when the message being consumed is not a request
+	ErrNoReplyTo = errors.New("the message has no reply address")
+)
+
+// replyMessaging is implemented by brokers supporting request/reply.
+// Replies received on the reply queue are handed to deliverReply.
+type replyMessaging interface {
+	// replyQueue returns the reply queue of the instance, consuming it from the first call
+	replyQueue(ctx context.Context) (string, error)
+	// reply publishes the message straight to the reply queue
+	reply(ctx context.Context, queue string, msg *ProviderMessage) error
+}
+
+type requestContextKey struct{}
+
+// requestContext is the request being consumed and the producer options its reply is sealed with
+type requestContext struct {
+	msg   *ProviderMessage
+	reply []ProducerOption
+}
+
+// pendingRequests holds the channel waiting for the reply of each request sent by this instance
+var pendingRequests sync.Map
+
+// Request publishes the message to the producer topic and waits for the reply of a consumer calling ReplyTo.
+// The reply is correlated to the request by the request id and arrives on a reply queue of the instance.
+func (p *Producer) Request(ctx context.Context, action string, message any, timeout time.Duration, opts ...PublishOption) (*ProviderMessage, error) {
+	if instance == nil {
+		logging.Fatal(context.Background()).Msg(messagingNotInitialized)
+	}
+	broker, ok := instance.(replyMessaging)
+	if !ok {
+		return nil, ErrRequestReplyNotSupported
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	queue, err := broker.replyQueue(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	correlationID := getCorrelationID(ctx)
+	txn, txnCtx := p.startTransaction(ctx, action, correlationID)
+	defer monitoring.EndTransaction(txn)
+
+	msg := p.newMessage(ctx, txnCtx, action, message, correlationID, append(opts, WithHeader(headerReplyTo, queue))...)
+	replies := make(chan *ProviderMessage, 1)
+	pendingRequests.Store(msg.ID, replies)
+	defer pendingRequests.Delete(msg.ID)
+
+	start := time.Now()
+	if err = p.seal(ctx, msg); err == nil {
+		err = p.send(ctx, msg, 0)
+	}
+	observePublish(p.topic, start, 1, err)
+	if err != nil {
+		logging.Error(ctx).Err(err).Msgf(couldNotSendMsg, msg.ID, p.topic)
+		monitoring.NoticeError(txn, err)
+		return nil, err
+	}
+
+	select {
+	case reply := <-replies:
+		return reply, nil
+	case <-ctx.Done():
+		if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+			err = fmt.Errorf("%w: %s", ErrRequestTimeout, msg.ID)
+		} else {
+			err = ctx.Err()
+		}
+		monitoring.NoticeError(txn, err)
+		return nil, err
+	}
+}
+
+// Request sends a request with the producer and decodes the reply into T
+func Request[T any](ctx context.Context, p *Producer, action string, message any, timeout time.Duration, opts ...PublishOption) (T, error) {
+	var result T
+	reply, err := p.Request(ctx, action, message, timeout, opts...)
+	if err != nil {
+		return result, err
+	}
+
+	err = reply.DecodeMessage(&result)
+	return result, err
+}
+
+// ReplyTo sends the message as the reply to the request being consumed. ctx must be the context given to Consume.
+// The reply is encrypted, offloaded and signed as set with the WithReplyOptions of the consumer.
+func ReplyTo(ctx context.Context, message any, opts ...PublishOption) error {
+	requestCtx, ok := ctx.Value(requestContextKey{}).(requestContext)
+	if !ok || requestCtx.msg.Header(headerReplyTo) == "" {
+		return ErrNoReplyTo
+	}
+	broker, ok := instance.(replyMessaging)
+	if !ok {
+		return ErrRequestReplyNotSupported
+	}
+
+	request := requestCtx.msg
+	p := NewProducer(request.Header(headerReplyTo), requestCtx.reply...)
+	reply := &ProviderMessage{
+		ID:            uuid.New(),
+		Origin:        config.APP_NAME,
+		Action:        request.Action,
+		Message:       message,
+		CorrelationID: request.CorrelationID,
+		OccurredAt:    time.Now().UTC(),
+		traceContext:  make(map[string]string),
+		encoding:      p.encoding,
+	}
+	for _, opt := range append(opts, WithHeader(headerInReplyTo, request.ID.String())) {
+		opt(reply)
+	}
+	monitoring.InjectTraceContext(ctx, reply.traceContext)
+
+	if err := p.seal(ctx, reply); err != nil {
+		return err
+	}
+
+	return broker.reply(ctx, request.Header(headerReplyTo), reply)
+}
+
+// withRequest returns the context of a consumed message, from which ReplyTo reads the request
+func withRequest(ctx context.Context, msg *ProviderMessage, reply []ProducerOption) context.Context {
+	return context.WithValue(ctx, requestContextKey{}, requestContext{msg: msg, reply: reply})
+}
+
+// deliverReply opens the reply as consumers open messages and hands it to the request waiting for it,
+// discarding replies that fail verification or arrive after the timeout
+func deliverReply(ctx context.Context, reply *ProviderMessage) {
+	requestID, err := uuid.Parse(reply.Header(headerInReplyTo))
+	if err != nil {
+		return
+	}
+
+	replies, ok := pendingRequests.Load(requestID)
+	if !ok {
+		logging.Debug(ctx).Msgf(replyWithoutRequest, reply.ID, requestID)
+		return
+	}
+
+	err = verifySignature(ctx, false, reply)
+	if err == nil {
+		err = resolveClaimCheck(ctx, reply)
+	}
+	if err == nil {
+		err = decryptMessage(ctx, reply)
+	}
+	if err != nil {
+		logging.Error(ctx).Err(err).Msgf(couldNotOpenReply, reply.ID, requestID)
+		return
+	}
+	releaseClaimCheck(ctx, reply)
+
+	select {
+	case replies.(chan *ProviderMessage) <- reply:
+	default:
+	}
+}
+
+// getReplyQueueName returns a reply queue name unique to this instance
+func getReplyQueueName() string {
+	return fmt.Sprintf("%s.%s.%s", config.APP_NAME, replyQueueSuffix, uuid.NewString())
+}
@@ -0,0 +1,119 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/stretchr/testify/assert"
+)
+
+type greetingMessageTest struct {
+	Greeting string `json:"greeting"`
+}
+
+func TestRequestReply(t *testing.T) {
+	useMemoryMessaging(t)
+
+	greet := func(ctx context.Context, message *ProviderMessage) error {
+		var user userMessageTest
+		if err := message.DecodeMessage(&user); err != nil {
+			return err
+		}
+		return ReplyTo(ctx, greetingMessageTest{Greeting: "Hello " + user.Name}, WithHeader("source", "greeter"))
+	}
+
+	t.Run("Should return the typed reply of the responder", func(t *testing.T) {
+		Memory().Bind("REQUEST_TOPIC", "REQUEST_QUEUE")
+		newConsumerTest(t, &queueConsumerTest{fn: greet, qName: "REQUEST_QUEUE"})
+		ctx := context.WithValue(context.Background(), logging.CorrelationIDParam, "request-correlation")
+
+		reply, err := NewProducer("REQUEST_TOPIC").Request(ctx, "greet", userMessageTest{Name: "User"}, time.Second)
+
+		assert.NoError(t, err)
+		assert.Equal(t, "greet", reply.Action)
+		assert.Equal(t, "request-correlation", reply.CorrelationID)
+		assert.Equal(t, "greeter", reply.Header("source"))
+		assert.Equal(t, Memory().Published("REQUEST_TOPIC")[0].ID.String(), reply.Header(headerInReplyTo))
+	})
+
+	t.Run("Should wait for the reply of an asynchronous responder", func(t *testing.T) {
+		Memory().SetSynchronous(false).Bind("REQUEST_ASYNC_TOPIC", "REQUEST_ASYNC_QUEUE")
+		defer Memory().SetSynchronous(true)
+		consumer := newConsumerTest(t, &queueConsumerTest{fn: greet, qName: "REQUEST_ASYNC_QUEUE"})
+		defer func() { _ = consumer.Stop(context.Background()) }()
+
+		result, err := Request[greetingMessageTest](context.Background(), NewProducer("REQUEST_ASYNC_TOPIC"), "greet",
+			userMessageTest{Name: "User"}, time.Second)
+
+		assert.NoError(t, err)
+		assert.Equal(t, greetingMessageTest{Greeting: "Hello User"}, result)
+	})
+
+	t.Run("Should return timeout error when nobody replies", func(t *testing.T) {
+		Memory().Bind("REQUEST_NO_REPLY_TOPIC", "REQUEST_NO_REPLY_QUEUE")
+		newConsumerTest(t, &queueConsumerTest{
+			fn:    func(ctx context.Context, message *ProviderMessage) error { return nil },
+			qName: "REQUEST_NO_REPLY_QUEUE",
+		})
+
+		reply, err := NewProducer("REQUEST_NO_REPLY_TOPIC").Request(context.Background(), "greet", userMessageTest{Name: "User"}, 50*time.Millisecond)
+
+		assert.ErrorIs(t, err, ErrRequestTimeout)
+		assert.Nil(t, reply)
+	})
+
+	t.Run("Should seal the reply with the reply options of the responder and open it for the requester", func(t *testing.T) {
+		provider, err := NewLocalKeyProvider(writeKeyFileTest(t, "2026-10"))
+		assert.NoError(t, err)
+		useKeyProviderTest(t, provider)
+		useSigningKeyProviderTest(t, SigningKeys{config.APP_NAME: []byte("secret of the app")})
+		Memory().Bind("REQUEST_SEALED_TOPIC", "REQUEST_SEALED_QUEUE")
+		newConsumerTest(t, &queueConsumerTest{fn: greet, qName: "REQUEST_SEALED_QUEUE"}, WithReplyOptions(WithEncryption(), WithSigning()))
+
+		reply, err := NewProducer("REQUEST_SEALED_TOPIC").Request(context.Background(), "greet", userMessageTest{Name: "User"}, time.Second)
+
+		assert.NoError(t, err)
+		var greeting greetingMessageTest
+		assert.NoError(t, reply.DecodeMessage(&greeting))
+		assert.Equal(t, greetingMessageTest{Greeting: "Hello User"}, greeting)
+		assert.NotEmpty(t, reply.Header(headerSignature))
+		assert.Empty(t, reply.Header(headerEncryption))
+	})
+
+	t.Run("Should discard the replies that fail verification", func(t *testing.T) {
+		useSigningKeyProviderTest(t, SigningKeys{config.APP_NAME: []byte("secret of the app")})
+		Memory().Bind("REQUEST_FORGED_TOPIC", "REQUEST_FORGED_QUEUE")
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				return ReplyTo(ctx, greetingMessageTest{Greeting: "Hello"}, WithHeader(headerSignature, "Zm9yZ2Vk"))
+			},
+			qName: "REQUEST_FORGED_QUEUE",
+		})
+
+		reply, err := NewProducer("REQUEST_FORGED_TOPIC").Request(context.Background(), "greet", userMessageTest{Name: "User"}, 50*time.Millisecond)
+
+		assert.ErrorIs(t, err, ErrRequestTimeout)
+		assert.Nil(t, reply)
+	})
+
+	t.Run("Should return error when replying to a message that is not a request", func(t *testing.T) {
+		Memory().Bind("REQUEST_PUBLISH_TOPIC", "REQUEST_PUBLISH_QUEUE")
+		var replyErr error
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				replyErr = ReplyTo(ctx, greetingMessageTest{Greeting: "Hello"})
+				return nil
+			},
+			qName: "REQUEST_PUBLISH_QUEUE",
+		})
+
+		err := NewProducer("REQUEST_PUBLISH_TOPIC").Publish(context.Background(), "greet", userMessageTest{Name: "User"})
+
+		assert.NoError(t, err)
+		assert.True(t, errors.Is(replyErr, ErrNoReplyTo))
+	})
+}
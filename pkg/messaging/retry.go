@@ -0,0 +1,78 @@
+package messaging
+
+import (
+	"errors"
+	"math"
+	"time"
+)
+
+const (
+	headerFailureReason = "x-failure-reason"
+	headerAttempts      = "x-attempts"
+	// headerMessageKey carries the ordering key of the message on every broker
+	headerMessageKey = "x-message-key"
+)
+
+// RetryPolicy defines how a consumer retries messages whose processing failed
+// before routing them to the dead-letter queue.
+type RetryPolicy struct {
+	// MaxAttempts is the total number of deliveries, including the first one. Values lower than 1 mean 1.
+	MaxAttempts int
+	// InitialBackoff is the delay before the second delivery.
+	InitialBackoff time.Duration
+	// MaxBackoff caps the delay between deliveries. Zero means no cap.
+	MaxBackoff time.Duration
+	// Multiplier is the factor applied to the delay after each attempt. Values lower than 1 mean 2.
+	Multiplier float64
+}
+
+// permanentError marks a processing error that retrying can not fix
+type permanentError struct {
+	err error
+}
+
+func (e permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e permanentError) Unwrap() error {
+	return e.err
+}
+
+// NewPermanentError wraps err so the consumer sends the message straight to the dead-letter queue, skipping retries
+func NewPermanentError(err error) error {
+	return permanentError{err: err}
+}
+
+// IsPermanentError returns true when err was wrapped with NewPermanentError
+func IsPermanentError(err error) bool {
+	return errors.As(err, &permanentError{})
+}
+
+// DefaultRetryPolicy does not retry, so failed messages go straight to the dead-letter queue
+var DefaultRetryPolicy = RetryPolicy{MaxAttempts: 1}
+
+// maxAttempts returns the total number of deliveries allowed by the policy
+func (p RetryPolicy) maxAttempts() int {
+	return max(p.MaxAttempts, 1)
+}
+
+// ShouldRetry returns true when a message delivered attempt times can be delivered again
+func (p RetryPolicy) ShouldRetry(attempt int) bool {
+	return attempt < p.maxAttempts()
+}
+
+// Backoff returns the delay before the delivery following the given attempt, growing exponentially
+func (p RetryPolicy) Backoff(attempt int) time.Duration {
+	multiplier := p.Multiplier
+	if multiplier < 1 {
+		multiplier = 2
+	}
+
+	delay := time.Duration(float64(p.InitialBackoff) * math.Pow(multiplier, float64(max(attempt-1, 0))))
+	if delay < 0 || (p.MaxBackoff > 0 && delay > p.MaxBackoff) {
+		return p.MaxBackoff
+	}
+
+	return delay
+}
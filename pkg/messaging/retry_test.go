@@ -0,0 +1,39 @@
+package messaging
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRetryPolicy(t *testing.T) {
+	t.Run("Should not retry with default policy", func(t *testing.T) {
+		options := newConsumerOptions()
+
+		assert.False(t, options.Retry.ShouldRetry(1))
+	})
+
+	t.Run("Should retry while attempts are lower than max attempts", func(t *testing.T) {
+		policy := RetryPolicy{MaxAttempts: 3}
+
+		assert.True(t, policy.ShouldRetry(1))
+		assert.True(t, policy.ShouldRetry(2))
+		assert.False(t, policy.ShouldRetry(3))
+	})
+
+	t.Run("Should grow backoff exponentially up to max backoff", func(t *testing.T) {
+		policy := RetryPolicy{MaxAttempts: 10, InitialBackoff: time.Second, MaxBackoff: 5 * time.Second, Multiplier: 2}
+
+		assert.Equal(t, time.Second, policy.Backoff(1))
+		assert.Equal(t, 2*time.Second, policy.Backoff(2))
+		assert.Equal(t, 4*time.Second, policy.Backoff(3))
+		assert.Equal(t, 5*time.Second, policy.Backoff(4))
+	})
+
+	t.Run("Should use multiplier 2 when not set", func(t *testing.T) {
+		policy := RetryPolicy{InitialBackoff: 100 * time.Millisecond}
+
+		assert.Equal(t, 400*time.Millisecond, policy.Backoff(3))
+	})
+}
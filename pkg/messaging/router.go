@@ -0,0 +1,125 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+)
+
+const unknownActionIgnored = "no handler for action %s on queue %s, message %s acknowledged"
+
+// ErrUnknownAction is returned when a Router has no handler for the message action
+var ErrUnknownAction = errors.New("unknown action")
+
+// UnknownActionPolicy defines what a Router does with messages whose action has no handler
+type UnknownActionPolicy int
+
+const (
+	// UnknownActionAck acknowledges and drops the message
+	UnknownActionAck UnknownActionPolicy = iota
+	// UnknownActionNack rejects the message, so it is retried according to the consumer retry policy
+	UnknownActionNack
+	// UnknownActionDLQ sends the message straight to the dead-letter queue
+	UnknownActionDLQ
+)
+
+// MessageHandler processes a message routed by action
+type MessageHandler func(ctx context.Context, message *ProviderMessage) error
+
+type routerContextKey struct{}
+
+// Router is a QueueConsumer that dispatches messages to a handler registered for their action
+type Router struct {
+	queue    string
+	topic    string
+	handlers map[string]MessageHandler
+	unknown  UnknownActionPolicy
+}
+
+// RouterOption configures optional behaviour of a Router
+type RouterOption func(r *Router)
+
+// WithRouterTopic sets the topic the router subscribes to on brokers where consumer groups subscribe to topics
+func WithRouterTopic(topic string) RouterOption {
+	return func(r *Router) {
+		r.topic = topic
+	}
+}
+
+// WithUnknownActionPolicy sets what the router does with messages whose action has no handler
+func WithUnknownActionPolicy(policy UnknownActionPolicy) RouterOption {
+	return func(r *Router) {
+		r.unknown = policy
+	}
+}
+
+// NewRouter returns a Router consuming the queue. Unknown actions are acknowledged unless configured otherwise.
+func NewRouter(queueName string, opts ...RouterOption) *Router {
+	r := &Router{
+		queue:    queueName,
+		topic:    queueName,
+		handlers: make(map[string]MessageHandler),
+		unknown:  UnknownActionAck,
+	}
+	for _, opt := range opts {
+		opt(r)
+	}
+
+	return r
+}
+
+// Handle registers the handler of an action, replacing any previous one
+func (r *Router) Handle(action string, handler MessageHandler) *Router {
+	r.handlers[action] = handler
+	return r
+}
+
+// Typed returns a MessageHandler that decodes and validates the payload into T before calling fn.
+// Payloads that can not be decoded or are invalid are sent straight to the dead-letter queue.
+func Typed[T any](fn func(ctx context.Context, message T) error) MessageHandler {
+	return func(ctx context.Context, message *ProviderMessage) error {
+		var model T
+		if err := message.DecodeAndValidateMessage(&model); err != nil {
+			return NewPermanentError(err)
+		}
+
+		return fn(ctx, model)
+	}
+}
+
+// QueueName returns the queue consumed by the router
+func (r *Router) QueueName() string {
+	return r.queue
+}
+
+// TopicName returns the topic the router subscribes to
+func (r *Router) TopicName() string {
+	return r.topic
+}
+
+// Consume dispatches the message to the handler registered for its action
+func (r *Router) Consume(ctx context.Context, message *ProviderMessage) error {
+	handler, ok := r.handlers[message.Action]
+	if ok {
+		return handler(context.WithValue(ctx, routerContextKey{}, message), message)
+	}
+
+	err := fmt.Errorf("%w: %s", ErrUnknownAction, message.Action)
+	switch r.unknown {
+	case UnknownActionNack:
+		return err
+	case UnknownActionDLQ:
+		return NewPermanentError(err)
+	default:
+		logging.Warn(ctx).Msgf(unknownActionIgnored, message.Action, r.queue, message.ID)
+		return nil
+	}
+}
+
+// MessageFromContext returns the message being handled by a Router, so typed handlers can read its metadata
+func MessageFromContext(ctx context.Context) *ProviderMessage {
+	message, _ := ctx.Value(routerContextKey{}).(*ProviderMessage)
+	return message
+}
@@ -0,0 +1,86 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type userCreatedTest struct {
+	Name  string `json:"name" validate:"required"`
+	Email string `json:"email"`
+}
+
+func TestRouter(t *testing.T) {
+	useMemoryMessaging(t)
+	ctx := context.Background()
+
+	t.Run("Should decode payload and call the handler of the action", func(t *testing.T) {
+		var received userCreatedTest
+		var receivedMessage *ProviderMessage
+		router := NewRouter("ROUTER_QUEUE").
+			Handle("create", Typed(func(ctx context.Context, user userCreatedTest) error {
+				received = user
+				receivedMessage = MessageFromContext(ctx)
+				return nil
+			})).
+			Handle("delete", Typed(func(ctx context.Context, user userCreatedTest) error {
+				return errors.New("should not be called")
+			}))
+		msg := NewProviderMessage(ctx, "create", userCreatedTest{Name: "User", Email: "user@email.com"})
+
+		err := router.Consume(ctx, msg)
+
+		assert.NoError(t, err)
+		assert.Equal(t, userCreatedTest{Name: "User", Email: "user@email.com"}, received)
+		assert.Equal(t, msg, receivedMessage)
+	})
+
+	t.Run("Should return permanent error when payload is invalid", func(t *testing.T) {
+		router := NewRouter("ROUTER_QUEUE").Handle("create", Typed(func(ctx context.Context, user userCreatedTest) error {
+			return nil
+		}))
+
+		err := router.Consume(ctx, NewProviderMessage(ctx, "create", userCreatedTest{Email: "user@email.com"}))
+
+		assert.Error(t, err)
+		assert.True(t, IsPermanentError(err))
+	})
+
+	t.Run("Should apply unknown action policy", func(t *testing.T) {
+		msg := NewProviderMessage(ctx, "update", userCreatedTest{Name: "User"})
+
+		ackErr := NewRouter("ROUTER_QUEUE").Consume(ctx, msg)
+		nackErr := NewRouter("ROUTER_QUEUE", WithUnknownActionPolicy(UnknownActionNack)).Consume(ctx, msg)
+		dlqErr := NewRouter("ROUTER_QUEUE", WithUnknownActionPolicy(UnknownActionDLQ)).Consume(ctx, msg)
+
+		assert.NoError(t, ackErr)
+		assert.ErrorIs(t, nackErr, ErrUnknownAction)
+		assert.False(t, IsPermanentError(nackErr))
+		assert.ErrorIs(t, dlqErr, ErrUnknownAction)
+		assert.True(t, IsPermanentError(dlqErr))
+	})
+
+	t.Run("Should plug into consumer and skip retries for permanent errors", func(t *testing.T) {
+		created := make([]string, 0)
+		router := NewRouter("ROUTER_BROKER_QUEUE", WithRouterTopic("ROUTER_TOPIC"), WithUnknownActionPolicy(UnknownActionDLQ)).
+			Handle("create", Typed(func(ctx context.Context, user userCreatedTest) error {
+				created = append(created, user.Name)
+				return nil
+			}))
+		Memory().Bind("ROUTER_TOPIC", "ROUTER_BROKER_QUEUE")
+		newConsumerTest(t, router, WithRetryPolicy(RetryPolicy{MaxAttempts: 3}))
+
+		producer := NewProducer("ROUTER_TOPIC")
+		assert.NoError(t, producer.Publish(ctx, "create", userCreatedTest{Name: "User"}))
+		assert.NoError(t, producer.Publish(ctx, "archive", userCreatedTest{Name: "User"}))
+		deadLetters := Memory().DeadLetters("ROUTER_BROKER_QUEUE")
+
+		assert.Equal(t, []string{"User"}, created)
+		assert.Len(t, deadLetters, 1)
+		assert.Equal(t, 1, deadLetters[0].Attempts)
+		assert.Equal(t, "unknown action: archive", deadLetters[0].Reason)
+	})
+}
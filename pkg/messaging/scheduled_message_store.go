@@ -0,0 +1,107 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/database/sqlDB"
+	"github.com/google/uuid"
+)
+
+const (
+	scheduledMessagesDefaultTable = "colibri_scheduled_messages"
+	scheduledMessagesCreateTable  = "CREATE TABLE IF NOT EXISTS %s (id UUID PRIMARY KEY, topic TEXT NOT NULL, message_key TEXT NOT NULL, encoding TEXT NOT NULL, attributes TEXT NOT NULL, payload TEXT NOT NULL, deliver_at TIMESTAMPTZ NOT NULL, locked_until TIMESTAMPTZ)"
+	scheduledMessagesCreateIndex  = "CREATE INDEX IF NOT EXISTS %[1]s_deliver_at_idx ON %[1]s (deliver_at)"
+	scheduledMessagesInsertQuery  = "INSERT INTO %s (id, topic, message_key, encoding, attributes, payload, deliver_at) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT (id) DO NOTHING"
+	scheduledMessagesDueQuery     = "UPDATE %[1]s SET locked_until = now() + $1 * interval '1 millisecond' WHERE id IN (SELECT id FROM %[1]s WHERE deliver_at <= now() AND (locked_until IS NULL OR locked_until < now()) ORDER BY deliver_at LIMIT $2 FOR UPDATE SKIP LOCKED) RETURNING id, topic, message_key, encoding, attributes, payload, deliver_at"
+	scheduledMessagesDeleteQuery  = "DELETE FROM %s WHERE id = $1"
+)
+
+// scheduledMessageRow is a row of the scheduled messages table
+type scheduledMessageRow struct {
+	ID         uuid.UUID
+	Topic      string
+	Key        string
+	Encoding   string
+	Attributes string
+	Payload    string
+	DeliverAt  time.Time
+}
+
+// SQLScheduledMessageStore keeps scheduled messages in a Postgres table with sqlDB.
+// The table is created on first use. Inside a sqlDB transaction, Save is part of that transaction.
+type SQLScheduledMessageStore struct {
+	table       string
+	createTable sqlTableCreator
+}
+
+// NewSQLScheduledMessageStore returns a ScheduledMessageStore backed by the given table, or colibri_scheduled_messages when empty
+func NewSQLScheduledMessageStore(table string) *SQLScheduledMessageStore {
+	if table == "" {
+		table = scheduledMessagesDefaultTable
+	}
+
+	return &SQLScheduledMessageStore{table: table}
+}
+
+// Save inserts the message, ignoring messages that were already saved
+func (s *SQLScheduledMessageStore) Save(ctx context.Context, msg ScheduledMessage) error {
+	if err := s.ensureTable(ctx); err != nil {
+		return err
+	}
+
+	attributes, err := json.Marshal(msg.Attributes)
+	if err != nil {
+		return err
+	}
+
+	return sqlDB.NewStatement(ctx, fmt.Sprintf(scheduledMessagesInsertQuery, s.table),
+		msg.ID, msg.Topic, msg.Key, string(msg.Encoding), string(attributes), string(msg.Payload), msg.DeliverAt,
+	).Execute()
+}
+
+// Due claims the oldest due messages that are not claimed by another scheduler
+func (s *SQLScheduledMessageStore) Due(ctx context.Context, limit int, lease time.Duration) ([]ScheduledMessage, error) {
+	if err := s.ensureTable(ctx); err != nil {
+		return nil, err
+	}
+
+	rows, err := sqlDB.NewQuery[scheduledMessageRow](ctx, fmt.Sprintf(scheduledMessagesDueQuery, s.table), lease.Milliseconds(), limit).Many()
+	if err != nil {
+		return nil, err
+	}
+
+	msgs := make([]ScheduledMessage, 0, len(rows))
+	for _, row := range rows {
+		var attributes map[string]string
+		if err = json.Unmarshal([]byte(row.Attributes), &attributes); err != nil {
+			return nil, err
+		}
+
+		msgs = append(msgs, ScheduledMessage{
+			ID:         row.ID,
+			Topic:      row.Topic,
+			Key:        row.Key,
+			Encoding:   Encoding(row.Encoding),
+			Attributes: attributes,
+			Payload:    []byte(row.Payload),
+			DeliverAt:  row.DeliverAt,
+		})
+	}
+
+	return msgs, nil
+}
+
+// Delete removes a published message
+func (s *SQLScheduledMessageStore) Delete(ctx context.Context, id uuid.UUID) error {
+	return sqlDB.NewStatement(ctx, fmt.Sprintf(scheduledMessagesDeleteQuery, s.table), id).Execute()
+}
+
+func (s *SQLScheduledMessageStore) ensureTable(ctx context.Context) error {
+	return s.createTable.ensure(ctx,
+		fmt.Sprintf(scheduledMessagesCreateTable, s.table),
+		fmt.Sprintf(scheduledMessagesCreateIndex, s.table),
+	)
+}
@@ -0,0 +1,239 @@
+package messaging
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"maps"
+	"sync"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/logging"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/observer"
+	"github.com/google/uuid"
+)
+
+const (
+	schedulerDefaultPollInterval = 5 * time.Second
+	schedulerDefaultBatchSize    = 100
+	schedulerDefaultLease        = time.Minute
+
+	schedulerNotInitialized     string = "messaging scheduler has not been initialized. add in main.go `messaging.InitializeScheduler()`"
+	schedulerAlreadyStarted     string = "messaging scheduler already started"
+	schedulerStarted            string = "messaging scheduler started"
+	closingScheduler            string = "closing messaging scheduler"
+	couldNotReadScheduledMsgs   string = "could not read due scheduled messages"
+	couldNotPublishScheduledMsg string = "could not publish scheduled message %s to topic %s"
+	couldNotDeleteScheduledMsg  string = "could not delete scheduled message %s"
+	couldNotDecodeScheduledMsg  string = "could not decode scheduled message %s"
+)
+
+// ErrSchedulerNotInitialized is returned when a message has to be scheduled before InitializeScheduler is called
+var ErrSchedulerNotInitialized = errors.New(schedulerNotInitialized)
+
+// ScheduledMessage is a message kept in the scheduler store until it is due
+type ScheduledMessage struct {
+	ID         uuid.UUID
+	Topic      string
+	Key        string
+	Encoding   Encoding
+	Attributes map[string]string
+	Payload    []byte
+	DeliverAt  time.Time
+}
+
+// ScheduledMessageStore persists the messages delayed by the scheduler.
+// Due claims up to limit due messages for the lease duration, so concurrent schedulers do not publish them twice.
+// Messages that are not deleted before the lease expires are claimed again.
+type ScheduledMessageStore interface {
+	Save(ctx context.Context, msg ScheduledMessage) error
+	Due(ctx context.Context, limit int, lease time.Duration) ([]ScheduledMessage, error)
+	Delete(ctx context.Context, id uuid.UUID) error
+}
+
+// SchedulerOptions configures the scheduler
+type SchedulerOptions struct {
+	// Store persists the scheduled messages. The default is a SQLScheduledMessageStore.
+	Store ScheduledMessageStore
+	// PollInterval is how often the store is polled for due messages
+	PollInterval time.Duration
+	// BatchSize is the maximum number of messages claimed per query
+	BatchSize int
+	// Lease is how long claimed messages are hidden from other schedulers while they are published
+	Lease time.Duration
+}
+
+// SchedulerOption configures optional behaviour of the scheduler
+type SchedulerOption func(o *SchedulerOptions)
+
+// WithSchedulerStore sets the store of the scheduled messages
+func WithSchedulerStore(store ScheduledMessageStore) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.Store = store
+	}
+}
+
+// WithSchedulerPollInterval sets how often the store is polled for due messages
+func WithSchedulerPollInterval(interval time.Duration) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.PollInterval = interval
+	}
+}
+
+// WithSchedulerBatchSize sets the maximum number of messages claimed per query
+func WithSchedulerBatchSize(size int) SchedulerOption {
+	return func(o *SchedulerOptions) {
+		o.BatchSize = size
+	}
+}
+
+type messageScheduler struct {
+	sync.WaitGroup
+	options SchedulerOptions
+	done    chan any
+}
+
+type schedulerObserver struct {
+	s *messageScheduler
+}
+
+func (o schedulerObserver) Close() {
+	logging.Info(context.Background()).Msg(closingScheduler)
+	o.s.close()
+}
+
+var scheduler *messageScheduler
+
+// InitializeScheduler starts the worker that publishes the messages delayed with PublishAt or PublishAfter
+// beyond the broker limits. Messages are published at least once: a message is published again when the
+// scheduler stops between publishing and deleting it.
+func InitializeScheduler(opts ...SchedulerOption) {
+	if instance == nil {
+		logging.Fatal(context.Background()).Msg(messagingNotInitialized)
+	}
+
+	if scheduler != nil {
+		logging.Info(context.Background()).Msg(schedulerAlreadyStarted)
+		return
+	}
+
+	scheduler = newMessageScheduler(opts...)
+	observer.Attach(schedulerObserver{s: scheduler})
+
+	scheduler.Add(1)
+	go scheduler.run()
+
+	logging.Info(context.Background()).Msg(schedulerStarted)
+}
+
+func newMessageScheduler(opts ...SchedulerOption) *messageScheduler {
+	options := SchedulerOptions{
+		PollInterval: schedulerDefaultPollInterval,
+		BatchSize:    schedulerDefaultBatchSize,
+		Lease:        schedulerDefaultLease,
+	}
+	for _, opt := range opts {
+		opt(&options)
+	}
+
+	if options.Store == nil {
+		options.Store = NewSQLScheduledMessageStore("")
+	}
+	if options.PollInterval <= 0 {
+		options.PollInterval = schedulerDefaultPollInterval
+	}
+	options.BatchSize = max(options.BatchSize, 1)
+
+	return &messageScheduler{options: options, done: make(chan any)}
+}
+
+// scheduleMessage saves the message in the scheduler store until the given time
+func scheduleMessage(ctx context.Context, topic string, msg *ProviderMessage, at time.Time) error {
+	if scheduler == nil {
+		return ErrSchedulerNotInitialized
+	}
+
+	return scheduler.options.Store.Save(ctx, ScheduledMessage{
+		ID:         msg.ID,
+		Topic:      topic,
+		Key:        msg.key,
+		Encoding:   msg.encoding,
+		Attributes: maps.Clone(msg.traceContext),
+		Payload:    []byte(msg.String()),
+		DeliverAt:  at.UTC(),
+	})
+}
+
+// run polls the store until the scheduler is closed
+func (s *messageScheduler) run() {
+	defer s.Done()
+
+	ticker := time.NewTicker(s.options.PollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.publishDue(context.Background())
+
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// publishDue publishes the due messages, claiming batches until there are no more due messages
+func (s *messageScheduler) publishDue(ctx context.Context) {
+	for {
+		msgs, err := s.options.Store.Due(ctx, s.options.BatchSize, s.options.Lease)
+		if err != nil {
+			logging.Error(ctx).Err(err).Msg(couldNotReadScheduledMsgs)
+			return
+		}
+
+		for _, msg := range msgs {
+			s.publish(ctx, msg)
+		}
+
+		if len(msgs) < s.options.BatchSize || s.isCanceled() {
+			return
+		}
+	}
+}
+
+// publish sends the scheduled message to its topic and deletes it from the store.
+// Messages that fail are kept and published again once their lease expires.
+func (s *messageScheduler) publish(ctx context.Context, scheduled ScheduledMessage) {
+	var msg ProviderMessage
+	if err := json.Unmarshal(scheduled.Payload, &msg); err != nil {
+		logging.Error(ctx).Err(err).Msgf(couldNotDecodeScheduledMsg, scheduled.ID)
+		return
+	}
+	msg.key = scheduled.Key
+	msg.encoding = scheduled.Encoding
+	msg.traceContext = scheduled.Attributes
+
+	if err := instance.producer(ctx, NewProducer(scheduled.Topic), &msg); err != nil {
+		logging.Error(ctx).Err(err).Msgf(couldNotPublishScheduledMsg, scheduled.ID, scheduled.Topic)
+		return
+	}
+
+	if err := s.options.Store.Delete(ctx, scheduled.ID); err != nil {
+		logging.Error(ctx).Err(err).Msgf(couldNotDeleteScheduledMsg, scheduled.ID)
+	}
+}
+
+func (s *messageScheduler) isCanceled() bool {
+	select {
+	case <-s.done:
+		return true
+	default:
+		return false
+	}
+}
+
+// close stops polling and waits for the messages being published
+func (s *messageScheduler) close() {
+	close(s.done)
+	s.Wait()
+}
@@ -0,0 +1,118 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"slices"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/stretchr/testify/assert"
+)
+
+type scheduledMessageStoreTest struct {
+	mu   sync.Mutex
+	msgs []ScheduledMessage
+	err  error
+}
+
+func (s *scheduledMessageStoreTest) Save(_ context.Context, msg ScheduledMessage) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.msgs = append(s.msgs, msg)
+	return s.err
+}
+
+func (s *scheduledMessageStoreTest) Due(_ context.Context, limit int, _ time.Duration) ([]ScheduledMessage, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	due := make([]ScheduledMessage, 0)
+	for _, msg := range s.msgs {
+		if len(due) < limit && !msg.DeliverAt.After(time.Now()) {
+			due = append(due, msg)
+		}
+	}
+
+	return due, s.err
+}
+
+func (s *scheduledMessageStoreTest) Delete(_ context.Context, id uuid.UUID) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.msgs = slices.DeleteFunc(s.msgs, func(msg ScheduledMessage) bool { return msg.ID == id })
+	return s.err
+}
+
+func (s *scheduledMessageStoreTest) len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return len(s.msgs)
+}
+
+func TestScheduler(t *testing.T) {
+	useMemoryMessaging(t)
+	t.Cleanup(func() { scheduler = nil })
+
+	t.Run("Should return error when scheduler is not initialized", func(t *testing.T) {
+		scheduler = nil
+		msg := NewProviderMessage(context.Background(), "create", userMessageTest{Name: "User"})
+
+		err := scheduleMessage(context.Background(), "SCHEDULER_TOPIC", msg, time.Now())
+
+		assert.ErrorIs(t, err, ErrSchedulerNotInitialized)
+	})
+
+	t.Run("Should publish only due messages and delete them from the store", func(t *testing.T) {
+		store := &scheduledMessageStoreTest{}
+		scheduler = newMessageScheduler(WithSchedulerStore(store), WithSchedulerBatchSize(1))
+		due := NewProviderMessage(context.Background(), "due", userMessageTest{Name: "User"})
+		due.key = "user-1"
+		due.traceContext = map[string]string{"traceparent": "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01"}
+		later := NewProviderMessage(context.Background(), "later", userMessageTest{Name: "User"})
+
+		assert.NoError(t, scheduleMessage(context.Background(), "SCHEDULER_TOPIC", due, time.Now().Add(-time.Second)))
+		assert.NoError(t, scheduleMessage(context.Background(), "SCHEDULER_TOPIC", later, time.Now().Add(time.Hour)))
+		scheduler.publishDue(context.Background())
+
+		published := Memory().Published("SCHEDULER_TOPIC")
+		assert.Len(t, published, 1)
+		assert.Equal(t, due.ID, published[0].ID)
+		assert.Equal(t, "due", published[0].Action)
+		assert.Equal(t, "user-1", published[0].key)
+		assert.Equal(t, due.traceContext, published[0].traceContext)
+		assert.Equal(t, 1, store.len())
+	})
+
+	t.Run("Should keep messages in the store when it fails", func(t *testing.T) {
+		Memory().Reset()
+		store := &scheduledMessageStoreTest{}
+		scheduler = newMessageScheduler(WithSchedulerStore(store))
+		msg := NewProviderMessage(context.Background(), "create", userMessageTest{Name: "User"})
+		assert.NoError(t, scheduleMessage(context.Background(), "SCHEDULER_FAIL_TOPIC", msg, time.Now()))
+		store.err = errors.New("database unavailable")
+
+		scheduler.publishDue(context.Background())
+
+		assert.Empty(t, Memory().Published("SCHEDULER_FAIL_TOPIC"))
+		assert.Equal(t, 1, store.len())
+	})
+
+	t.Run("Should poll the store until closed", func(t *testing.T) {
+		scheduler = nil
+		store := &scheduledMessageStoreTest{}
+		InitializeScheduler(WithSchedulerStore(store), WithSchedulerPollInterval(10*time.Millisecond))
+		msg := NewProviderMessage(context.Background(), "create", userMessageTest{Name: "User"})
+
+		assert.NoError(t, scheduleMessage(context.Background(), "SCHEDULER_POLL_TOPIC", msg, time.Now()))
+
+		assert.Eventually(t, func() bool { return len(Memory().Published("SCHEDULER_POLL_TOPIC")) == 1 }, time.Second, 10*time.Millisecond)
+		assert.Equal(t, 0, store.len())
+		scheduler.close()
+	})
+}
@@ -0,0 +1,231 @@
+package messaging
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
+)
+
+// headerSignature holds the HMAC-SHA256 of the message envelope, keyed by its origin.
+// It only has lower-case letters, so it is kept as is by every encoding.
+const headerSignature = "signature"
+
+var (
+	// ErrMissingSignature is returned when a consumer requiring signatures receives an unsigned message
+	ErrMissingSignature = errors.New("message is not signed")
+	// ErrInvalidSignature is returned when the signature does not match the message, which was tampered with
+	// or not published by its origin
+	ErrInvalidSignature = errors.New("invalid message signature")
+	// ErrNoSigningKeyProvider is returned when a message is signed or verified without a signing key provider
+	ErrNoSigningKeyProvider = errors.New("no messaging signing key provider, set one with SetSigningKeyProvider or MESSAGING_KEY_FILE")
+	// ErrHeaderNotSignable is returned when signing a CloudEvents message with a header that is not a valid
+	// CloudEvents extension name, since consumers would receive it renamed and could not verify the signature
+	ErrHeaderNotSignable = errors.New("header can not be signed with the CloudEvents encoding, use only lower-case letters and digits")
+)
+
+// SigningKeyProvider returns the HMAC key of each application publishing signed messages.
+// Producers sign with the key of config.APP_NAME and consumers verify with the key of the message origin.
+type SigningKeyProvider interface {
+	SigningKey(ctx context.Context, origin string) ([]byte, error)
+}
+
+// SigningKeys is a SigningKeyProvider with the HMAC key of each origin
+type SigningKeys map[string][]byte
+
+// SigningKey returns the key of the origin, or ErrKeyNotFound
+func (k SigningKeys) SigningKey(_ context.Context, origin string) ([]byte, error) {
+	key, ok := k[origin]
+	if !ok {
+		return nil, fmt.Errorf("%w: signing key of %s", ErrKeyNotFound, origin)
+	}
+
+	return key, nil
+}
+
+// signedEnvelope is the canonical form of the message that is signed.
+// Every header but the signature is signed, and payloads are written as sorted JSON, so every encoding signs the same bytes.
+type signedEnvelope struct {
+	ID            string            `json:"id"`
+	Origin        string            `json:"origin"`
+	Action        string            `json:"action"`
+	CorrelationID string            `json:"correlationId"`
+	OccurredAt    string            `json:"occurredAt"`
+	Headers       map[string]string `json:"headers"`
+	AuthContext   any               `json:"authenticationContext"`
+	Message       any               `json:"message"`
+}
+
+var (
+	signingKeyProviderMu sync.Mutex
+	signingKeyProvider   SigningKeyProvider
+)
+
+// WithSigning signs every published message with the HMAC key of the application, so consumers can verify its origin
+func WithSigning() ProducerOption {
+	return func(p *Producer) {
+		p.sign = true
+	}
+}
+
+// SetSigningKeyProvider sets the signing key provider of the producers with WithSigning and of the consumers
+func SetSigningKeyProvider(provider SigningKeyProvider) {
+	signingKeyProviderMu.Lock()
+	defer signingKeyProviderMu.Unlock()
+
+	signingKeyProvider = provider
+}
+
+// getSigningKeyProvider returns the signing key provider, falling back to the local key file of MESSAGING_KEY_FILE
+func getSigningKeyProvider() (SigningKeyProvider, error) {
+	signingKeyProviderMu.Lock()
+	provider := signingKeyProvider
+	signingKeyProviderMu.Unlock()
+	if provider != nil {
+		return provider, nil
+	}
+
+	if local, err := getKeyProvider(); err == nil {
+		if provider, ok := local.(SigningKeyProvider); ok {
+			return provider, nil
+		}
+	}
+
+	return nil, ErrNoSigningKeyProvider
+}
+
+// signMessage sets the signature header of the message with the key of the application
+func signMessage(ctx context.Context, msg *ProviderMessage) error {
+	if msg.encoding == EncodingCloudEventsStructured || msg.encoding == EncodingCloudEventsBinary {
+		for key := range msg.Headers {
+			if getCloudEventsExtensionName(key) != key {
+				return fmt.Errorf("%w: %s", ErrHeaderNotSignable, key)
+			}
+		}
+	}
+
+	provider, err := getSigningKeyProvider()
+	if err != nil {
+		return err
+	}
+
+	key, err := provider.SigningKey(ctx, config.APP_NAME)
+	if err != nil {
+		return err
+	}
+
+	signature, err := getSignature(key, msg)
+	if err != nil {
+		return err
+	}
+
+	WithHeader(headerSignature, base64.StdEncoding.EncodeToString(signature))(msg)
+	return nil
+}
+
+// verifySignature checks the signature of a signed message with the key of its origin.
+// Unsigned messages are rejected only when the signature is required.
+// Messages that fail verification are permanent errors, so they go straight to the dead-letter queue.
+func verifySignature(ctx context.Context, required bool, msg *ProviderMessage) error {
+	encoded, ok := msg.Headers[headerSignature]
+	if !ok {
+		if required {
+			return NewPermanentError(ErrMissingSignature)
+		}
+		return nil
+	}
+
+	provider, err := getSigningKeyProvider()
+	if err != nil {
+		if required {
+			return err
+		}
+		return nil
+	}
+
+	key, err := provider.SigningKey(ctx, msg.Origin)
+	if errors.Is(err, ErrKeyNotFound) {
+		return NewPermanentError(fmt.Errorf("%w: %w", ErrInvalidSignature, err))
+	}
+	if err != nil {
+		return err
+	}
+
+	signature, err := base64.StdEncoding.DecodeString(encoded)
+	if err != nil {
+		return NewPermanentError(ErrInvalidSignature)
+	}
+
+	expected, err := getSignature(key, msg)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal(signature, expected) {
+		return NewPermanentError(ErrInvalidSignature)
+	}
+
+	return nil
+}
+
+// getSignature returns the HMAC-SHA256 of the canonical envelope of the message
+func getSignature(key []byte, msg *ProviderMessage) ([]byte, error) {
+	envelope, err := getSignedEnvelope(msg)
+	if err != nil {
+		return nil, err
+	}
+
+	mac := hmac.New(sha256.New, key)
+	mac.Write(envelope)
+	return mac.Sum(nil), nil
+}
+
+// getSignedEnvelope returns the canonical envelope of the message, leaving out the signature itself.
+// The authentication context is only signed by the colibri encoding, since CloudEvents do not carry it.
+func getSignedEnvelope(msg *ProviderMessage) ([]byte, error) {
+	envelope := signedEnvelope{
+		ID:            msg.ID.String(),
+		Origin:        msg.Origin,
+		Action:        msg.Action,
+		CorrelationID: msg.CorrelationID,
+		Headers:       make(map[string]string, len(msg.Headers)),
+	}
+	if !msg.OccurredAt.IsZero() {
+		envelope.OccurredAt = msg.OccurredAt.UTC().Format(time.RFC3339Nano)
+	}
+	for key, value := range msg.Headers {
+		if key != headerSignature {
+			envelope.Headers[key] = value
+		}
+	}
+
+	var err error
+	if msg.encoding != EncodingCloudEventsStructured && msg.encoding != EncodingCloudEventsBinary {
+		if envelope.AuthContext, err = getCanonicalJSON(msg.AuthContext); err != nil {
+			return nil, err
+		}
+	}
+	if envelope.Message, err = getCanonicalJSON(msg.Message); err != nil {
+		return nil, err
+	}
+
+	return json.Marshal(envelope)
+}
+
+// getCanonicalJSON returns the value as decoded from its JSON by a consumer, which marshals with sorted keys
+func getCanonicalJSON(value any) (any, error) {
+	content, err := json.Marshal(value)
+	if err != nil {
+		return nil, err
+	}
+
+	var canonical any
+	err = json.Unmarshal(content, &canonical)
+	return canonical, err
+}
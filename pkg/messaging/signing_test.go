@@ -0,0 +1,162 @@
+package messaging
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/config"
+	"github.com/colibriproject-dev/colibri-sdk-go/pkg/base/security"
+	"github.com/stretchr/testify/assert"
+)
+
+func useSigningKeyProviderTest(t *testing.T, provider SigningKeyProvider) {
+	previousProvider := signingKeyProvider
+	SetSigningKeyProvider(provider)
+	t.Cleanup(func() { SetSigningKeyProvider(previousProvider) })
+}
+
+func TestSigning(t *testing.T) {
+	useMemoryMessaging(t)
+	useSigningKeyProviderTest(t, SigningKeys{config.APP_NAME: []byte("secret of the app")})
+	ctx := security.NewAuthenticationContext("tenant", "user").SetInContext(context.Background())
+
+	consume := func(queue string, opts ...ConsumerOption) *[]*ProviderMessage {
+		received := make([]*ProviderMessage, 0)
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				received = append(received, message)
+				return nil
+			},
+			qName: queue,
+		}, opts...)
+		return &received
+	}
+
+	t.Run("Should verify signed messages with every encoding", func(t *testing.T) {
+		for _, encoding := range []Encoding{EncodingColibri, EncodingCloudEventsStructured, EncodingCloudEventsBinary} {
+			queue := "SIGNING_QUEUE_" + string(encoding)
+			received := consume(queue, WithSignatureVerification())
+
+			err := NewProducer(queue, WithSigning(), WithEncoding(encoding)).
+				Publish(ctx, "create", userMessageTest{Name: "User"}, WithHeader("tenant", "tenant"))
+
+			assert.NoError(t, err)
+			assert.Len(t, *received, 1, encoding)
+			assert.NotEmpty(t, Memory().Published(queue)[0].Header(headerSignature))
+		}
+	})
+
+	t.Run("Should verify signed messages that are encrypted and offloaded", func(t *testing.T) {
+		useKeyProviderTest(t, NewKMSKeyProvider(&kmsTest{keys: map[string]byte{"alias/messaging": 42}}, "alias/messaging"))
+		store := &claimCheckStoreTest{objects: make(map[string][]byte)}
+		previousStore := claimCheckStore
+		SetClaimCheckStore(store)
+		defer SetClaimCheckStore(previousStore)
+		received := consume("SIGNING_SEALED_QUEUE", WithSignatureVerification())
+
+		err := NewProducer("SIGNING_SEALED_QUEUE", WithSigning(), WithEncryption(), WithClaimCheck("bucket", WithClaimCheckThreshold(10))).
+			Publish(ctx, "create", userMessageTest{Name: "User"})
+
+		assert.NoError(t, err)
+		assert.Len(t, *received, 1)
+		var user userMessageTest
+		assert.NoError(t, (*received)[0].DecodeMessage(&user))
+		assert.Equal(t, "User", user.Name)
+	})
+
+	t.Run("Should verify the signature again on redeliveries", func(t *testing.T) {
+		useKeyProviderTest(t, NewKMSKeyProvider(&kmsTest{keys: map[string]byte{"alias/messaging": 42}}, "alias/messaging"))
+		attempts := 0
+		newConsumerTest(t, &queueConsumerTest{
+			fn: func(ctx context.Context, message *ProviderMessage) error {
+				attempts++
+				if attempts == 1 {
+					return errors.New("temporary failure")
+				}
+				return nil
+			},
+			qName: "SIGNING_RETRY_QUEUE",
+		}, WithSignatureVerification(), WithRetryPolicy(RetryPolicy{MaxAttempts: 2, InitialBackoff: time.Millisecond}))
+
+		err := NewProducer("SIGNING_RETRY_QUEUE", WithSigning(), WithEncryption()).Publish(ctx, "create", userMessageTest{Name: "User"})
+
+		assert.NoError(t, err)
+		assert.Equal(t, 2, attempts)
+		assert.Len(t, Memory().Acked("SIGNING_RETRY_QUEUE"), 1)
+		assert.Empty(t, Memory().DeadLetters("SIGNING_RETRY_QUEUE"))
+	})
+
+	t.Run("Should send tampered messages to the dead-letter queue without consuming them", func(t *testing.T) {
+		tamper := map[string]func(msg *ProviderMessage){
+			"origin":       func(msg *ProviderMessage) { msg.Origin = "other-app" },
+			"payload":      func(msg *ProviderMessage) { msg.Message = userMessageTest{Name: "Admin"} },
+			"auth context": func(msg *ProviderMessage) { msg.AuthContext = security.NewAuthenticationContext("tenant", "admin") },
+			"header":       func(msg *ProviderMessage) { msg.Headers["x-tenant"] = "other-tenant" },
+			"added header": func(msg *ProviderMessage) { msg.Headers["x_role"] = "admin" },
+			"renamed header": func(msg *ProviderMessage) {
+				msg.Headers["xtenant"] = msg.Headers["x-tenant"]
+				delete(msg.Headers, "x-tenant")
+			},
+		}
+
+		for field, fn := range tamper {
+			queue := "SIGNING_TAMPERED_QUEUE_" + field
+			received := consume(queue)
+			msg := &ProviderMessage{Origin: config.APP_NAME, Action: "create", Message: userMessageTest{Name: "User"},
+				AuthContext: security.NewAuthenticationContext("tenant", "user"), Headers: map[string]string{"x-tenant": "tenant"}}
+			assert.NoError(t, signMessage(context.Background(), msg))
+			fn(msg)
+
+			assert.NoError(t, instance.producer(context.Background(), NewProducer(queue), msg))
+
+			assert.Empty(t, *received, field)
+			deadLetters := Memory().DeadLetters(queue)
+			assert.Len(t, deadLetters, 1, field)
+		}
+	})
+
+	t.Run("Should return error when signing CloudEvents with headers the encoding renames", func(t *testing.T) {
+		for _, encoding := range []Encoding{EncodingCloudEventsStructured, EncodingCloudEventsBinary} {
+			queue := "SIGNING_RENAMED_HEADER_QUEUE_" + string(encoding)
+
+			err := NewProducer(queue, WithSigning(), WithEncoding(encoding)).
+				Publish(ctx, "create", userMessageTest{Name: "User"}, WithHeader("x-tenant", "tenant"))
+
+			assert.ErrorIs(t, err, ErrHeaderNotSignable, encoding)
+			assert.Empty(t, Memory().Published(queue), encoding)
+		}
+	})
+
+	t.Run("Should reject unsigned messages when the consumer requires signatures", func(t *testing.T) {
+		received := consume("SIGNING_REQUIRED_QUEUE", WithSignatureVerification())
+
+		err := NewProducer("SIGNING_REQUIRED_QUEUE").Publish(ctx, "create", userMessageTest{Name: "User"})
+
+		assert.NoError(t, err)
+		assert.Empty(t, *received)
+		deadLetters := Memory().DeadLetters("SIGNING_REQUIRED_QUEUE")
+		assert.Len(t, deadLetters, 1)
+		assert.Contains(t, deadLetters[0].Reason, ErrMissingSignature.Error())
+	})
+
+	t.Run("Should consume unsigned messages when the consumer does not require signatures", func(t *testing.T) {
+		received := consume("SIGNING_OPTIONAL_QUEUE")
+
+		err := NewProducer("SIGNING_OPTIONAL_QUEUE").Publish(ctx, "create", userMessageTest{Name: "User"})
+
+		assert.NoError(t, err)
+		assert.Len(t, *received, 1)
+	})
+
+	t.Run("Should reject messages from origins without a signing key", func(t *testing.T) {
+		msg := &ProviderMessage{Origin: "unknown-app", Action: "create", Message: userMessageTest{Name: "User"},
+			Headers: map[string]string{headerSignature: "c2lnbmF0dXJl"}}
+
+		err := verifySignature(context.Background(), false, msg)
+
+		assert.ErrorIs(t, err, ErrInvalidSignature)
+		assert.True(t, IsPermanentError(err))
+	})
+}
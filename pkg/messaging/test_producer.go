@@ -41,24 +41,32 @@ func NewTestProducer[T any](producerFn func() error, testQueue string, timeoutIn
 	}
 }
 
-// Execute returns a T pointer or error in test execution
+// Execute returns a T pointer or error in test execution.
+// The consumer of the test queue is stopped before returning, so it does not outlive the test.
 func (p *TestProducer[T]) Execute() (response *T, err error) {
-	chSuccess := make(chan *T)
-	chError := make(chan error)
+	chSuccess := make(chan *T, 1)
+	chError := make(chan error, 1)
 
-	NewConsumer(&testProducerConsumer{
+	consumer := NewConsumer(&testProducerConsumer{
 		fn: func(ctx context.Context, providerMessage *ProviderMessage) error {
 			var model T
 			if err := providerMessage.DecodeMessage(&model); err != nil {
-				chError <- err
+				select {
+				case chError <- err:
+				default:
+				}
 				return err
 			}
 
-			chSuccess <- &model
+			select {
+			case chSuccess <- &model:
+			default:
+			}
 			return nil
 		},
 		queueName: p.testQueue,
 	})
+	defer func() { _ = consumer.Stop(context.Background()) }()
 
 	if err := p.producerFn(); err != nil {
 		return nil, err